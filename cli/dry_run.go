@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/randa-mu/ssv-dkg/shared"
+	"github.com/randa-mu/ssv-dkg/shared/api"
+	"github.com/randa-mu/ssv-dkg/shared/crypto"
+	"github.com/randa-mu/ssv-dkg/shared/registry"
+	"golang.org/x/exp/slices"
+)
+
+// DryRunReshare checks a reshare of an existing validator cluster onto a new set of operators could go ahead, without running it.
+// The new operators must be ready to reshare, and the old threshold of the operators holding key shares must be able to take part,
+// still hold their key share and have it verify against the group's public polynomial
+func DryRunReshare(operators []string, state api.SigningOutput, operatorsRegistry *registry.Registry, log shared.QuietLogger) error {
+	suite := crypto.NewBLSSuite()
+	_, plan, _, err := prepareReshare(suite, operators, state, operatorsRegistry, log)
+	if err != nil {
+		return err
+	}
+
+	shares := make(map[uint32]api.OperatorShare)
+	for _, share := range state.OperatorShares {
+		shares[share.Identity.OperatorID] = share
+	}
+
+	log.MaybeLog("⏳ verifying key shares")
+	verified := 0
+	for _, identity := range append(slices.Clone(plan.staying), plan.dealers...) {
+		if err := verifyOperatorShare(identity.Address, state, shares[identity.OperatorID]); err != nil {
+			log.MaybeLog(fmt.Sprintf("❌ operator %d can't reshare its key share: %v", identity.OperatorID, err))
+			continue
+		}
+		log.MaybeLog(fmt.Sprintf("✅ operator %d holds a valid key share", identity.OperatorID))
+		verified++
+	}
+
+	if verified < plan.oldThreshold {
+		return fmt.Errorf("only %d operators hold a valid key share, but the old threshold requires %d", verified, plan.oldThreshold)
+	}
+	return nil
+}
+
+// verifyOperatorShare asks an operator to check it still holds the key share in the state, and that it verifies
+// against the group's public polynomial
+func verifyOperatorShare(address string, state api.SigningOutput, share api.OperatorShare) error {
+	client := api.NewSidecarClient(address)
+	protocolVersion, err := negotiateCapability(client, api.CapabilityVerifyShare)
+	if err != nil {
+		return err
+	}
+
+	encryptedShareHash := sha256.Sum256(share.EncryptedShare)
+	response, err := client.VerifyShare(api.VerifyShareRequest{
+		SessionID:          hex.EncodeToString(state.SessionID),
+		EncryptedShareHash: encryptedShareHash[:],
+		PublicPolynomial:   state.GroupPublicPolynomial,
+		ProtocolVersion:    protocolVersion,
+	})
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(response.SharePublicKey, share.SharePublicKey) {
+		return errors.New("the operator's key share does not match the share public key in the state")
+	}
+	return nil
+}
@@ -0,0 +1,5 @@
+package cmd
+
+// the launchpad requires a deposit CLI version in deposit data files, so we
+// report the staking-deposit-cli version whose output format we match
+const depositCLIVersion = "2.7.0"
@@ -1,26 +1,36 @@
 package cmd
 
 import (
+	"errors"
+	"fmt"
+	"time"
+
 	"github.com/spf13/cobra"
+
+	"github.com/randa-mu/ssv-dkg/shared"
+	"github.com/randa-mu/ssv-dkg/shared/crypto"
+	"github.com/randa-mu/ssv-dkg/shared/network"
+	"github.com/randa-mu/ssv-dkg/shared/registry"
 )
 
 var (
-	sourceUrlFlag  string
-	sourceFileFlag string
-	operatorsCmd   = &cobra.Command{
+	sourceUrlFlag    string
+	sourceFileFlag   string
+	probeTimeoutFlag time.Duration
+	operatorsCmd     = &cobra.Command{
 		Use:   "operators",
 		Short: "Commands relating to SSV node operators",
 	}
 )
 
 func init() {
-	operatorsCmd.AddCommand(operatorsListCmd)
+	operatorsCmd.AddCommand(operatorsListCmd, operatorsSelectCmd)
 	operatorsCmd.PersistentFlags().StringVarP(
 		&sourceUrlFlag,
 		"source-url",
 		"u",
-		"https://raw.githubusercontent.com/randa-mu/ssv-dkg/master/nodes/operators-mainnet.json",
-		"The location of a toml file listing operators and their signed public keys",
+		"",
+		"The location of a toml file listing operators and their signed public keys. Defaults to the registry of the network",
 	)
 	operatorsCmd.PersistentFlags().StringVarP(
 		&sourceFileFlag,
@@ -29,4 +39,70 @@ func init() {
 		"",
 		"A local toml file listing operators and their signed public keys",
 	)
+	operatorsCmd.PersistentFlags().StringVarP(
+		&networkFlag,
+		"network",
+		"N",
+		"mainnet",
+		"mainnet, hoodi, holesky or the path to a custom network file",
+	)
+	operatorsCmd.PersistentFlags().DurationVar(
+		&probeTimeoutFlag,
+		"timeout",
+		5*time.Second,
+		"How long to wait for each operator to respond to its health check",
+	)
+}
+
+// loadVerifiedOperators reads the operators registry from the source flags, or the network's registry by default,
+// dropping any operators whose signed identity doesn't verify. If the network has registry maintainers,
+// the registry must be signed by them and not be older than the last one used
+func loadVerifiedOperators(log shared.QuietLogger) ([]registry.Operator, error) {
+	n, err := network.Resolve(networkFlag)
+	if err != nil {
+		return nil, err
+	}
+
+	// read the list of operators from file or URL
+	location := sourceFileFlag
+	if location != "" {
+		log.MaybeLog("📂 reading operators from a local file")
+	} else {
+		location = sourceUrlFlag
+		if location == "" {
+			location = n.OperatorsRegistryUrl
+		}
+		if location == "" {
+			return nil, errors.New("you must provide either a `source-url` or `source-file`!")
+		}
+		log.MaybeLog("🌐 reading operators from the internet")
+	}
+	r, err := resolveRegistry(n, location, log)
+	if err != nil {
+		return nil, err
+	}
+	if n.RegistryMaintainers.Configured() {
+		log.MaybeLog(fmt.Sprintf("🔏 registry serial %d is signed by its maintainers and valid until %s", r.Serial, r.Expiry.Format(time.RFC3339)))
+	}
+
+	// verify the signatures of all the operators, dropping any that have expired or been revoked
+	suite := crypto.NewBLSSuite()
+	now := time.Now()
+	verified := make([]registry.Operator, 0, len(r.Operators))
+	for _, op := range r.Operators {
+		if err := op.Verify(suite); err != nil {
+			log.MaybeLog(fmt.Sprintf("🔒 error verifying key for %s", op.Address))
+			continue
+		}
+		if err := op.CheckExpiry(now); err != nil {
+			log.MaybeLog(fmt.Sprintf("⌛ %v", err))
+			continue
+		}
+		if err := r.CheckRevoked(op.Identity); err != nil {
+			log.MaybeLog(fmt.Sprintf("🚫 %v", err))
+			continue
+		}
+		verified = append(verified, op)
+	}
+	return verified, nil
 }
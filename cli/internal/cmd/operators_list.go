@@ -1,25 +1,33 @@
 package cmd
 
 import (
+	"bytes"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"log"
-	"net/http"
 	"os"
+	"strconv"
 	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
 
+	"github.com/randa-mu/ssv-dkg/cli"
 	"github.com/randa-mu/ssv-dkg/shared"
-	"github.com/randa-mu/ssv-dkg/shared/crypto"
 )
 
-var quietFlag bool
+const (
+	tableFormat = "table"
+	jsonFormat  = "json"
+	csvFormat   = "csv"
+)
 
-type operatorsJsonResponse struct {
-	Operators []crypto.Identity `json:"operators"`
-}
+var (
+	quietFlag  bool
+	formatFlag string
+)
 
 var operatorsListCmd = &cobra.Command{
 	Use:   "list",
@@ -36,111 +44,142 @@ func init() {
 		false,
 		"With short enabled, the stdout input is simpler and machine readable",
 	)
+	operatorsListCmd.PersistentFlags().StringVar(
+		&formatFlag,
+		"format",
+		tableFormat,
+		"The format to print the operators and their health in: table, json or csv",
+	)
 }
 
 func listOperators(_ *cobra.Command, _ []string) {
-	if sourceFileFlag == "" && sourceUrlFlag == "" {
-		log.Fatal("you must provide either a `source-url` or `source-file`!")
+	if formatFlag != tableFormat && formatFlag != jsonFormat && formatFlag != csvFormat {
+		fmt.Printf("❌ unknown format %s; must be one of table, json or csv\n", formatFlag)
+		os.Exit(1)
 	}
 
-	log := shared.QuietLogger{Quiet: quietFlag}
-
-	// read the list of operators from file or URL
-	var operators []crypto.Identity
-	if sourceFileFlag != "" {
-		log.MaybeLog("📂 reading operators from a local file")
-		operators = readSourceFile(sourceFileFlag)
-	} else {
-		log.MaybeLog("🌐 reading operators from the internet")
-		operators = readSourceUrl(sourceUrlFlag)
-	}
-
-	// verify the signatures of all the operators
-	operatorIdentities := make([]crypto.Identity, 0, len(operators))
-	suite := crypto.NewBLSSuite()
-
-	for _, op := range operators {
-		if err := op.Verify(suite); err != nil {
-			log.MaybeLog(fmt.Sprintf("🔒 error verifying key for %s", op.Address))
-			continue
-		}
-		operatorIdentities = append(operatorIdentities, op)
+	// only the table is for humans, so we keep the other formats machine readable
+	log := shared.QuietLogger{Quiet: quietFlag || formatFlag != tableFormat}
+	operators, err := loadVerifiedOperators(log)
+	if err != nil {
+		log.Log(fmt.Sprintf("❌ %v", err))
+		os.Exit(1)
 	}
 
-	// print out the pretty or machine-readable results
 	if quietFlag {
-		printOperatorsQuiet(log, operatorIdentities)
-	} else {
-		printOperatorsPretty(log, operatorIdentities)
+		entries := make([]string, len(operators))
+		for i, operator := range operators {
+			entries[i] = fmt.Sprintf("%d", operator.OperatorID)
+		}
+		log.Log(strings.Join(entries, " "))
+		return
 	}
-}
 
-func readSourceFile(path string) []crypto.Identity {
-	contents, err := os.ReadFile(path)
-	if err != nil {
-		log.Fatalf("there was an error reading the source file: %v", err)
+	if len(operators) == 0 {
+		log.Log("❌ operator list was empty!")
+		os.Exit(1)
 	}
 
-	var j operatorsJsonResponse
-	err = json.Unmarshal(contents, &j)
+	log.MaybeLog("⏳ checking health of operators")
+	probes := cli.ProbeOperators(operators, probeTimeoutFlag)
+	output, err := formatOperators(formatFlag, probes)
 	if err != nil {
-		log.Fatalf("there was an error unmarshalling the source file: %v", err)
+		log.Log(fmt.Sprintf("❌ %v", err))
+		os.Exit(1)
 	}
-	return j.Operators
+	fmt.Print(output)
 }
 
-func readSourceUrl(url string) []crypto.Identity {
-	res, err := http.Get(url)
-	if err != nil {
-		log.Fatalf("failed to reach URL %s: %v", url, err)
-	}
-	defer res.Body.Close()
+// operatorStatus is the machine-readable health of an operator
+type operatorStatus struct {
+	OperatorID      uint32   `json:"operator_id"`
+	Address         string   `json:"address"`
+	Location        string   `json:"location,omitempty"`
+	Organisation    string   `json:"organisation,omitempty"`
+	Healthy         bool     `json:"healthy"`
+	LatencyMs       int64    `json:"latency_ms"`
+	Version         string   `json:"version,omitempty"`
+	ProtocolVersion uint32   `json:"protocol_version,omitempty"`
+	Capabilities    []string `json:"capabilities,omitempty"`
+	Error           string   `json:"error,omitempty"`
+}
 
-	body, err := io.ReadAll(res.Body)
-	if err != nil {
-		log.Fatal("failed to read response body")
+func toOperatorStatus(p cli.OperatorProbe) operatorStatus {
+	status := operatorStatus{
+		OperatorID:      p.Operator.OperatorID,
+		Address:         p.Operator.Address,
+		Location:        p.Operator.Location,
+		Organisation:    p.Operator.Organisation,
+		Healthy:         p.Healthy(),
+		LatencyMs:       p.Latency.Milliseconds(),
+		Version:         p.Version,
+		ProtocolVersion: p.ProtocolVersion,
+		Capabilities:    p.Capabilities,
 	}
-
-	var j operatorsJsonResponse
-	err = json.Unmarshal(body, &j)
-	if err != nil {
-		log.Fatalf("there was an error unmarshalling the HTTP response: %v", err)
+	if err := errors.Join(p.Err, p.IdentityErr); err != nil {
+		status.Error = err.Error()
 	}
-
-	return j.Operators
+	return status
 }
 
-func printOperatorsPretty(logger shared.QuietLogger, operators []crypto.Identity) {
-	if len(operators) == 0 {
-		log.Fatal("Operator list was empty!")
+// formatOperators renders the results of probing the operators as a table, JSON or CSV
+func formatOperators(format string, probes []cli.OperatorProbe) (string, error) {
+	statuses := make([]operatorStatus, len(probes))
+	for i, p := range probes {
+		statuses[i] = toOperatorStatus(p)
 	}
 
-	logger.Log("⏳checking health of operators")
-
-	success := make([]crypto.Identity, 0, len(operators))
-	failure := make([]crypto.Identity, 0, len(operators))
-	for _, o := range operators {
-		res, err := http.Get(fmt.Sprintf("%s/health", o.Address))
-		if err != nil || res.StatusCode != 200 {
-			failure = append(failure, o)
-		} else {
-			success = append(success, o)
+	buf := bytes.Buffer{}
+	switch format {
+	case jsonFormat:
+		j, err := json.MarshalIndent(statuses, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		buf.Write(j)
+		buf.WriteString("\n")
+
+	case csvFormat:
+		w := csv.NewWriter(&buf)
+		_ = w.Write([]string{"operator_id", "address", "location", "organisation", "healthy", "latency_ms", "version", "protocol_version", "capabilities", "error"})
+		for _, s := range statuses {
+			_ = w.Write([]string{
+				strconv.FormatUint(uint64(s.OperatorID), 10),
+				s.Address,
+				s.Location,
+				s.Organisation,
+				strconv.FormatBool(s.Healthy),
+				strconv.FormatInt(s.LatencyMs, 10),
+				s.Version,
+				strconv.FormatUint(uint64(s.ProtocolVersion), 10),
+				strings.Join(s.Capabilities, " "),
+				s.Error,
+			})
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return "", err
 		}
-	}
 
-	logger.Log(fmt.Sprintf("Status\tID\tAddress"))
-	for _, s := range success {
-		logger.Log(fmt.Sprintf("✅\t%d\t%s", s.OperatorID, s.Address))
-	}
-	for _, f := range failure {
-		logger.Log(fmt.Sprintf("❌\t%d\t%s", f.OperatorID, f.Address))
-	}
-}
+	case tableFormat:
+		w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "Status\tID\tAddress\tLatency\tVersion\tCapabilities")
+		for i, s := range statuses {
+			status := "✅"
+			if !s.Healthy {
+				status = "❌"
+			}
+			_, _ = fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\n", status, s.OperatorID, s.Address, probes[i].Latency.Round(time.Millisecond), s.Version, strings.Join(s.Capabilities, ","))
+		}
+		_ = w.Flush()
+		for _, s := range statuses {
+			if s.Error != "" {
+				buf.WriteString(fmt.Sprintf("❌ %d %s: %s\n", s.OperatorID, s.Address, s.Error))
+			}
+		}
 
-func printOperatorsQuiet(log shared.QuietLogger, operators []crypto.Identity) {
-	entries := make([]string, len(operators))
-	for i, operator := range operators {
-		entries[i] = fmt.Sprintf("%d", operator.OperatorID)
+	default:
+		return "", fmt.Errorf("unknown format %s; must be one of table, json or csv", format)
 	}
-	log.Log(strings.Join(entries, " "))
+	return buf.String(), nil
 }
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/randa-mu/ssv-dkg/cli"
+	"github.com/randa-mu/ssv-dkg/shared/crypto"
+	"github.com/randa-mu/ssv-dkg/shared/registry"
+)
+
+func TestFormatOperators(t *testing.T) {
+	probes := []cli.OperatorProbe{
+		{
+			Operator:        registry.Operator{Identity: crypto.Identity{OperatorID: 1, Address: "https://example.org"}, Location: "DE"},
+			Latency:         42 * time.Millisecond,
+			Version:         "v0.0.1",
+			ProtocolVersion: 2,
+			Capabilities:    []string{"reshare", "topup"},
+		},
+		{
+			Operator: registry.Operator{Identity: crypto.Identity{OperatorID: 2, Address: "https://muster.de"}},
+			Latency:  5 * time.Second,
+			Err:      errors.New("timed out"),
+		},
+	}
+
+	output, err := formatOperators(jsonFormat, probes)
+	require.NoError(t, err)
+	var statuses []operatorStatus
+	require.NoError(t, json.Unmarshal([]byte(output), &statuses))
+	require.Len(t, statuses, 2)
+	require.True(t, statuses[0].Healthy)
+	require.Equal(t, int64(42), statuses[0].LatencyMs)
+	require.Equal(t, []string{"reshare", "topup"}, statuses[0].Capabilities)
+	require.False(t, statuses[1].Healthy)
+	require.Equal(t, "timed out", statuses[1].Error)
+
+	output, err = formatOperators(csvFormat, probes)
+	require.NoError(t, err)
+	records, err := csv.NewReader(strings.NewReader(output)).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	require.Equal(t, []string{"1", "https://example.org", "DE", "", "true", "42", "v0.0.1", "2", "reshare topup", ""}, records[1])
+
+	output, err = formatOperators(tableFormat, probes)
+	require.NoError(t, err)
+	require.Contains(t, output, "https://muster.de: timed out")
+
+	_, err = formatOperators("yaml", probes)
+	require.Error(t, err)
+}
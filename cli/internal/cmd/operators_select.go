@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/randa-mu/ssv-dkg/cli"
+	"github.com/randa-mu/ssv-dkg/shared"
+)
+
+var (
+	countFlag          int
+	excludeFlag        []uint
+	requireFlag        []uint
+	maxLatencyFlag     time.Duration
+	locationFlag       []string
+	organisationFlag   []string
+	operatorsSelectCmd = &cobra.Command{
+		Use:   "select",
+		Short: "Selects healthy operators from the registry for a validator cluster",
+		Long:  "Selects the fastest healthy operators from the registry that match your criteria. The selection can be piped into `ssv-dkg sign`.",
+		Run:   selectOperators,
+	}
+)
+
+func init() {
+	operatorsSelectCmd.PersistentFlags().IntVarP(
+		&countFlag,
+		"count",
+		"c",
+		4,
+		"The number of operators to select: 4, 7, 10 or 13",
+	)
+	operatorsSelectCmd.PersistentFlags().UintSliceVar(
+		&excludeFlag,
+		"exclude",
+		nil,
+		"The IDs of operators that must not be selected",
+	)
+	operatorsSelectCmd.PersistentFlags().UintSliceVar(
+		&requireFlag,
+		"require",
+		nil,
+		"The IDs of operators that must be selected",
+	)
+	operatorsSelectCmd.PersistentFlags().DurationVar(
+		&maxLatencyFlag,
+		"max-latency",
+		0,
+		"Only select operators that respond within this duration, e.g. 200ms",
+	)
+	operatorsSelectCmd.PersistentFlags().StringArrayVar(
+		&locationFlag,
+		"location",
+		nil,
+		"Only select operators in this location, as listed in the registry. Can be passed more than once",
+	)
+	operatorsSelectCmd.PersistentFlags().StringArrayVar(
+		&organisationFlag,
+		"organisation",
+		nil,
+		"Only select operators run by this organisation, as listed in the registry. Can be passed more than once",
+	)
+	operatorsSelectCmd.PersistentFlags().BoolVarP(
+		&quietFlag,
+		"quiet",
+		"q",
+		false,
+		"Only print out the IDs of the selected operators, e.g. to pipe into `ssv-dkg sign`",
+	)
+}
+
+func selectOperators(_ *cobra.Command, _ []string) {
+	log := shared.QuietLogger{Quiet: quietFlag}
+	operators, err := loadVerifiedOperators(log)
+	if err != nil {
+		log.Log(fmt.Sprintf("❌ %v", err))
+		os.Exit(1)
+	}
+
+	log.MaybeLog("⏳ checking health of operators")
+	probes := cli.ProbeOperators(operators, probeTimeoutFlag)
+	selected, err := cli.SelectOperators(probes, cli.SelectionCriteria{
+		Count:         countFlag,
+		Exclude:       toOperatorIDs(excludeFlag),
+		Require:       toOperatorIDs(requireFlag),
+		MaxLatency:    maxLatencyFlag,
+		Locations:     locationFlag,
+		Organisations: organisationFlag,
+	})
+	if err != nil {
+		log.Log(fmt.Sprintf("❌ %v", err))
+		os.Exit(1)
+	}
+
+	ids := make([]string, len(selected))
+	for i, s := range selected {
+		ids[i] = fmt.Sprintf("%d", s.OperatorID)
+	}
+	if quietFlag {
+		log.Log(strings.Join(ids, " "))
+		return
+	}
+
+	log.Log("ID\tLatency\tAddress")
+	for _, s := range selected {
+		for _, p := range probes {
+			if p.Operator.OperatorID == s.OperatorID {
+				log.Log(fmt.Sprintf("%d\t%s\t%s", s.OperatorID, p.Latency.Round(time.Millisecond), s.Address))
+			}
+		}
+	}
+	log.Log(fmt.Sprintf("✅ sign with these operators using: ssv-dkg sign --operator-id %s", strings.Join(ids, " --operator-id ")))
+}
+
+func toOperatorIDs(ids []uint) []uint32 {
+	out := make([]uint32, len(ids))
+	for i, id := range ids {
+		out[i] = uint32(id)
+	}
+	return out
+}
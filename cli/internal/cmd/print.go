@@ -7,6 +7,7 @@ import (
 
 	"github.com/randa-mu/ssv-dkg/shared/api"
 	"github.com/randa-mu/ssv-dkg/shared/files"
+	"github.com/randa-mu/ssv-dkg/shared/network"
 	"github.com/spf13/cobra"
 )
 
@@ -34,7 +35,7 @@ func init() {
 		"network",
 		"N",
 		"mainnet",
-		"mainnet or holesky",
+		"mainnet, hoodi, holesky or the path to a custom network file",
 	)
 }
 
@@ -43,14 +44,11 @@ func Print(_ *cobra.Command, _ []string) {
 		log.Fatal("you must pass the input flag with the output of the DKG you wish to create a keyfile for")
 	}
 
-	var ssvClient api.SsvClient
-	if networkFlag == "mainnet" {
-		ssvClient = api.MainnetSsvClient()
-	} else if networkFlag == "holesky" {
-		ssvClient = api.HoleskySsvClient()
-	} else {
-		log.Fatal("you must select a network to run against - holesky or mainnet")
+	n, err := network.Resolve(networkFlag)
+	if err != nil {
+		log.Fatal(err)
 	}
+	ssvClient := api.NewSsvClient(n.SsvApiUrl)
 
 	s, err := files.LoadState(dkgStateFlag)
 	if err != nil {
@@ -0,0 +1,208 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/randa-mu/ssv-dkg/cli"
+	"github.com/randa-mu/ssv-dkg/shared"
+	"github.com/randa-mu/ssv-dkg/shared/api"
+	"github.com/randa-mu/ssv-dkg/shared/files"
+	"github.com/randa-mu/ssv-dkg/shared/network"
+	"github.com/randa-mu/ssv-dkg/shared/registry"
+)
+
+var (
+	intervalFlag time.Duration
+	refreshCmd   = &cobra.Command{
+		Use:   "refresh",
+		Short: "Refreshes the key shares of a validator cluster without changing its operators",
+		Long:  "Refreshes the key shares of a validator cluster without changing its operators or its public key, limiting how long a leaked key share is useful for. The validator must be registered again with the new key shares.",
+		Run:   Refresh,
+	}
+)
+
+func init() {
+	refreshCmd.PersistentFlags().StringVarP(
+		&stateFilePath,
+		"state",
+		"s",
+		"",
+		"The filepath of the state of the validator cluster whose key shares you wish to refresh. Note: this will get rewritten during execution.",
+	)
+
+	refreshCmd.PersistentFlags().Int32VarP(
+		&validatorNonceFlag,
+		"validator-nonce",
+		"n",
+		-1, // default is -1 to ensure user MUST pass this flag (as -1 is invalid)
+		"The current validator cluster nonce of the owner from the SSV contract, used to register the validator with its new key shares. Be _very_ sure about this or you'll lose your stake",
+	)
+
+	refreshCmd.PersistentFlags().DurationVar(
+		&intervalFlag,
+		"interval",
+		0,
+		"Keep refreshing the key shares at this interval, e.g. 720h, rather than only once. Each refresh after the first uses the owner's validator nonce from the SSV API, and refreshing stops if the last refresh's keyshares file hasn't been registered",
+	)
+
+	refreshCmd.PersistentFlags().StringVarP(
+		&networkFlag,
+		"network",
+		"N",
+		"mainnet",
+		"mainnet, hoodi, holesky or the path to a custom network file",
+	)
+
+	refreshCmd.PersistentFlags().StringVar(
+		&registryFlag,
+		"registry",
+		"",
+		"The path or URL of the operators registry to check the operators' identities against before refreshing. Defaults to the registry of the network",
+	)
+
+	refreshCmd.PersistentFlags().BoolVar(
+		&skipRegistryCheckFlag,
+		"skip-registry-check",
+		false,
+		"Trust whatever identity each operator returns rather than checking it against the operators registry. Operator IDs are still resolved from the registry",
+	)
+}
+
+func Refresh(_ *cobra.Command, _ []string) {
+	logger := shared.QuietLogger{}
+	if stateFilePath == "" {
+		log.Fatal("you must enter the path to the state created from the initial distributed key generation")
+	}
+	if intervalFlag < 0 {
+		log.Fatal("the interval can't be negative")
+	}
+
+	n, err := network.Resolve(networkFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ssvClient := api.NewSsvClient(n.SsvApiUrl)
+
+	s, err := files.LoadState(stateFilePath)
+	if err != nil {
+		log.Fatalf("❌ tried to load state from %s but it failed: %v", stateFilePath, err)
+	}
+	owner, err := parseReshareOwnerConfig(s.OwnerConfig, validatorNonceFlag, "", s.SigningOutput.SessionID, "", logger)
+	if err != nil {
+		log.Fatalf("error parsing owner details: %v", err)
+	}
+
+	if intervalFlag > 0 && !ssvClient.IsConfigured() {
+		log.Fatalf("network %s has no SSV API to read the owner's validator nonce from between refreshes", n.Name)
+	}
+
+	// the validator nonce the last successful refresh signed, which registering its keyshares file uses up
+	var refreshedNonce *uint32
+	for {
+		// the registry is reloaded each time, so scheduled refreshes pick up revocations and new registry versions
+		operatorsRegistry, err := loadRegistry(n, registryFlag, skipRegistryCheckFlag, logger)
+		if err == nil {
+			err = refreshOnce(owner, operatorsRegistry, ssvClient, logger)
+		}
+		if err == nil {
+			recordRegistrySerial(n, operatorsRegistry, logger)
+		}
+		if intervalFlag == 0 {
+			if err != nil {
+				log.Fatalf("❌ refresh failed: %v", err)
+			}
+			return
+		}
+
+		// scheduled refreshes retry at the next interval
+		if err != nil {
+			logger.Log(fmt.Sprintf("❌ refresh failed, retrying in %s: %v", intervalFlag, err))
+		} else {
+			nonce := owner.ValidatorNonce
+			refreshedNonce = &nonce
+			logger.MaybeLog(fmt.Sprintf("⏰ refreshing again in %s; register the validator with the new key shares before then", intervalFlag))
+		}
+
+		// the owner may have registered other validators in the meantime, so the next nonce is read from SSV rather than guessed
+		for {
+			time.Sleep(intervalFlag)
+			nonce, err := nextRefreshNonce(ssvClient, owner.Address, refreshedNonce)
+			if errors.Is(err, errRefreshNotRegistered) {
+				log.Fatalf("❌ %v", err)
+			}
+			if err == nil {
+				owner.ValidatorNonce = nonce
+				break
+			}
+			logger.Log(fmt.Sprintf("❌ couldn't read the owner's validator nonce from the SSV API, retrying in %s: %v", intervalFlag, err))
+		}
+	}
+}
+
+var errRefreshNotRegistered = errors.New("the keyshares file from the last refresh hasn't been registered")
+
+// nextRefreshNonce reads the owner's validator nonce from the SSV API for the next scheduled refresh. If the last refresh
+// succeeded, its keyshares file must have been registered first, using up the nonce it signed; otherwise the validator
+// would still be running with the key shares that were meant to be refreshed
+func nextRefreshNonce(ssvClient api.SsvClient, owner []byte, refreshedNonce *uint32) (uint32, error) {
+	nonce, err := ssvClient.FetchOwnerNonce(owner)
+	if err != nil {
+		return 0, err
+	}
+	if refreshedNonce != nil && nonce <= *refreshedNonce {
+		return 0, fmt.Errorf("%w: the owner's validator nonce on SSV is still %d. Register it, then run refresh again", errRefreshNotRegistered, nonce)
+	}
+	return nonce, nil
+}
+
+// refreshOnce refreshes the key shares in the state file, storing the new state and a keyshares file for registering the validator with them
+func refreshOnce(owner api.OwnerConfig, operatorsRegistry *registry.Registry, ssvClient api.SsvClient, logger shared.QuietLogger) error {
+	s, err := files.LoadState(stateFilePath)
+	if err != nil {
+		return fmt.Errorf("tried to load state from %s but it failed: %w", stateFilePath, err)
+	}
+
+	operators := make([]string, len(s.SigningOutput.OperatorShares))
+	for i, share := range s.SigningOutput.OperatorShares {
+		operators[i] = share.Identity.Address
+	}
+	operatorsRegistry = pinningRegistry(operatorsRegistry, skipRegistryCheckFlag, operators, logger)
+
+	output, err := cli.Refresh(s.SigningOutput, owner, operatorsRegistry, logger)
+	if err != nil {
+		return err
+	}
+
+	nextState := files.StoredState{
+		OwnerConfig:   owner,
+		SigningOutput: output,
+	}
+	bytes, err := files.StoreState(stateFilePath, nextState)
+	if err != nil {
+		logger.Log(fmt.Sprintf("⚠️  there was an error storing your state; printing it to the console so you can save it in a flat file. Err: %v", err))
+		logger.Log(string(bytes))
+		return err
+	}
+	logger.MaybeLog(fmt.Sprintf("✅ key shares refreshed successfully. Encrypted shares stored in %s", stateFilePath))
+
+	keyshareFile, err := files.CreateKeyshareFile(nextState.OwnerConfig, nextState.SigningOutput, ssvClient)
+	if err != nil {
+		return fmt.Errorf("couldn't create keyshare file: %w", err)
+	}
+	keysharePath := path.Join(filepath.Dir(stateFilePath), files.RefreshKeyShareFileName())
+	bytes, err = files.StoreStateIfNotExists(keysharePath, keyshareFile)
+	if err != nil {
+		logger.Log(fmt.Sprintf("⚠️  there was an error storing the keyshares file; printing it to the console so you can save it in a flat file. Err: %v", err))
+		logger.Log(string(bytes))
+		return nil
+	}
+	logger.MaybeLog(fmt.Sprintf("📄 the keyshares file for registering the validator with its new key shares has been stored to %s", keysharePath))
+	return nil
+}
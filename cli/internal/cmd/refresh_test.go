@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/randa-mu/ssv-dkg/shared/api"
+)
+
+func TestNextRefreshNonce(t *testing.T) {
+	owner := []byte{0xde, 0xad, 0xbe, 0xef}
+	nonce := 5
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/accounts/0xdeadbeef" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = fmt.Fprintf(w, `{"type": "account", "data": {"ownerAddress": "0xdeadbeef", "nonce": %d}}`, nonce)
+	}))
+	t.Cleanup(server.Close)
+	ssvClient := api.NewSsvClient(server.URL)
+
+	// without a successful refresh, the owner's current nonce is used
+	next, err := nextRefreshNonce(ssvClient, owner, nil)
+	require.NoError(t, err)
+	require.Equal(t, uint32(5), next)
+
+	// after one, its keyshares file must have been registered, using up its nonce
+	refreshed := uint32(5)
+	_, err = nextRefreshNonce(ssvClient, owner, &refreshed)
+	require.ErrorIs(t, err, errRefreshNotRegistered)
+
+	nonce = 6
+	next, err = nextRefreshNonce(ssvClient, owner, &refreshed)
+	require.NoError(t, err)
+	require.Equal(t, uint32(6), next)
+
+	// errors from the SSV API aren't mistaken for an unregistered refresh
+	_, err = nextRefreshNonce(ssvClient, []byte{0xca, 0xfe}, &refreshed)
+	require.Error(t, err)
+	require.NotErrorIs(t, err, errRefreshNotRegistered)
+}
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/randa-mu/ssv-dkg/shared"
+	"github.com/randa-mu/ssv-dkg/shared/network"
+	"github.com/randa-mu/ssv-dkg/shared/registry"
+)
+
+var (
+	skipRegistryCheckFlag bool
+	// registrySerialsPath is where the highest serial of each network's signed registry that has been used is recorded
+	registrySerialsPath = defaultRegistrySerialsPath()
+)
+
+// loadRegistry loads the operators registry: the one passed with `--registry`, or the network's registry by default.
+// Operator IDs are still resolved against it with `--skip-registry-check`, so it's only an error not to have one
+// if the operators' identities are going to be pinned against it
+func loadRegistry(n network.Network, registryLocation string, skipRegistryCheck bool, log shared.QuietLogger) (*registry.Registry, error) {
+	if registryLocation == "" {
+		registryLocation = n.OperatorsRegistryUrl
+	}
+	if registryLocation == "" {
+		if skipRegistryCheck {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("network %s has no operators registry; pass one with --registry or pass --skip-registry-check to trust the operators' keys", n.Name)
+	}
+
+	r, err := resolveRegistry(n, registryLocation, log)
+	if err != nil {
+		if skipRegistryCheck {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error loading the operators registry: %v", err)
+	}
+	return &r, nil
+}
+
+// resolveRegistry loads a registry from a file or URL, which must be signed by the network's maintainers if it has any.
+// A signed registry can't be older than the last one used for the network, so it can't be rolled back
+func resolveRegistry(n network.Network, location string, log shared.QuietLogger) (registry.Registry, error) {
+	r, err := registry.Resolve(location, n.RegistryMaintainers)
+	if err != nil {
+		return registry.Registry{}, err
+	}
+	if !n.RegistryMaintainers.Configured() {
+		return r, nil
+	}
+	err = registry.CheckSerial(registrySerialsPath, n.Name, r)
+	if errors.Is(err, registry.ErrRolledBack) {
+		return registry.Registry{}, err
+	}
+	if err != nil {
+		log.Log(fmt.Sprintf("⚠️  couldn't check the registry hasn't been rolled back: %v", err))
+	}
+	return r, nil
+}
+
+// recordRegistrySerial records the serial of the signed registry an operation succeeded with, so older registries
+// are refused from then on. Failing to record it doesn't undo the operation, so it's only warned about
+func recordRegistrySerial(n network.Network, r *registry.Registry, log shared.QuietLogger) {
+	if r == nil || !n.RegistryMaintainers.Configured() {
+		return
+	}
+	if err := registry.RecordSerial(registrySerialsPath, n.Name, *r); err != nil {
+		log.Log(fmt.Sprintf("⚠️  couldn't record the registry's serial, so it can't be checked for rollbacks: %v", err))
+	}
+}
+
+func defaultRegistrySerialsPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path.Join(".ssv", "registry-serials.json")
+	}
+	return path.Join(home, ".ssv", "registry-serials.json")
+}
+
+// resolveOperators replaces any operator IDs with the address registered for them,
+// leaving the URLs of private operators as they are
+func resolveOperators(operators []string, operatorsRegistry *registry.Registry) ([]string, error) {
+	resolved := make([]string, len(operators))
+	for i, operator := range operators {
+		id, err := strconv.ParseUint(operator, 10, 32)
+		if err != nil {
+			resolved[i] = operator
+			continue
+		}
+		if operatorsRegistry == nil {
+			return nil, fmt.Errorf("operator ID %d can't be resolved without the operators registry; pass its URL instead", id)
+		}
+		identity, found := operatorsRegistry.FindByID(uint32(id))
+		if !found {
+			return nil, fmt.Errorf("operator %d is not in the operators registry", id)
+		}
+		resolved[i] = identity.Address
+	}
+	return shared.Uniq(resolved), nil
+}
+
+// pinningRegistry returns the registry the operators' identities are pinned against, or nil if the check has been skipped.
+// Operators passed by URL that aren't in the registry are private operators whose keys can't be pinned,
+// so they're let through unpinned with a warning rather than failing the check for everyone else
+func pinningRegistry(operatorsRegistry *registry.Registry, skipRegistryCheck bool, operators []string, log shared.QuietLogger) *registry.Registry {
+	if skipRegistryCheck || operatorsRegistry == nil {
+		return nil
+	}
+
+	var unregistered []string
+	for _, operator := range operators {
+		if _, found := operatorsRegistry.FindByAddress(operator); !found {
+			unregistered = append(unregistered, operator)
+		}
+	}
+	if len(unregistered) == 0 {
+		return operatorsRegistry
+	}
+
+	log.Log(fmt.Sprintf("⚠️  %s are not in the operators registry, so their keys won't be pinned", strings.Join(unregistered, ", ")))
+	r := operatorsRegistry.AllowUnregistered(unregistered...)
+	return &r
+}
@@ -1,14 +1,20 @@
 package cmd
 
 import (
+	"bytes"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"strings"
 
 	golog "log"
 
 	"github.com/randa-mu/ssv-dkg/shared/api"
+	"github.com/randa-mu/ssv-dkg/shared/crypto"
 	"github.com/randa-mu/ssv-dkg/shared/files"
+	"github.com/randa-mu/ssv-dkg/shared/network"
 	"github.com/spf13/cobra"
 
 	"github.com/randa-mu/ssv-dkg/cli"
@@ -16,8 +22,10 @@ import (
 )
 
 var (
-	stateFilePath string
-	reshareCmd    = &cobra.Command{
+	stateFilePath              string
+	dryRunFlag                 bool
+	ownerTransferSignatureFlag string
+	reshareCmd                 = &cobra.Command{
 		Use:   "reshare",
 		Short: "Reshares the key for a validator cluster you have already created",
 		Long:  "Reshares the key for a validator cluster you have already created",
@@ -31,7 +39,14 @@ func init() {
 		"operator",
 		"o",
 		nil,
-		"SSV DKG node operators you wish to sign your ETH deposit data",
+		"The URLs of SSV DKG node operators you wish to reshare the key to, e.g. for private operators not in the operators registry",
+	)
+
+	reshareCmd.PersistentFlags().UintSliceVar(
+		&operatorIDFlag,
+		"operator-id",
+		nil,
+		"The IDs of SSV DKG node operators in the operators registry you wish to reshare the key to",
 	)
 
 	reshareCmd.PersistentFlags().StringVarP(
@@ -47,7 +62,51 @@ func init() {
 		"network",
 		"N",
 		"mainnet",
-		"mainnet, hoodi or holesky",
+		"mainnet, hoodi, holesky or the path to a custom network file",
+	)
+
+	reshareCmd.PersistentFlags().StringVar(
+		&registryFlag,
+		"registry",
+		"",
+		"The path or URL of the operators registry to check the operators' identities against before resharing. Defaults to the registry of the network",
+	)
+
+	reshareCmd.PersistentFlags().BoolVar(
+		&skipRegistryCheckFlag,
+		"skip-registry-check",
+		false,
+		"Trust whatever identity each operator returns rather than checking it against the operators registry. Operator IDs are still resolved from the registry",
+	)
+
+	reshareCmd.PersistentFlags().Int32VarP(
+		&validatorNonceFlag,
+		"validator-nonce",
+		"n",
+		-1, // default is -1 to ensure user MUST pass this flag (as -1 is invalid)
+		"The current validator cluster nonce of the owner from the SSV contract, used to register the validator with the new operators. Be _very_ sure about this or you'll lose your stake",
+	)
+
+	reshareCmd.PersistentFlags().StringVarP(
+		&ethAddressFlag,
+		"owner-address",
+		"a",
+		"",
+		"The ETH address in hex format of the owner registering the validator with the new operators. Defaults to the owner in the state file",
+	)
+
+	reshareCmd.PersistentFlags().StringVar(
+		&ownerTransferSignatureFlag,
+		"owner-transfer-signature",
+		"",
+		"The current owner's signature in hex format authorising the transfer of the validator to --owner-address. Run without it to print the message they must sign",
+	)
+
+	reshareCmd.PersistentFlags().BoolVar(
+		&dryRunFlag,
+		"dry-run",
+		false,
+		"Check the operators could reshare the key, including that enough of the current operators hold a valid key share, without resharing it or changing the state",
 	)
 }
 
@@ -57,37 +116,56 @@ func Reshare(cmd *cobra.Command, _ []string) {
 		golog.Fatal("you must enter the path to the state created from the initial distributed key generation")
 	}
 
-	var ssvClient api.SsvClient
-	if networkFlag == "mainnet" {
-		ssvClient = api.MainnetSsvClient()
-	} else if networkFlag == "holesky" {
-		ssvClient = api.HoleskySsvClient()
-	} else if networkFlag == "hoodi" {
-		ssvClient = api.HoodiSsvClient()
-	} else {
-		golog.Fatal("network must be either mainnet, holesky or hoodi")
+	n, err := network.Resolve(networkFlag)
+	if err != nil {
+		golog.Fatal(err)
 	}
+	ssvClient := api.NewSsvClient(n.SsvApiUrl)
 
-	// if the operator flag isn't passed, we consume operator addresses from stdin
-	operators, err := parseOperators(operatorFlag, cmd.InOrStdin())
+	operatorsRegistry, err := loadRegistry(n, registryFlag, skipRegistryCheckFlag, log)
 	if err != nil {
-		golog.Fatal("you must pass your new set of operators either via the operator flag or from stdin")
+		golog.Fatal(err)
 	}
 
+	// if the operator flags aren't passed, we consume operator addresses or IDs from stdin
+	operatorTokens, err := parseOperators(operatorFlag, operatorIDFlag, cmd.InOrStdin())
+	if err != nil {
+		golog.Fatal("you must pass your new set of operators either via the operator flags or from stdin")
+	}
+	operators, err := resolveOperators(operatorTokens, operatorsRegistry)
+	if err != nil {
+		golog.Fatal(err)
+	}
+	operatorsRegistry = pinningRegistry(operatorsRegistry, skipRegistryCheckFlag, operators, log)
+
 	// load any existing state and run the reshare
 	s, err := files.LoadState(stateFilePath)
 	if err != nil {
 		golog.Fatalf("❌ tried to load state from %s but it failed: %v", stateFilePath, err)
 	}
 
-	output, err := cli.Reshare(operators, s.SigningOutput, log)
+	if dryRunFlag {
+		if err := cli.DryRunReshare(operators, s.SigningOutput, operatorsRegistry, log); err != nil {
+			golog.Fatalf("❌ dry run failed: %v", err)
+		}
+		log.MaybeLog("✅ dry run succeeded; the key can be reshared to these operators. Nothing has been changed")
+		return
+	}
+
+	owner, err := parseReshareOwnerConfig(s.OwnerConfig, validatorNonceFlag, ethAddressFlag, s.SigningOutput.SessionID, ownerTransferSignatureFlag, log)
+	if err != nil {
+		golog.Fatalf("error parsing owner details: %v", err)
+	}
+
+	output, err := cli.Reshare(operators, s.SigningOutput, owner, operatorsRegistry, log)
 	if err != nil {
 		golog.Fatalf("❌ resharing failed: %v", err)
 	}
+	recordRegistrySerial(n, operatorsRegistry, log)
 
 	// store any state resulting from it
 	nextState := files.StoredState{
-		OwnerConfig:   s.OwnerConfig,
+		OwnerConfig:   owner,
 		SigningOutput: output,
 	}
 	bytes, err := files.StoreState(stateFilePath, nextState)
@@ -112,3 +190,53 @@ func Reshare(cmd *cobra.Command, _ []string) {
 	log.MaybeLog("📄 below is a keyfile JSON for use with the SSV UI:")
 	log.Log(string(j))
 }
+
+// parseReshareOwnerConfig parses the owner the validator will be registered to with the new operators,
+// defaulting to the owner in the state file. The same owner can't reuse a nonce from before the one in the state file
+func parseReshareOwnerConfig(previous api.OwnerConfig, validatorNonce int32, ethAddress string, sessionID []byte, transferSignature string, log shared.QuietLogger) (api.OwnerConfig, error) {
+	if ethAddress == "" {
+		ethAddress = hex.EncodeToString(previous.Address)
+	}
+	owner, err := parseOwnerConfig(validatorNonce, ethAddress)
+	if err != nil {
+		return api.OwnerConfig{}, err
+	}
+
+	if !bytes.Equal(owner.Address, previous.Address) {
+		owner.TransferSignature, err = parseOwnerTransferSignature(previous, owner, sessionID, transferSignature)
+		if err != nil {
+			return api.OwnerConfig{}, err
+		}
+		log.MaybeLog(fmt.Sprintf("🔀 the validator will be registered to the new owner %s", crypto.FormatAddress(owner.Address)))
+		return owner, nil
+	}
+	if transferSignature != "" {
+		return api.OwnerConfig{}, errors.New("an owner transfer signature is only needed when transferring the validator to a new owner")
+	}
+
+	switch {
+	case owner.ValidatorNonce < previous.ValidatorNonce:
+		return api.OwnerConfig{}, fmt.Errorf("validator nonce %d is lower than the nonce %d in the state file, so it has already been used", owner.ValidatorNonce, previous.ValidatorNonce)
+	case owner.ValidatorNonce == previous.ValidatorNonce:
+		log.MaybeLog(fmt.Sprintf("⚠️  validator nonce %d is the one in the state file; this only works if the validator was never registered with it", owner.ValidatorNonce))
+	}
+	return owner, nil
+}
+
+// parseOwnerTransferSignature checks the current owner has authorised transferring the validator to the new owner,
+// as the operators won't sign the new owner's validator nonce otherwise
+func parseOwnerTransferSignature(previous api.OwnerConfig, owner api.OwnerConfig, sessionID []byte, transferSignature string) ([]byte, error) {
+	if transferSignature == "" {
+		message := crypto.OwnerTransferMessage(sessionID, owner.Address, owner.ValidatorNonce)
+		return nil, fmt.Errorf("transferring the validator must be authorised by its owner %s. Sign this message with their wallet, e.g. `cast wallet sign \"%s\"`, and pass the signature with --owner-transfer-signature:\n%s",
+			crypto.FormatAddress(previous.Address), message, message)
+	}
+	signature, err := hex.DecodeString(strings.TrimPrefix(transferSignature, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("the owner transfer signature must be in hex format: %w", err)
+	}
+	if err := crypto.VerifyOwnerTransfer(previous.Address, sessionID, owner.Address, owner.ValidatorNonce, signature); err != nil {
+		return nil, err
+	}
+	return signature, nil
+}
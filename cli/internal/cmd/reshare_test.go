@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"crypto/ecdsa"
+	"encoding/hex"
+	"testing"
+
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+
+	"github.com/randa-mu/ssv-dkg/shared"
+	"github.com/randa-mu/ssv-dkg/shared/api"
+	"github.com/randa-mu/ssv-dkg/shared/crypto"
+)
+
+func TestParseReshareOwnerConfig(t *testing.T) {
+	log := shared.QuietLogger{Quiet: true}
+	address, err := hex.DecodeString("0a184b86b4cdb747f4a3bf6e6fcd5e27c1d92c50")
+	require.NoError(t, err)
+	newAddress, err := hex.DecodeString("aa184b86b4cdb747f4a3bf6e6fcd5e27c1d92c5c")
+	require.NoError(t, err)
+	previous := api.OwnerConfig{Address: address, ValidatorNonce: 3}
+
+	// the owner defaults to the one in the state file
+	owner, err := parseReshareOwnerConfig(previous, 4, "", nil, "", log)
+	require.NoError(t, err)
+	require.Equal(t, api.OwnerConfig{Address: address, ValidatorNonce: 4}, owner)
+
+	// reusing the nonce is allowed in case the validator was never registered
+	_, err = parseReshareOwnerConfig(previous, 3, "", nil, "", log)
+	require.NoError(t, err)
+
+	// but older nonces have definitely been used
+	_, err = parseReshareOwnerConfig(previous, 2, "", nil, "", log)
+	require.Error(t, err)
+
+	// the nonce must always be passed
+	_, err = parseReshareOwnerConfig(previous, -1, "", nil, "", log)
+	require.Error(t, err)
+
+	_, err = parseReshareOwnerConfig(previous, 0, "0xnothex", nil, "", log)
+	require.Error(t, err)
+
+	// an owner transfer signature is only for transfers
+	_, err = parseReshareOwnerConfig(previous, 4, "", nil, "0xdeadbeef", log)
+	require.Error(t, err)
+	_, err = parseReshareOwnerConfig(previous, 0, "0x"+hex.EncodeToString(newAddress), nil, "", log)
+	require.ErrorContains(t, err, "must be authorised by its owner")
+}
+
+func TestParseReshareOwnerConfigTransfer(t *testing.T) {
+	log := shared.QuietLogger{Quiet: true}
+	ownerKey, err := ethcrypto.GenerateKey()
+	require.NoError(t, err)
+	previous := api.OwnerConfig{Address: ethcrypto.PubkeyToAddress(ownerKey.PublicKey).Bytes(), ValidatorNonce: 3}
+	newAddress, err := hex.DecodeString("aa184b86b4cdb747f4a3bf6e6fcd5e27c1d92c5c")
+	require.NoError(t, err)
+	sessionID := []byte("session")
+	sign := func(key *ecdsa.PrivateKey, newOwner []byte, validatorNonce uint32) string {
+		signature, err := ethcrypto.Sign(crypto.OwnerTransferHash(sessionID, newOwner, validatorNonce), key)
+		require.NoError(t, err)
+		signature[ethcrypto.RecoveryIDOffset] += 27
+		return "0x" + hex.EncodeToString(signature)
+	}
+
+	// without the current owner's signature, we print the message they need to sign
+	_, err = parseReshareOwnerConfig(previous, 0, hex.EncodeToString(newAddress), sessionID, "", log)
+	require.ErrorContains(t, err, crypto.OwnerTransferMessage(sessionID, newAddress, 0))
+
+	// a new owner has its own nonces
+	signature := sign(ownerKey, newAddress, 0)
+	owner, err := parseReshareOwnerConfig(previous, 0, hex.EncodeToString(newAddress), sessionID, signature, log)
+	require.NoError(t, err)
+	require.Equal(t, newAddress, []byte(owner.Address))
+	require.Equal(t, uint32(0), owner.ValidatorNonce)
+	require.Equal(t, signature, "0x"+hex.EncodeToString(owner.TransferSignature))
+
+	// the signature only authorises the transfer it was made for
+	_, err = parseReshareOwnerConfig(previous, 1, hex.EncodeToString(newAddress), sessionID, signature, log)
+	require.Error(t, err)
+	_, err = parseReshareOwnerConfig(previous, 0, hex.EncodeToString(newAddress), []byte("other session"), signature, log)
+	require.Error(t, err)
+
+	// and must be made by the current owner
+	otherKey, err := ethcrypto.GenerateKey()
+	require.NoError(t, err)
+	_, err = parseReshareOwnerConfig(previous, 0, hex.EncodeToString(newAddress), sessionID, sign(otherKey, newAddress, 0), log)
+	require.ErrorContains(t, err, "not the validator's owner")
+
+	_, err = parseReshareOwnerConfig(previous, 0, hex.EncodeToString(newAddress), sessionID, "nothex", log)
+	require.Error(t, err)
+}
@@ -13,7 +13,7 @@ var rootCmd = &cobra.Command{
 }
 
 func init() {
-	rootCmd.AddCommand(versionCmd, operatorsCmd, signCmd, reshareCmd, printCmd)
+	rootCmd.AddCommand(versionCmd, operatorsCmd, signCmd, reshareCmd, refreshCmd, topUpCmd, printCmd)
 }
 
 func Execute() error {
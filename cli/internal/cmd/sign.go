@@ -7,13 +7,17 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"os"
 	"path"
+	"strconv"
 	"strings"
 
 	"github.com/randa-mu/ssv-dkg/shared/crypto"
 	"github.com/randa-mu/ssv-dkg/shared/files"
+	"github.com/randa-mu/ssv-dkg/shared/network"
 	"github.com/spf13/cobra"
+	"golang.org/x/exp/slices"
 
 	"github.com/randa-mu/ssv-dkg/cli"
 	"github.com/randa-mu/ssv-dkg/shared"
@@ -21,14 +25,23 @@ import (
 )
 
 var (
-	operatorFlag       []string
-	inputPathFlag      string
-	shortFlag          bool
-	stateDirectoryFlag string
-	validatorNonceFlag int32 = -1
-	ethAddressFlag     string
-	networkFlag        string
-	signCmd            = &cobra.Command{
+	operatorFlag          []string
+	operatorIDFlag        []uint
+	inputPathFlag         string
+	shortFlag             bool
+	stateDirectoryFlag    string
+	validatorNonceFlag    int32 = -1
+	ethAddressFlag        string
+	networkFlag           string
+	withdrawalAddrFlag    string
+	credentialTypeFlag    string
+	amountFlag            string
+	faultTolerantFlag     bool
+	spareOperatorFlag     []string
+	maxAttemptsFlag       int
+	registryFlag          string
+	requireSsvBindingFlag bool
+	signCmd               = &cobra.Command{
 		Use:   "sign",
 		Short: "Signs ETH deposit data by forming a validator cluster",
 		Long:  "Signs ETH deposit data by forming a validator cluster that creates a distributed key. Operators can be passed via stdin.",
@@ -42,7 +55,14 @@ func init() {
 		"operator",
 		"o",
 		nil,
-		"SSV DKG node operators you wish to sign your ETH deposit data",
+		"The URLs of SSV DKG node operators you wish to sign your ETH deposit data, e.g. for private operators not in the operators registry",
+	)
+
+	signCmd.PersistentFlags().UintSliceVar(
+		&operatorIDFlag,
+		"operator-id",
+		nil,
+		"The IDs of SSV DKG node operators in the operators registry you wish to sign your ETH deposit data",
 	)
 
 	signCmd.PersistentFlags().StringVarP(
@@ -50,7 +70,7 @@ func init() {
 		"deposit-file",
 		"f",
 		"",
-		"The filepath of the ETH deposit data",
+		"The filepath of the ETH deposit data. If omitted, deposit data is created from the withdrawal address, amount and network",
 	)
 	signCmd.PersistentFlags().StringVarP(
 		&stateDirectoryFlag,
@@ -86,7 +106,72 @@ func init() {
 		"network",
 		"N",
 		"mainnet",
-		"mainnet, hoodi or holesky",
+		"mainnet, hoodi, holesky or the path to a custom network file",
+	)
+
+	signCmd.PersistentFlags().StringVarP(
+		&withdrawalAddrFlag,
+		"withdrawal-address",
+		"w",
+		"",
+		"The ETH address in hex format that withdrawals will be sent to. Overrides the withdrawal credentials in the deposit file",
+	)
+
+	signCmd.PersistentFlags().StringVarP(
+		&credentialTypeFlag,
+		"credential-type",
+		"t",
+		"0x01",
+		"The type of withdrawal credentials to create for the withdrawal address: 0x01 or 0x02 (compounding)",
+	)
+
+	signCmd.PersistentFlags().StringVar(
+		&amountFlag,
+		"amount",
+		"",
+		"The amount of ETH to deposit, e.g. 32 or 64.5. Overrides the amount in the deposit file. Must be 32 ETH for 0x01 credentials or between 32 and 2048 ETH for 0x02",
+	)
+
+	signCmd.PersistentFlags().BoolVar(
+		&faultTolerantFlag,
+		"fault-tolerant",
+		false,
+		"Complete the DKG as long as a threshold of operators succeed. Operators that fail must be reshared in before the validator can be registered",
+	)
+
+	signCmd.PersistentFlags().StringArrayVar(
+		&spareOperatorFlag,
+		"spare-operator",
+		nil,
+		"The URLs or registry IDs of SSV DKG node operators that can replace failing operators if the DKG has to be retried",
+	)
+
+	signCmd.PersistentFlags().IntVar(
+		&maxAttemptsFlag,
+		"max-attempts",
+		3,
+		"The maximum number of times to run the DKG, replacing failing operators with spares between attempts",
+	)
+
+	signCmd.PersistentFlags().StringVar(
+		&registryFlag,
+		"registry",
+		"",
+		"The path or URL of the operators registry to check the operators' identities against before starting the DKG. Defaults to the registry of the network",
+	)
+
+	signCmd.PersistentFlags().BoolVar(
+		&skipRegistryCheckFlag,
+		"skip-registry-check",
+		false,
+		"Trust whatever identity each operator returns rather than checking it against the operators registry. Operator IDs are still resolved from the registry",
+	)
+
+	signCmd.PersistentFlags().BoolVar(
+		&requireSsvBindingFlag,
+		"require-ssv-binding",
+		false,
+		"Fail operators whose registry entry isn't signed by their SSV operator key, rather than only warning about them. Operators without a registry entry are only ever warned about. Only applies to networks with an SSV API",
 	)
 }
 
@@ -103,6 +188,9 @@ func Sign(cmd *cobra.Command, _ []string) {
 	if err != nil {
 		log.Fatalf("%v", err)
 	}
+	if n, err := network.Resolve(networkFlag); err == nil {
+		recordRegistrySerial(n, signingConfig.Registry, logger)
+	}
 
 	statePath := files.CreateFilename(stateDirectoryFlag, signingOutput, files.StateFileName)
 	depositDataPath := files.CreateFilename(stateDirectoryFlag, signingOutput, files.DepositDataFileName)
@@ -113,10 +201,6 @@ func Sign(cmd *cobra.Command, _ []string) {
 	if err != nil {
 		log.Fatalf("couldn't create signed deposit data: %v", err)
 	}
-	keyShareFile, err := files.CreateKeyshareFile(nextState.OwnerConfig, nextState.SigningOutput, signingConfig.SsvClient)
-	if err != nil {
-		log.Fatalf("couldn't create keyshare file: %v", err)
-	}
 
 	errored := false
 	bytes, err := files.StoreStateIfNotExists(statePath, nextState)
@@ -131,6 +215,20 @@ func Sign(cmd *cobra.Command, _ []string) {
 		logger.Log(fmt.Sprintf("⚠️  DKG was successful but there was an error storing the deposit data; you should store it somewhere for resharing. Error: %v", err))
 		logger.Log(string(bytes))
 	}
+
+	// SSV needs a key share for every operator to register the validator, so if some are missing
+	// there's no point creating the keyshares file until they've been reshared in
+	if len(signingOutput.MissingOperators) > 0 {
+		if !errored {
+			logger.Log(fmt.Sprintf("⚠️  your state and signed deposit data have been stored to %s, but %d operators have no key share. Reshare them in with `ssv-dkg reshare` to create the keyshares file", path.Join(stateDirectoryFlag, hex.EncodeToString(signingOutput.SessionID)), len(signingOutput.MissingOperators)))
+		}
+		return
+	}
+
+	keyShareFile, err := files.CreateKeyshareFile(nextState.OwnerConfig, nextState.SigningOutput, signingConfig.SsvClient)
+	if err != nil {
+		log.Fatalf("couldn't create keyshare file: %v", err)
+	}
 	bytes, err = files.StoreStateIfNotExists(keySharePath, keyShareFile)
 	if err != nil {
 		errored = true
@@ -145,45 +243,85 @@ func Sign(cmd *cobra.Command, _ []string) {
 }
 
 func parseArgs(cmd *cobra.Command) (api.SignatureConfig, error) {
-	// if the operator flag isn't passed, we consume operator addresses from stdin
-	operators, err := parseOperators(operatorFlag, cmd.InOrStdin())
+	// if the operator flags aren't passed, we consume operator addresses or IDs from stdin
+	operatorTokens, err := parseOperators(operatorFlag, operatorIDFlag, cmd.InOrStdin())
+	if err != nil {
+		return api.SignatureConfig{}, fmt.Errorf("error parsing the operators: %v", err)
+	}
+
+	n, err := network.Resolve(networkFlag)
+	if err != nil {
+		return api.SignatureConfig{}, err
+	}
+
+	operatorsRegistry, err := loadRegistry(n, registryFlag, skipRegistryCheckFlag, shared.QuietLogger{Quiet: shortFlag})
+	if err != nil {
+		return api.SignatureConfig{}, err
+	}
+	operators, err := resolveOperators(operatorTokens, operatorsRegistry)
 	if err != nil {
 		return api.SignatureConfig{}, fmt.Errorf("error parsing the operators: %v", err)
 	}
+	spareOperators, err := resolveOperators(spareOperatorFlag, operatorsRegistry)
+	if err != nil {
+		return api.SignatureConfig{}, fmt.Errorf("error parsing the spare operators: %v", err)
+	}
+	operatorsRegistry = pinningRegistry(operatorsRegistry, skipRegistryCheckFlag, append(slices.Clone(operators), spareOperators...), shared.QuietLogger{Quiet: shortFlag})
+
+	var depositData api.UnsignedDepositData
+	if inputPathFlag != "" {
+		depositData, err = parseUnsignedInputData(inputPathFlag, stateDirectoryFlag)
+	} else {
+		depositData, err = createUnsignedDepositData(n, withdrawalAddrFlag)
+	}
+	if err != nil {
+		return api.SignatureConfig{}, fmt.Errorf("error parsing deposit data: %v", err)
+	}
 
-	depositData, err := parseUnsignedInputData(inputPathFlag, stateDirectoryFlag)
+	depositData, err = applyDepositOverrides(depositData, withdrawalAddrFlag, credentialTypeFlag, amountFlag)
 	if err != nil {
 		return api.SignatureConfig{}, fmt.Errorf("error parsing deposit data: %v", err)
 	}
 
+	if err = depositData.Validate(); err != nil {
+		return api.SignatureConfig{}, fmt.Errorf("invalid deposit data: %v", err)
+	}
+
 	ownerConfig, err := parseOwnerConfig(validatorNonceFlag, ethAddressFlag)
 	if err != nil {
 		return api.SignatureConfig{}, fmt.Errorf("error parsing owner details: %v", err)
 	}
 
-	var ssvClient api.SsvClient
-	if networkFlag == "mainnet" {
-		ssvClient = api.MainnetSsvClient()
-	} else if networkFlag == "holesky" {
-		ssvClient = api.HoleskySsvClient()
-	} else if networkFlag == "hoodi" {
-		ssvClient = api.HoodiSsvClient()
-	} else {
-		return api.SignatureConfig{}, fmt.Errorf("network must be either mainnet, hoodi or holesky")
+	if maxAttemptsFlag < 1 {
+		return api.SignatureConfig{}, errors.New("max attempts must be at least 1")
+	}
+	for _, spare := range spareOperators {
+		if slices.Contains(operators, spare) {
+			return api.SignatureConfig{}, fmt.Errorf("spare operator %s is already one of the operators", spare)
+		}
 	}
 
 	return api.SignatureConfig{
-		Operators:   operators,
-		DepositData: depositData,
-		Owner:       ownerConfig,
-		SsvClient:   ssvClient,
+		Operators:         operators,
+		DepositData:       depositData,
+		Owner:             ownerConfig,
+		SsvClient:         api.NewSsvClient(n.SsvApiUrl),
+		FaultTolerant:     faultTolerantFlag,
+		SpareOperators:    spareOperators,
+		MaxAttempts:       maxAttemptsFlag,
+		Registry:          operatorsRegistry,
+		RequireSsvBinding: requireSsvBindingFlag,
 	}, nil
 }
 
-// parseOperators returns the array if it's non-empty, or reads an array of strings from the provided `Reader` if it's empty
-func parseOperators(arr []string, r io.Reader) ([]string, error) {
-	if len(arr) != 0 {
-		return arr, nil
+// parseOperators returns the operator addresses and IDs passed as flags, or reads them from the provided `Reader` if none were passed
+func parseOperators(arr []string, ids []uint, r io.Reader) ([]string, error) {
+	if len(arr) != 0 || len(ids) != 0 {
+		operators := slices.Clone(arr)
+		for _, id := range ids {
+			operators = append(operators, strconv.FormatUint(uint64(id), 10))
+		}
+		return operators, nil
 	}
 
 	bytes, err := io.ReadAll(r)
@@ -191,12 +329,29 @@ func parseOperators(arr []string, r io.Reader) ([]string, error) {
 		return nil, err
 	}
 
-	lines := strings.Trim(string(bytes), "\n")
-	if lines == "" {
+	// operators may be separated by spaces or newlines, e.g. when piped through `head`
+	operators := strings.Fields(string(bytes))
+	if len(operators) == 0 {
 		return nil, errors.New("reader was empty")
 	}
 
-	return shared.Uniq(strings.Split(lines, " ")), nil
+	return shared.Uniq(operators), nil
+}
+
+// createUnsignedDepositData creates deposit data for a new validator on the given network, so users don't need
+// to create a deposit file with a third-party tool. The withdrawal credentials and amount are applied afterwards
+// from the flags
+func createUnsignedDepositData(n network.Network, withdrawalAddress string) (api.UnsignedDepositData, error) {
+	if withdrawalAddress == "" {
+		return api.UnsignedDepositData{}, errors.New("you must pass either a deposit file or a withdrawal address")
+	}
+
+	return api.UnsignedDepositData{
+		Amount:            crypto.MinActivationBalance,
+		ForkVersion:       n.GenesisForkVersion,
+		NetworkName:       n.Name,
+		DepositCLIVersion: depositCLIVersion,
+	}, nil
 }
 
 func parseUnsignedInputData(inputPathFlag string, stateDirectory string) (api.UnsignedDepositData, error) {
@@ -229,7 +384,7 @@ func parseOwnerConfig(validatorNonce int32, ethAddress string) (api.OwnerConfig,
 	}
 
 	// remove any preceding `0x` before parsing it
-	cleanAddress := strings.Trim(ethAddress, "0x")
+	cleanAddress := strings.TrimPrefix(ethAddress, "0x")
 	if cleanAddress == "" {
 		return api.OwnerConfig{}, fmt.Errorf("owner address cannot be empty")
 	}
@@ -244,3 +399,81 @@ func parseOwnerConfig(validatorNonce int32, ethAddress string) (api.OwnerConfig,
 		ValidatorNonce: uint32(validatorNonce),
 	}, nil
 }
+
+// applyDepositOverrides replaces the withdrawal credentials and amount of the deposit data
+// with those passed as flags, if any
+func applyDepositOverrides(depositData api.UnsignedDepositData, withdrawalAddress string, credentialType string, amount string) (api.UnsignedDepositData, error) {
+	if withdrawalAddress != "" {
+		prefix, err := parseCredentialType(credentialType)
+		if err != nil {
+			return api.UnsignedDepositData{}, err
+		}
+
+		address, err := parseAddress(withdrawalAddress)
+		if err != nil {
+			return api.UnsignedDepositData{}, fmt.Errorf("invalid withdrawal address: %v", err)
+		}
+
+		credentials, err := crypto.NewWithdrawalCredentials(prefix, address)
+		if err != nil {
+			return api.UnsignedDepositData{}, err
+		}
+		depositData.WithdrawalCredentials = credentials
+	}
+
+	if amount != "" {
+		gwei, err := parseEthAmount(amount)
+		if err != nil {
+			return api.UnsignedDepositData{}, err
+		}
+		depositData.Amount = gwei
+	}
+
+	return depositData, nil
+}
+
+func parseCredentialType(credentialType string) (byte, error) {
+	switch credentialType {
+	case "0x01", "01":
+		return crypto.ExecutionWithdrawalPrefix, nil
+	case "0x02", "02":
+		return crypto.CompoundingWithdrawalPrefix, nil
+	default:
+		return 0, fmt.Errorf("credential type must be either 0x01 or 0x02, got %s", credentialType)
+	}
+}
+
+// parseAddress parses a hex encoded 20 byte ETH address, with or without the `0x` prefix
+func parseAddress(input string) ([]byte, error) {
+	address, err := hex.DecodeString(strings.TrimPrefix(input, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("address must be valid hex: %v", err)
+	}
+	if len(address) != 20 {
+		return nil, fmt.Errorf("address must be 20 bytes, got %d", len(address))
+	}
+	return address, nil
+}
+
+// parseEthAmount converts a decimal amount of ETH into gwei
+func parseEthAmount(amount string) (uint64, error) {
+	whole, fraction, _ := strings.Cut(amount, ".")
+	if len(fraction) > 9 {
+		return 0, fmt.Errorf("amount %s has more precision than 1 gwei", amount)
+	}
+	fraction += strings.Repeat("0", 9-len(fraction))
+
+	eth, err := strconv.ParseUint(whole, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %s: %v", amount, err)
+	}
+	gwei, err := strconv.ParseUint(fraction, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %s: %v", amount, err)
+	}
+	if eth > math.MaxUint64/crypto.GweiPerEth {
+		return 0, fmt.Errorf("amount %s is too large", amount)
+	}
+
+	return eth*crypto.GweiPerEth + gwei, nil
+}
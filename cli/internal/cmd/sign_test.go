@@ -1,21 +1,53 @@
 package cmd
 
 import (
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"os"
 	"path"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
 	"github.com/randa-mu/ssv-dkg/shared/api"
+	"github.com/randa-mu/ssv-dkg/shared/crypto"
+	"github.com/randa-mu/ssv-dkg/shared/network"
+	"github.com/randa-mu/ssv-dkg/shared/registry"
 )
 
 func TestSignCommand(t *testing.T) {
 	tmp := t.TempDir()
 	filepath := path.Join(tmp, "testfile")
 	createdUnsignedDepositData(t, filepath)
+	operators := `[
+		{"operator_id": 1, "address": "http://127.0.0.1:8081", "public": "aGVsbG8", "signature": "d29ybGQ"},
+		{"operator_id": 2, "address": "http://127.0.0.1:8082", "public": "aGVsbG8", "signature": "d29ybGQ"},
+		{"operator_id": 3, "address": "http://127.0.0.1:8083", "public": "aGVsbG8", "signature": "d29ybGQ"}
+	]`
+	maintainer, err := crypto.NewBLSSuite().CreateKeypair()
+	require.NoError(t, err)
+	networkPath := path.Join(tmp, "devnet.json")
+	require.NoError(t, os.WriteFile(networkPath, []byte(fmt.Sprintf(`{
+		"name": "devnet",
+		"genesis_fork_version": "10000001",
+		"genesis_validators_root": "212f13fc4df078b6cb7db228f1c8307566dcecf900867401a92023d7ba99cb5f",
+		"ssv_api_url": "http://127.0.0.1:3000/api/v4/devnet",
+		"registry_maintainers": {"keys": ["%x"], "threshold": 1}
+	}`, maintainer.Public)), 0o644))
+	registryPath := path.Join(tmp, "operators.json")
+	writeSignedRegistry(t, registryPath, 2, operators, maintainer)
+	oldRegistryPath := path.Join(tmp, "old-operators.json")
+	writeSignedRegistry(t, oldRegistryPath, 1, operators, maintainer)
+	unsignedRegistryPath := path.Join(tmp, "unsigned-operators.json")
+	require.NoError(t, os.WriteFile(unsignedRegistryPath, []byte(fmt.Sprintf(`{"operators": %s}`, operators)), 0o644))
+	registrySerialsPath = path.Join(tmp, "registry-serials.json")
+	require.NoError(t, registry.RecordSerial(registrySerialsPath, "devnet", registry.Registry{Serial: 2}))
+	t.Cleanup(func() {
+		registrySerialsPath = defaultRegistrySerialsPath()
+	})
 
 	tests := []struct {
 		name        string
@@ -29,6 +61,8 @@ func TestSignCommand(t *testing.T) {
 			args: []string{
 				"ssv-dkg",
 				"sign",
+				"--registry", registryPath,
+				"--network", networkPath,
 				"--deposit-file", filepath,
 				"--output", filepath,
 				"--validator-nonce", "1",
@@ -44,6 +78,8 @@ func TestSignCommand(t *testing.T) {
 			args: []string{
 				"ssv-dkg",
 				"sign",
+				"--registry", registryPath,
+				"--network", networkPath,
 				"--deposit-file", filepath,
 				"--output", filepath,
 				"--validator-nonce", "1",
@@ -137,6 +173,326 @@ func TestSignCommand(t *testing.T) {
 				"--operator", "http://127.0.0.1:8083",
 			},
 		},
+		{
+			name:        "no deposit file with withdrawal address succeeds",
+			shouldError: false,
+			args: []string{
+				"ssv-dkg",
+				"sign",
+				"--registry", registryPath,
+				"--network", networkPath,
+				"--output", filepath,
+				"--validator-nonce", "1",
+				"--owner-address", "0xdeadbeef",
+				"--withdrawal-address", "0xaA184b86B4cdb747F4A3BF6e6FCd5e27c1d92c5c",
+				"--operator", "http://127.0.0.1:8081",
+			},
+		},
+		{
+			name:        "no deposit file with unknown network returns error",
+			shouldError: true,
+			args: []string{
+				"ssv-dkg",
+				"sign",
+				"--output", filepath,
+				"--validator-nonce", "1",
+				"--owner-address", "0xdeadbeef",
+				"--withdrawal-address", "0xaA184b86B4cdb747F4A3BF6e6FCd5e27c1d92c5c",
+				"--network", "sepolia",
+				"--operator", "http://127.0.0.1:8081",
+			},
+		},
+		{
+			name:        "compounding credentials with custom amount succeeds",
+			shouldError: false,
+			args: []string{
+				"ssv-dkg",
+				"sign",
+				"--registry", registryPath,
+				"--network", networkPath,
+				"--deposit-file", filepath,
+				"--output", filepath,
+				"--validator-nonce", "1",
+				"--owner-address", "0xdeadbeef",
+				"--withdrawal-address", "0xaA184b86B4cdb747F4A3BF6e6FCd5e27c1d92c5c",
+				"--credential-type", "0x02",
+				"--amount", "100.5",
+				"--operator", "http://127.0.0.1:8081",
+			},
+		},
+		{
+			name:        "more than 32 ETH for 0x01 credentials returns error",
+			shouldError: true,
+			args: []string{
+				"ssv-dkg",
+				"sign",
+				"--deposit-file", filepath,
+				"--output", filepath,
+				"--validator-nonce", "1",
+				"--owner-address", "0xdeadbeef",
+				"--withdrawal-address", "0xaA184b86B4cdb747F4A3BF6e6FCd5e27c1d92c5c",
+				"--credential-type", "0x01",
+				"--amount", "64",
+				"--operator", "http://127.0.0.1:8081",
+			},
+		},
+		{
+			name:        "more than 2048 ETH for 0x02 credentials returns error",
+			shouldError: true,
+			args: []string{
+				"ssv-dkg",
+				"sign",
+				"--deposit-file", filepath,
+				"--output", filepath,
+				"--validator-nonce", "1",
+				"--owner-address", "0xdeadbeef",
+				"--withdrawal-address", "0xaA184b86B4cdb747F4A3BF6e6FCd5e27c1d92c5c",
+				"--credential-type", "0x02",
+				"--amount", "2048.000000001",
+				"--operator", "http://127.0.0.1:8081",
+			},
+		},
+		{
+			name:        "less than 32 ETH returns error",
+			shouldError: true,
+			args: []string{
+				"ssv-dkg",
+				"sign",
+				"--deposit-file", filepath,
+				"--output", filepath,
+				"--validator-nonce", "1",
+				"--owner-address", "0xdeadbeef",
+				"--amount", "31",
+				"--operator", "http://127.0.0.1:8081",
+			},
+		},
+		{
+			name:        "unknown credential type returns error",
+			shouldError: true,
+			args: []string{
+				"ssv-dkg",
+				"sign",
+				"--deposit-file", filepath,
+				"--output", filepath,
+				"--validator-nonce", "1",
+				"--owner-address", "0xdeadbeef",
+				"--withdrawal-address", "0xaA184b86B4cdb747F4A3BF6e6FCd5e27c1d92c5c",
+				"--credential-type", "0x03",
+				"--operator", "http://127.0.0.1:8081",
+			},
+		},
+		{
+			name:        "fault tolerant flag is accepted",
+			shouldError: false,
+			args: []string{
+				"ssv-dkg",
+				"sign",
+				"--registry", registryPath,
+				"--network", networkPath,
+				"--deposit-file", filepath,
+				"--output", filepath,
+				"--validator-nonce", "1",
+				"--owner-address", "0xdeadbeef",
+				"--fault-tolerant",
+				"--operator", "http://127.0.0.1:8081",
+			},
+		},
+		{
+			name:        "spare operators are accepted",
+			shouldError: false,
+			args: []string{
+				"ssv-dkg",
+				"sign",
+				"--registry", registryPath,
+				"--network", networkPath,
+				"--deposit-file", filepath,
+				"--output", filepath,
+				"--validator-nonce", "1",
+				"--owner-address", "0xdeadbeef",
+				"--operator", "http://127.0.0.1:8081",
+				"--spare-operator", "http://127.0.0.1:8082",
+				"--max-attempts", "2",
+			},
+		},
+		{
+			name:        "spare operator that is already an operator returns error",
+			shouldError: true,
+			args: []string{
+				"ssv-dkg",
+				"sign",
+				"--deposit-file", filepath,
+				"--output", filepath,
+				"--validator-nonce", "1",
+				"--owner-address", "0xdeadbeef",
+				"--operator", "http://127.0.0.1:8081",
+				"--spare-operator", "http://127.0.0.1:8081",
+			},
+		},
+		{
+			name:        "zero max attempts returns error",
+			shouldError: true,
+			args: []string{
+				"ssv-dkg",
+				"sign",
+				"--deposit-file", filepath,
+				"--output", filepath,
+				"--validator-nonce", "1",
+				"--owner-address", "0xdeadbeef",
+				"--operator", "http://127.0.0.1:8081",
+				"--max-attempts", "0",
+			},
+		},
+		{
+			name:        "missing registry file returns error",
+			shouldError: true,
+			args: []string{
+				"ssv-dkg",
+				"sign",
+				"--deposit-file", filepath,
+				"--output", filepath,
+				"--validator-nonce", "1",
+				"--owner-address", "0xdeadbeef",
+				"--operator", "http://127.0.0.1:8081",
+				"--registry", "/definitely/not/a/registry.json",
+			},
+		},
+		{
+			name:        "unsigned registry for a network with registry maintainers returns error",
+			shouldError: true,
+			args: []string{
+				"ssv-dkg",
+				"sign",
+				"--registry", unsignedRegistryPath,
+				"--network", networkPath,
+				"--deposit-file", filepath,
+				"--output", filepath,
+				"--validator-nonce", "1",
+				"--owner-address", "0xdeadbeef",
+				"--operator", "http://127.0.0.1:8081",
+			},
+		},
+		{
+			name:        "registry older than the last one used returns error",
+			shouldError: true,
+			args: []string{
+				"ssv-dkg",
+				"sign",
+				"--registry", oldRegistryPath,
+				"--network", networkPath,
+				"--deposit-file", filepath,
+				"--output", filepath,
+				"--validator-nonce", "1",
+				"--owner-address", "0xdeadbeef",
+				"--operator", "http://127.0.0.1:8081",
+			},
+		},
+		{
+			name:        "skipping the registry check succeeds",
+			shouldError: false,
+			args: []string{
+				"ssv-dkg",
+				"sign",
+				"--deposit-file", filepath,
+				"--output", filepath,
+				"--validator-nonce", "1",
+				"--owner-address", "0xdeadbeef",
+				"--operator", "http://127.0.0.1:8081",
+				"--skip-registry-check",
+			},
+		},
+		{
+			name:        "skipping the registry check still resolves operator IDs from the registry",
+			shouldError: false,
+			args: []string{
+				"ssv-dkg",
+				"sign",
+				"--deposit-file", filepath,
+				"--output", filepath,
+				"--validator-nonce", "1",
+				"--owner-address", "0xdeadbeef",
+				"--operator-id", "1",
+				"--operator", "http://127.0.0.1:8084",
+				"--registry", registryPath,
+				"--network", networkPath,
+				"--skip-registry-check",
+			},
+		},
+		{
+			name:        "operator IDs are resolved from the registry",
+			shouldError: false,
+			args: []string{
+				"ssv-dkg",
+				"sign",
+				"--registry", registryPath,
+				"--network", networkPath,
+				"--deposit-file", filepath,
+				"--output", filepath,
+				"--validator-nonce", "1",
+				"--owner-address", "0xdeadbeef",
+				"--operator-id", "1",
+				"--operator-id", "2",
+				"--operator", "http://127.0.0.1:8084",
+			},
+		},
+		{
+			name:        "operator IDs from stdin are resolved from the registry",
+			shouldError: false,
+			args: []string{
+				"ssv-dkg",
+				"sign",
+				"--registry", registryPath,
+				"--network", networkPath,
+				"--deposit-file", filepath,
+				"--output", filepath,
+				"--validator-nonce", "1",
+				"--owner-address", "0xdeadbeef",
+			},
+			stdin: strings.NewReader("1\n2\n3\n"),
+		},
+		{
+			name:        "operator ID missing from the registry returns error",
+			shouldError: true,
+			args: []string{
+				"ssv-dkg",
+				"sign",
+				"--registry", registryPath,
+				"--network", networkPath,
+				"--deposit-file", filepath,
+				"--output", filepath,
+				"--validator-nonce", "1",
+				"--owner-address", "0xdeadbeef",
+				"--operator-id", "4",
+			},
+		},
+		{
+			name:        "operator ID without a registry returns error",
+			shouldError: true,
+			args: []string{
+				"ssv-dkg",
+				"sign",
+				"--skip-registry-check",
+				"--registry", "/definitely/not/a/registry.json",
+				"--deposit-file", filepath,
+				"--output", filepath,
+				"--validator-nonce", "1",
+				"--owner-address", "0xdeadbeef",
+				"--operator-id", "1",
+			},
+		},
+		{
+			name:        "short withdrawal address returns error",
+			shouldError: true,
+			args: []string{
+				"ssv-dkg",
+				"sign",
+				"--deposit-file", filepath,
+				"--output", filepath,
+				"--validator-nonce", "1",
+				"--owner-address", "0xdeadbeef",
+				"--withdrawal-address", "0xdeadbeef",
+				"--operator", "http://127.0.0.1:8081",
+			},
+		},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
@@ -151,11 +507,22 @@ func TestSignCommand(t *testing.T) {
 
 			t.Cleanup(func() {
 				operatorFlag = nil
+				operatorIDFlag = nil
 				inputPathFlag = ""
 				shortFlag = false
 				stateDirectoryFlag = ""
 				ethAddressFlag = ""
 				validatorNonceFlag = -1
+				withdrawalAddrFlag = ""
+				credentialTypeFlag = "0x01"
+				amountFlag = ""
+				networkFlag = "mainnet"
+				faultTolerantFlag = false
+				spareOperatorFlag = nil
+				maxAttemptsFlag = 3
+				registryFlag = ""
+				skipRegistryCheckFlag = false
+				requireSsvBindingFlag = true
 			})
 			if test.shouldError && err == nil {
 				t.Fatalf("expected err but got nil")
@@ -166,12 +533,67 @@ func TestSignCommand(t *testing.T) {
 	}
 }
 
+func TestParseEthAmount(t *testing.T) {
+	tests := []struct {
+		input       string
+		expected    uint64
+		shouldError bool
+	}{
+		{input: "32", expected: 32000000000},
+		{input: "32.5", expected: 32500000000},
+		{input: "2048.000000001", expected: 2048000000001},
+		{input: "0.000000001", expected: 1},
+		{input: "1.0000000001", shouldError: true},
+		{input: "-1", shouldError: true},
+		{input: "abc", shouldError: true},
+		{input: "99999999999999999999", shouldError: true},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			amount, err := parseEthAmount(test.input)
+			if test.shouldError {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, test.expected, amount)
+			}
+		})
+	}
+}
+
+func TestCreateUnsignedDepositData(t *testing.T) {
+	depositData, err := createUnsignedDepositData(network.Holesky, "0xaA184b86B4cdb747F4A3BF6e6FCd5e27c1d92c5c")
+	require.NoError(t, err)
+	depositData, err = applyDepositOverrides(depositData, "0xaA184b86B4cdb747F4A3BF6e6FCd5e27c1d92c5c", "0x01", "")
+	require.NoError(t, err)
+	require.NoError(t, depositData.Validate())
+
+	require.Equal(t, "010000000000000000000000aa184b86b4cdb747f4a3bf6e6fcd5e27c1d92c5c", hex.EncodeToString(depositData.WithdrawalCredentials))
+	require.Equal(t, uint64(32000000000), depositData.Amount)
+	require.Equal(t, []byte{0x01, 0x01, 0x70, 0x00}, []byte(depositData.ForkVersion))
+	require.Equal(t, "holesky", depositData.NetworkName)
+	require.NotEmpty(t, depositData.DepositCLIVersion)
+
+	_, err = createUnsignedDepositData(network.Holesky, "")
+	require.Error(t, err)
+}
+
+func writeSignedRegistry(t *testing.T, registryPath string, serial uint64, operators string, maintainer crypto.Keypair) {
+	contents := []byte(fmt.Sprintf(`{"serial": %d, "expiry": "%s", "operators": %s}`, serial, time.Now().Add(time.Hour).UTC().Format(time.RFC3339), operators))
+	require.NoError(t, os.WriteFile(registryPath, contents, 0o644))
+	signature, err := registry.Sign(crypto.NewBLSSuite(), maintainer, contents)
+	require.NoError(t, err)
+	signatures, err := json.Marshal(registry.SignatureFile{Signatures: []registry.MaintainerSignature{signature}})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(registryPath+registry.SignatureFileSuffix, signatures, 0o644))
+}
+
 func createdUnsignedDepositData(t *testing.T, filepath string) {
 	data := []api.UnsignedDepositData{
 
 		{
-			WithdrawalCredentials: []byte("hello worldhello worldhello worl"), // must be 32 bytes
-			Amount:                1,
+			WithdrawalCredentials: []byte("\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00hello worldhello wor"), // must be 32 bytes
+			Amount:                32000000000,
 			ForkVersion:           []byte{0x01, 0x02, 0x03, 0x04},
 			NetworkName:           "somenetwork",
 			DepositCLIVersion:     "somecli",
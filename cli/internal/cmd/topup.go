@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"path"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/randa-mu/ssv-dkg/cli"
+	"github.com/randa-mu/ssv-dkg/shared"
+	"github.com/randa-mu/ssv-dkg/shared/api"
+	"github.com/randa-mu/ssv-dkg/shared/crypto"
+	"github.com/randa-mu/ssv-dkg/shared/files"
+)
+
+var topUpCmd = &cobra.Command{
+	Use:   "topup",
+	Short: "Signs a deposit adding stake to a validator cluster you have already created",
+	Long:  "Signs a deposit adding stake to a validator cluster you have already created, using the operators' existing key shares rather than running a new DKG.",
+	Run:   TopUp,
+}
+
+func init() {
+	topUpCmd.PersistentFlags().StringVarP(
+		&stateFilePath,
+		"state",
+		"s",
+		"",
+		"The filepath of the state created by the distributed key generation for the validator cluster you wish to top up",
+	)
+
+	topUpCmd.PersistentFlags().StringVarP(
+		&inputPathFlag,
+		"deposit-file",
+		"f",
+		"",
+		"The filepath of the validator's deposit data, used for its withdrawal credentials and fork version. Defaults to the signed deposit data stored next to the state file",
+	)
+
+	topUpCmd.PersistentFlags().StringVar(
+		&amountFlag,
+		"amount",
+		"",
+		"The amount of ETH to add to the validator, e.g. 1 or 32.5. It can be at most 32 ETH for validators with 0x01 withdrawal credentials, as anything above that is swept",
+	)
+
+	topUpCmd.PersistentFlags().BoolVarP(
+		&shortFlag,
+		"quiet",
+		"q",
+		false,
+		"Only print out the signed deposit data",
+	)
+}
+
+func TopUp(_ *cobra.Command, _ []string) {
+	if stateFilePath == "" {
+		log.Fatal("you must enter the path to the state created from the initial distributed key generation")
+	}
+	if amountFlag == "" {
+		log.Fatal("you must enter the amount of ETH you wish to deposit")
+	}
+
+	depositFilePath := inputPathFlag
+	if depositFilePath == "" {
+		depositFilePath = path.Join(filepath.Dir(stateFilePath), files.DepositDataFileName)
+	}
+
+	depositData, err := parseUnsignedInputData(depositFilePath, filepath.Dir(stateFilePath))
+	if err != nil {
+		log.Fatalf("❌ error parsing deposit data: %v", err)
+	}
+	depositData, err = applyDepositOverrides(depositData, "", "", amountFlag)
+	if err != nil {
+		log.Fatalf("❌ error parsing deposit data: %v", err)
+	}
+
+	s, err := files.LoadState(stateFilePath)
+	if err != nil {
+		log.Fatalf("❌ tried to load state from %s but it failed: %v", stateFilePath, err)
+	}
+
+	logger := shared.QuietLogger{Quiet: shortFlag}
+	output, err := cli.TopUp(depositData, s.SigningOutput, logger)
+	if err != nil {
+		log.Fatalf("❌ top-up failed: %v", err)
+	}
+
+	signedDepositData, err := files.CreateSignedDepositData(crypto.NewBLSSuite(), api.SignatureConfig{DepositData: depositData}, output)
+	if err != nil {
+		log.Fatalf("couldn't create signed deposit data: %v", err)
+	}
+
+	topUpPath := path.Join(filepath.Dir(stateFilePath), files.TopUpDepositDataFileName())
+	bytes, err := files.StoreStateIfNotExists(topUpPath, signedDepositData)
+	if err != nil {
+		logger.Log(fmt.Sprintf("⚠️  top-up was successful but there was an error storing the deposit data. Error: %v", err))
+		logger.Log(string(bytes))
+		return
+	}
+
+	logger.MaybeLog(fmt.Sprintf("✅ your signed top-up deposit data has been stored to %s", topUpPath))
+	if shortFlag {
+		j, err := json.Marshal(signedDepositData)
+		if err != nil {
+			log.Fatalf("couldn't turn the deposit data into json: %v", err)
+		}
+		logger.Log(string(j))
+	}
+}
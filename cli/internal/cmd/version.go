@@ -1,3 +1,5 @@
 package cmd
 
-var VERSION = "v0.0.1"
+import "github.com/randa-mu/ssv-dkg/shared"
+
+var VERSION = shared.Version
@@ -0,0 +1,327 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"golang.org/x/exp/slices"
+
+	"github.com/randa-mu/ssv-dkg/shared"
+	"github.com/randa-mu/ssv-dkg/shared/api"
+	"github.com/randa-mu/ssv-dkg/shared/crypto"
+	"github.com/randa-mu/ssv-dkg/shared/registry"
+)
+
+// MaxClockSkew is how far an operator's clock may drift from ours before we refuse to start a DKG.
+// The DKG phases are timed, so operators with skewed clocks can miss each other's packets
+const MaxClockSkew = 30 * time.Second
+
+// preflightTimeout is how long we wait for an operator to answer each of the health and identity probes,
+// so an unreachable operator fails its checks rather than hanging the preflight
+const preflightTimeout = 10 * time.Second
+
+const (
+	healthCheck   = "health"
+	versionCheck  = "version"
+	networkCheck  = "network"
+	clockCheck    = "clock"
+	identityCheck = "identity"
+	protocolCheck = "protocol"
+	ssvCheck      = "ssv"
+	registryCheck = "registry"
+)
+
+// preflightChecks are the checks run against every operator, in the order they're run and displayed
+var preflightChecks = []string{healthCheck, versionCheck, networkCheck, clockCheck, identityCheck, protocolCheck, ssvCheck, registryCheck}
+
+type PreflightCheck struct {
+	Name    string
+	Skipped bool
+	Err     error
+	// Warning is set for checks that pass, but with a problem the user should know about
+	Warning error
+}
+
+type PreflightResult struct {
+	Operator string
+	Identity crypto.Identity
+	// the highest protocol version the operator speaks
+	ProtocolVersion uint32
+	Checks          []PreflightCheck
+}
+
+func (p PreflightResult) Failed() bool {
+	for _, c := range p.Checks {
+		if c.Err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Warned returns whether any check passed with a warning
+func (p PreflightResult) Warned() bool {
+	return slices.ContainsFunc(p.Checks, func(c PreflightCheck) bool { return c.Warning != nil })
+}
+
+// PreflightError is returned when any operator fails any of the preflight checks
+type PreflightError struct {
+	Results []PreflightResult
+}
+
+func (p PreflightError) Error() string {
+	return fmt.Sprintf("preflight checks failed:\n%s", FormatPreflightResults(p.Results))
+}
+
+// FailingOperators returns the addresses of every operator that failed a check
+func (p PreflightError) FailingOperators() []string {
+	var failing []string
+	for _, r := range p.Results {
+		if r.Failed() {
+			failing = append(failing, r.Operator)
+		}
+	}
+	return failing
+}
+
+// preflight checks every operator is healthy, compatible with us and the deposit we want to sign,
+// and is who it claims to be, before we start a DKG with them.
+// It returns the operators' identities and the highest protocol version they all speak if every check passes,
+// printing the results if any passed with a warning
+func preflight(suite crypto.ThresholdScheme, config api.SignatureConfig, log shared.QuietLogger) ([]crypto.Identity, uint32, error) {
+	results := make([]PreflightResult, len(config.Operators))
+	wg := sync.WaitGroup{}
+	wg.Add(len(config.Operators))
+	for i, operator := range config.Operators {
+		go func(i int, operator string) {
+			defer wg.Done()
+			results[i] = preflightOperator(suite, operator, config)
+		}(i, operator)
+	}
+	wg.Wait()
+
+	identities := make([]crypto.Identity, len(results))
+	versions := make([]uint32, len(results))
+	failed := false
+	warned := false
+	for i, r := range results {
+		identities[i] = r.Identity
+		versions[i] = r.ProtocolVersion
+		failed = failed || r.Failed()
+		warned = warned || r.Warned()
+	}
+	if failed {
+		return nil, 0, PreflightError{Results: results}
+	}
+	if warned {
+		log.Log(fmt.Sprintf("⚠️  preflight checks passed with warnings:\n%s", FormatPreflightResults(results)))
+	}
+
+	protocolVersion, err := api.NegotiateProtocolVersion(versions...)
+	if err != nil {
+		return nil, 0, err
+	}
+	return identities, protocolVersion, nil
+}
+
+// requiredCapabilities returns the optional sidecar features needed to sign with the config.
+// Every operator must bind its key share to the validator, or it couldn't top it up or sign its owner's nonces later
+func requiredCapabilities(config api.SignatureConfig) []string {
+	capabilities := []string{api.CapabilityValidatorBinding}
+	if config.FaultTolerant {
+		capabilities = append(capabilities, api.CapabilityFaultTolerant)
+	}
+	return capabilities
+}
+
+func preflightOperator(suite crypto.ThresholdScheme, operator string, config api.SignatureConfig) PreflightResult {
+	result := PreflightResult{Operator: operator}
+	checks := make(map[string]PreflightCheck)
+	pass := func(name string) { checks[name] = PreflightCheck{Name: name} }
+	fail := func(name string, err error) { checks[name] = PreflightCheck{Name: name, Err: err} }
+	warn := func(name string, err error) { checks[name] = PreflightCheck{Name: name, Warning: err} }
+
+	// every other check depends on the operator being reachable
+	address, err := parseOperator(operator)
+	if err != nil {
+		fail(healthCheck, err)
+		return withChecks(result, checks)
+	}
+	client := api.NewSidecarClientWithTimeout(address, preflightTimeout)
+	health, err := client.Health()
+	if err != nil {
+		fail(healthCheck, err)
+		return withChecks(result, checks)
+	}
+	pass(healthCheck)
+
+	if err := checkSidecarVersion(health.Version); err != nil {
+		fail(versionCheck, err)
+	} else {
+		pass(versionCheck)
+	}
+
+	// older deposit files may not contain a network name, in which case the sidecar will check the fork version
+	if config.DepositData.NetworkName != "" {
+		if !slices.Contains(health.Networks, config.DepositData.NetworkName) {
+			fail(networkCheck, fmt.Errorf("operator does not support %s; it supports %s", config.DepositData.NetworkName, strings.Join(health.Networks, ", ")))
+		} else {
+			pass(networkCheck)
+		}
+	}
+
+	if health.Timestamp.IsZero() {
+		fail(clockCheck, fmt.Errorf("operator did not report its time"))
+	} else if skew := time.Since(health.Timestamp).Abs(); skew > MaxClockSkew {
+		fail(clockCheck, fmt.Errorf("operator's clock is %s out, more than the maximum of %s", skew.Round(time.Second), MaxClockSkew))
+	} else {
+		pass(clockCheck)
+	}
+
+	identity, identityResponse, err := fetchIdentity(suite, address)
+	if err != nil {
+		fail(identityCheck, err)
+		return withChecks(result, checks)
+	}
+	pass(identityCheck)
+	result.Identity = identity
+	result.ProtocolVersion = identityResponse.SupportedProtocolVersion()
+
+	if err := checkCapabilities(identityResponse, requiredCapabilities(config)...); err != nil {
+		fail(protocolCheck, err)
+	} else {
+		pass(protocolCheck)
+	}
+
+	if config.SsvClient.IsConfigured() {
+		if ssvOperator, err := config.SsvClient.FetchPublicKeyFromSsv(identity.OperatorID); err != nil {
+			fail(ssvCheck, fmt.Errorf("operator %d is not registered on SSV: %w", identity.OperatorID, err))
+		} else if err := checkSsvKey(identityResponse, ssvOperator.PublicKey); err != nil {
+			fail(ssvCheck, err)
+		} else if err := checkSsvBinding(config.Registry, identity.OperatorID, ssvOperator.PublicKey); err != nil {
+			if errors.Is(err, errSsvUnchecked) || (errors.Is(err, errSsvUnbound) && !config.RequireSsvBinding) {
+				warn(ssvCheck, err)
+			} else {
+				fail(ssvCheck, err)
+			}
+		} else {
+			pass(ssvCheck)
+		}
+	}
+
+	if config.Registry != nil {
+		if err := config.Registry.Pin(identity); err != nil {
+			fail(registryCheck, err)
+		} else {
+			pass(registryCheck)
+		}
+	}
+
+	return withChecks(result, checks)
+}
+
+// checkSidecarVersion checks the operator's sidecar software is compatible with the CLI.
+// Legacy sidecars don't report a version, and are too old to speak the minimum protocol version
+func checkSidecarVersion(version string) error {
+	if version == "" {
+		return fmt.Errorf("operator's sidecar is too old to report its version; it must upgrade to one that speaks protocol version %d or later", api.MinimumProtocolVersion)
+	}
+	return shared.CompatibleVersion(shared.Version, version)
+}
+
+// checkSsvKey checks the operator encrypts shares to the key it's registered with on SSV.
+// Otherwise the validator would be registered with shares the operator's SSV node can't decrypt
+func checkSsvKey(response api.SidecarIdentityResponse, ssvPublicKey []byte) error {
+	if len(response.SsvKeyHash) == 0 {
+		return errors.New("operator does not report the SSV key it encrypts shares to; it must upgrade its sidecar")
+	}
+	expected, err := crypto.HashSsvPublicKey(ssvPublicKey)
+	if err != nil {
+		return fmt.Errorf("could not parse the operator's public key from SSV: %w", err)
+	}
+	if !bytes.Equal(expected, response.SsvKeyHash) {
+		return fmt.Errorf("operator %d encrypts shares to a different key than the one it's registered with on SSV; its `--ssv-key` is misconfigured", response.OperatorID)
+	}
+	return nil
+}
+
+// errSsvUnbound is returned for operators whose registry entry isn't bound to their SSV operator key, as opposed to ones bound to the wrong key
+var errSsvUnbound = errors.New("the operator's identity is not signed by its SSV operator key")
+
+// errSsvUnchecked is returned for operators without a registry entry to check the binding of,
+// i.e. when the registry check is skipped or the operator is allowed outside the registry.
+// They're only ever warned about, as there's nothing the registry could have bound them with
+var errSsvUnchecked = errors.New("the operator's identity could not be checked against its SSV operator key")
+
+// checkSsvBinding verifies the operator's registry entry is signed by the operator's key on SSV,
+// so a registry entry can't claim an operator ID it doesn't own.
+// It returns errSsvUnchecked if there's no registry entry for the operator, and errSsvUnbound if its entry carries no SSV signature
+func checkSsvBinding(operatorsRegistry *registry.Registry, operatorID uint32, ssvPublicKey []byte) error {
+	if operatorsRegistry == nil {
+		return fmt.Errorf("%w: the operators registry check was skipped", errSsvUnchecked)
+	}
+	registered, found := operatorsRegistry.FindByID(operatorID)
+	if !found {
+		return fmt.Errorf("%w: operator %d is not in the operators registry", errSsvUnchecked, operatorID)
+	}
+	if len(registered.SsvSignature) == 0 {
+		return fmt.Errorf("%w: operator %d's registry entry has no SSV signature", errSsvUnbound, operatorID)
+	}
+	return registered.VerifySsvSignature(ssvPublicKey)
+}
+
+// withChecks adds the checks to the result in display order, marking any that weren't run as skipped
+func withChecks(result PreflightResult, checks map[string]PreflightCheck) PreflightResult {
+	for _, name := range preflightChecks {
+		check, ok := checks[name]
+		if !ok {
+			check = PreflightCheck{Name: name, Skipped: true}
+		}
+		result.Checks = append(result.Checks, check)
+	}
+	return result
+}
+
+// FormatPreflightResults renders the results as a table, followed by the reason for each failure
+func FormatPreflightResults(results []PreflightResult) string {
+	buf := bytes.Buffer{}
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(w, "ID\tAddress\t%s\n", strings.Join(preflightChecks, "\t"))
+	for _, r := range results {
+		statuses := make([]string, len(r.Checks))
+		for i, c := range r.Checks {
+			switch {
+			case c.Err != nil:
+				statuses[i] = "❌"
+			case c.Warning != nil:
+				statuses[i] = "⚠️"
+			case c.Skipped:
+				statuses[i] = "➖"
+			default:
+				statuses[i] = "✅"
+			}
+		}
+		id := "?"
+		if r.Identity.OperatorID != 0 {
+			id = fmt.Sprintf("%d", r.Identity.OperatorID)
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", id, r.Operator, strings.Join(statuses, "\t"))
+	}
+	_ = w.Flush()
+
+	for _, r := range results {
+		for _, c := range r.Checks {
+			if c.Err != nil {
+				buf.WriteString(fmt.Sprintf("❌ %s %s: %v\n", r.Operator, c.Name, c.Err))
+			} else if c.Warning != nil {
+				buf.WriteString(fmt.Sprintf("⚠️  %s %s: %v\n", r.Operator, c.Name, c.Warning))
+			}
+		}
+	}
+	return buf.String()
+}
@@ -0,0 +1,85 @@
+package cli
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/randa-mu/ssv-dkg/shared"
+	"github.com/randa-mu/ssv-dkg/shared/api"
+	"github.com/randa-mu/ssv-dkg/shared/crypto"
+	"github.com/randa-mu/ssv-dkg/shared/registry"
+	"golang.org/x/exp/slices"
+)
+
+// Refresh reshares the key of an existing validator cluster to the same operators, so each of them gets a new key share
+// and any shares leaked before the refresh can't be combined with the new ones. The group public key stays the same.
+// The operators sign the validator nonce of `owner`, so the validator can be registered again with its new shares.
+// Once the refreshed shares are confirmed, the operators delete the shares they superseded.
+// If `operatorsRegistry` is set, every operator's identity must match the one registered for it
+func Refresh(state api.SigningOutput, owner api.OwnerConfig, operatorsRegistry *registry.Registry, log shared.QuietLogger) (api.SigningOutput, error) {
+	committee := refreshCommittee(state)
+	operators := make([]string, len(committee))
+	for i, identity := range committee {
+		operators[i] = identity.Address
+	}
+
+	output, partials, err := reshare(operators, state, owner, operatorsRegistry, log)
+	if err != nil {
+		return api.SigningOutput{}, err
+	}
+
+	// Reshare already checks the group public key is unchanged, so we only need to check the shares were refreshed
+	if bytes.Equal(output.GroupPublicPolynomial, state.GroupPublicPolynomial) {
+		return api.SigningOutput{}, errors.New("the public polynomial didn't change, so the key shares were not refreshed")
+	}
+	refreshed := make([]crypto.Identity, len(output.OperatorShares))
+	for i, share := range output.OperatorShares {
+		refreshed[i] = share.Identity
+	}
+	if formatOperatorIDs(refreshed) != formatOperatorIDs(committee) {
+		return api.SigningOutput{}, fmt.Errorf("the operators changed during the refresh from %s to %s", formatOperatorIDs(committee), formatOperatorIDs(refreshed))
+	}
+
+	pruneSupersededShares(output, owner, partials, log)
+	return output, nil
+}
+
+// pruneSupersededShares asks each operator to delete the key shares the refresh superseded, proving the refreshed shares
+// work with the partial signatures the operators made with them. The refreshed shares are valid either way, so any
+// operator that fails to delete its old shares is only warned about
+func pruneSupersededShares(output api.SigningOutput, owner api.OwnerConfig, partials [][]byte, log shared.QuietLogger) {
+	for _, share := range output.OperatorShares {
+		client := api.NewSidecarClient(share.Identity.Address)
+		protocolVersion, err := negotiateCapability(client, api.CapabilityPrune)
+		if err == nil {
+			encryptedShareHash := sha256.Sum256(share.EncryptedShare)
+			_, err = client.Prune(api.PruneRequest{
+				SessionID:                       hex.EncodeToString(output.SessionID),
+				EncryptedShareHash:              encryptedShareHash[:],
+				ValidatorNonce:                  owner.ValidatorNonce,
+				ValidatorNoncePartialSignatures: partials,
+				ProtocolVersion:                 protocolVersion,
+			})
+		}
+		if err != nil {
+			log.Log(fmt.Sprintf("⚠️  operator %d couldn't delete the key shares the refresh superseded: %v", share.Identity.OperatorID, err))
+		}
+	}
+}
+
+// refreshCommittee returns every operator in the cluster, including any that didn't receive a key share,
+// sorted by operator ID
+func refreshCommittee(state api.SigningOutput) []crypto.Identity {
+	committee := make([]crypto.Identity, 0, len(state.OperatorShares)+len(state.MissingOperators))
+	for _, share := range state.OperatorShares {
+		committee = append(committee, share.Identity)
+	}
+	committee = append(committee, state.MissingOperators...)
+	slices.SortStableFunc(committee, func(a, b crypto.Identity) int {
+		return int(a.OperatorID) - int(b.OperatorID)
+	})
+	return committee
+}
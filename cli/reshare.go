@@ -6,57 +6,66 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/drand/kyber/share/dkg"
 
 	"github.com/randa-mu/ssv-dkg/shared"
 	"github.com/randa-mu/ssv-dkg/shared/api"
 	"github.com/randa-mu/ssv-dkg/shared/crypto"
+	"github.com/randa-mu/ssv-dkg/shared/registry"
+	"golang.org/x/exp/slices"
 )
 
-func Reshare(operators []string, state api.SigningOutput, log shared.QuietLogger) (api.SigningOutput, error) {
-	// SSV supports 3f+1 nodes up to f=4
-	numOfNodes := len(operators)
-	if numOfNodes != 4 && numOfNodes != 7 && numOfNodes != 10 && numOfNodes != 13 {
-		return api.SigningOutput{}, errors.New("you must pass either 4, 7, 10 or 13 operators to ensure a majority threshold")
-	}
+// Reshare moves the key of an existing validator cluster onto a new set of operators.
+// The new operators sign the validator nonce of `owner`, so the validator can be registered with them.
+// If `operatorsRegistry` is set, every operator's identity must match the one registered for it
+func Reshare(operators []string, state api.SigningOutput, owner api.OwnerConfig, operatorsRegistry *registry.Registry, log shared.QuietLogger) (api.SigningOutput, error) {
+	output, _, err := reshare(operators, state, owner, operatorsRegistry, log)
+	return output, err
+}
 
+// reshare runs a reshare, returning the new operators' verified partial signatures over the validator nonce along with the output
+func reshare(operators []string, state api.SigningOutput, owner api.OwnerConfig, operatorsRegistry *registry.Registry, log shared.QuietLogger) (api.SigningOutput, [][]byte, error) {
+	numOfNodes := len(operators)
 	suite := crypto.NewBLSSuite()
-
-	// then fetch their signed public keys
-	log.MaybeLog("⏳ contacting nodes")
-	identities, err := fetchIdentities(suite, operators)
+	identities, plan, protocolVersion, err := prepareReshare(suite, operators, state, operatorsRegistry, log)
 	if err != nil {
-		return api.SigningOutput{}, err
+		return api.SigningOutput{}, nil, err
 	}
 
 	// then we run the reshare with them
-	operatorResponses, err := runReshare(state, identities)
+	operatorResponses, err := runReshare(state, owner, identities, plan, protocolVersion, log)
 	if err != nil {
-		return api.SigningOutput{}, err
+		return api.SigningOutput{}, nil, err
 	}
 
 	// if any nodes fail to qualify, we fail the reshare
 	// really the sidecars should know this themselves and return an error, but worth sanity checking anyway
 	if len(operatorResponses) != numOfNodes {
-		return api.SigningOutput{}, fmt.Errorf("some nodes did not complete the resharing. Count: %d, expected %d", len(operatorResponses), numOfNodes)
+		return api.SigningOutput{}, nil, fmt.Errorf("some nodes did not complete the resharing. Count: %d, expected %d", len(operatorResponses), numOfNodes)
 	}
 
 	// we do some sanity checks on the returned details to ensure the public key hasn't changed
 	if err := verifyPublicPolynomialSameReshare(operatorResponses); err != nil {
-		return api.SigningOutput{}, err
+		return api.SigningOutput{}, nil, err
 	}
 	polynomialCommitments := operatorResponses[0].response.PublicPolynomial
 	groupPK, err := extractGroupPublicKey(suite, polynomialCommitments)
 	if err != nil {
-		return api.SigningOutput{}, err
+		return api.SigningOutput{}, nil, err
 	}
 	oldGroupPK, err := extractGroupPublicKey(suite, state.GroupPublicPolynomial)
 	if err != nil {
-		return api.SigningOutput{}, err
+		return api.SigningOutput{}, nil, err
 	}
 
 	if !bytes.Equal(groupPK, oldGroupPK) {
-		return api.SigningOutput{}, errors.New("the new public key didn't match the old one")
+		return api.SigningOutput{}, nil, errors.New("the new public key didn't match the old one")
 	}
 
 	operatorShares := make([]api.OperatorShare, len(operatorResponses))
@@ -68,12 +77,61 @@ func Reshare(operators []string, state api.SigningOutput, log shared.QuietLogger
 		}
 	}
 
+	if err := verifySharePublicKeys(suite, polynomialCommitments, numOfNodes, operatorShares); err != nil {
+		return api.SigningOutput{}, nil, err
+	}
+
+	// the old validator nonce signature was made by the old operators, so we need a new one to re-register the validator
+	validatorNonceSignature, partials, err := aggregateReshareNonceSignature(suite, owner, polynomialCommitments, groupPK, operatorResponses)
+	if err != nil {
+		return api.SigningOutput{}, nil, err
+	}
+
 	return api.SigningOutput{
-		SessionID:             state.SessionID,
-		DepositDataSignature:  state.DepositDataSignature,
-		GroupPublicPolynomial: polynomialCommitments,
-		OperatorShares:        operatorShares,
-	}, nil
+		SessionID:               state.SessionID,
+		DepositDataSignature:    state.DepositDataSignature,
+		GroupPublicPolynomial:   polynomialCommitments,
+		OperatorShares:          operatorShares,
+		ValidatorNonceSignature: validatorNonceSignature,
+		WithdrawalCredentials:   state.WithdrawalCredentials,
+		Owner:                   owner.Address,
+	}, partials, nil
+}
+
+// prepareReshare checks the new operators are ready to reshare and plans the reshare from the old ones.
+// It returns the identities the new operators will reshare with, the plan and the protocol version every participant speaks
+func prepareReshare(suite crypto.ThresholdScheme, operators []string, state api.SigningOutput, operatorsRegistry *registry.Registry, log shared.QuietLogger) ([]crypto.Identity, reshareplan, uint32, error) {
+	// SSV supports 3f+1 nodes up to f=4
+	numOfNodes := len(operators)
+	if numOfNodes != 4 && numOfNodes != 7 && numOfNodes != 10 && numOfNodes != 13 {
+		return nil, reshareplan{}, 0, errors.New("you must pass either 4, 7, 10 or 13 operators to ensure a majority threshold")
+	}
+
+	// then fetch their signed public keys
+	log.MaybeLog("⏳ contacting nodes")
+	identities, protocolVersion, err := fetchIdentities(suite, operators, operatorsRegistry, api.CapabilityReshare, api.CapabilityReshareNonce, api.CapabilityValidatorBinding)
+	if err != nil {
+		return nil, reshareplan{}, 0, err
+	}
+
+	// operators can only take part in a reshare with a single key, so any that
+	// have rotated their key since the last DKG must keep using the one they had in the group
+	identities, err = keepPreviousKeys(state, identities, operatorsRegistry, log)
+	if err != nil {
+		return nil, reshareplan{}, 0, err
+	}
+
+	// operators leaving the cluster deal their key shares too, as the new operators might not hold enough of them alone
+	plan, err := planReshare(suite, state, identities, log)
+	if err != nil {
+		return nil, reshareplan{}, 0, err
+	}
+	plan.print(log)
+	protocolVersion, err = api.NegotiateProtocolVersion(append(plan.dealerVersions, protocolVersion)...)
+	if err != nil {
+		return nil, reshareplan{}, 0, err
+	}
+	return identities, plan, protocolVersion, nil
 }
 
 type operatorReshareResponse struct {
@@ -81,19 +139,148 @@ type operatorReshareResponse struct {
 	response api.ReshareResponse
 }
 
-func runReshare(state api.SigningOutput, identities []crypto.Identity) ([]operatorReshareResponse, error) {
+// keepPreviousKeys replaces the identities of operators that were already in the group, but have since rotated their key,
+// with the identities they had in the group. Their sidecars keep their retired keys for this.
+// A previous key that has expired or been revoked can't be used, so the operator must leave the cluster and rejoin it with its new key
+func keepPreviousKeys(state api.SigningOutput, identities []crypto.Identity, operatorsRegistry *registry.Registry, log shared.QuietLogger) ([]crypto.Identity, error) {
+	previous := make(map[uint32]crypto.Identity)
+	for _, share := range state.OperatorShares {
+		previous[share.Identity.OperatorID] = share.Identity
+	}
+	for _, identity := range state.MissingOperators {
+		previous[identity.OperatorID] = identity
+	}
+
+	out := make([]crypto.Identity, len(identities))
+	for i, identity := range identities {
+		out[i] = identity
+		old, found := previous[identity.OperatorID]
+		if !found || bytes.Equal(old.Public, identity.Public) {
+			continue
+		}
+		err := old.CheckExpiry(time.Now())
+		if err == nil && operatorsRegistry != nil {
+			err = operatorsRegistry.CheckKeyRevoked(old)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("operator %d has rotated its key since the last DKG, but its previous key can't be used to reshare: %w. "+
+				"Reshare without the operator, then reshare again with it to use its new key", identity.OperatorID, err)
+		}
+		log.MaybeLog(fmt.Sprintf("🔑 operator %d has rotated its key since the last DKG, so it will reshare with its previous key", identity.OperatorID))
+		out[i] = old
+	}
+	return out, nil
+}
+
+// reshareplan describes how a reshare moves a cluster from its old operators to its new ones
+type reshareplan struct {
+	staying []crypto.Identity
+	joining []crypto.Identity
+	leaving []crypto.Identity
+	// the leaving operators that can deal their key share to the new operators, and the protocol versions they speak
+	dealers        []crypto.Identity
+	dealerVersions []uint32
+	oldCount       int
+	newCount       int
+	oldThreshold   int
+	newThreshold   int
+}
+
+// planReshare works out which operators are staying in, joining and leaving the cluster, and checks that enough
+// of the operators holding key shares can take part for the old threshold to be met.
+// Leaving operators can only take part if they're reachable and support dealing their share to a group they aren't in
+func planReshare(suite crypto.ThresholdScheme, state api.SigningOutput, identities []crypto.Identity, log shared.QuietLogger) (reshareplan, error) {
+	holders := make(map[uint32]bool)
+	for _, share := range state.OperatorShares {
+		holders[share.Identity.OperatorID] = true
+	}
+	joiningIDs := make(map[uint32]bool)
+	oldCount := len(state.OperatorShares) + len(state.MissingOperators)
+	plan := reshareplan{
+		oldCount:     oldCount,
+		newCount:     len(identities),
+		oldThreshold: dkg.MinimumT(oldCount),
+		newThreshold: dkg.MinimumT(len(identities)),
+	}
+	for _, identity := range identities {
+		joiningIDs[identity.OperatorID] = true
+		if holders[identity.OperatorID] {
+			plan.staying = append(plan.staying, identity)
+		} else {
+			plan.joining = append(plan.joining, identity)
+		}
+	}
+
+	for _, identity := range state.MissingOperators {
+		if !joiningIDs[identity.OperatorID] {
+			plan.leaving = append(plan.leaving, identity)
+		}
+	}
+	for _, share := range state.OperatorShares {
+		if joiningIDs[share.Identity.OperatorID] {
+			continue
+		}
+		plan.leaving = append(plan.leaving, share.Identity)
+
+		// they deal with the key they had in the group, which their sidecar keeps even if it has been rotated
+		_, response, err := fetchIdentity(suite, share.Identity.Address)
+		if err == nil {
+			err = checkCapabilities(response, api.CapabilityReshareLeave, api.CapabilityValidatorBinding)
+		}
+		if err != nil {
+			log.MaybeLog(fmt.Sprintf("⚠️  operator %d is leaving the cluster but can't deal its key share: %v", share.Identity.OperatorID, err))
+			continue
+		}
+		plan.dealers = append(plan.dealers, share.Identity)
+		plan.dealerVersions = append(plan.dealerVersions, response.SupportedProtocolVersion())
+	}
+
+	if available := len(plan.staying) + len(plan.dealers); available < plan.oldThreshold {
+		return reshareplan{}, fmt.Errorf("only %d operators holding key shares can take part in the reshare, but the old threshold requires %d", available, plan.oldThreshold)
+	}
+	return plan, nil
+}
+
+func (p reshareplan) print(log shared.QuietLogger) {
+	log.MaybeLog(fmt.Sprintf("📋 resharing from %d operators (threshold %d) to %d operators (threshold %d)", p.oldCount, p.oldThreshold, p.newCount, p.newThreshold))
+	log.MaybeLog(fmt.Sprintf("\tstaying: %s", formatOperatorIDs(p.staying)))
+	log.MaybeLog(fmt.Sprintf("\tleaving: %s", formatOperatorIDs(p.leaving)))
+	log.MaybeLog(fmt.Sprintf("\tjoining: %s", formatOperatorIDs(p.joining)))
+}
+
+func formatOperatorIDs(identities []crypto.Identity) string {
+	if len(identities) == 0 {
+		return "none"
+	}
+	operatorIDs := make([]uint32, len(identities))
+	for i, identity := range identities {
+		operatorIDs[i] = identity.OperatorID
+	}
+	slices.Sort(operatorIDs)
+
+	ids := make([]string, len(operatorIDs))
+	for i, id := range operatorIDs {
+		ids[i] = strconv.FormatUint(uint64(id), 10)
+	}
+	return strings.Join(ids, ", ")
+}
+
+// runReshare runs the reshare with the new operators, along with any leaving operators dealing their key share.
+// Only the responses of the new operators are returned, as the leaving operators receive no share
+func runReshare(state api.SigningOutput, owner api.OwnerConfig, identities []crypto.Identity, plan reshareplan, protocolVersion uint32, log shared.QuietLogger) ([]operatorReshareResponse, error) {
 	dkgResponses := shared.SafeList[operatorReshareResponse]{}
-	errs := make(chan error, len(identities))
+	participants := append(slices.Clone(identities), plan.dealers...)
+	errs := make(chan error, len(participants))
 	wg := sync.WaitGroup{}
-	wg.Add(len(identities))
+	wg.Add(len(participants))
 
 	// we hash the encrypted share, which will be used by the sidecar to identify
 	// which keyshare to pass into resharing, in case some nodes have successfully
 	// saved a keyshare for DKGs which not all nodes responded to
-	oldNodes := make([]crypto.Identity, len(state.OperatorShares))
+	oldNodes := make([]crypto.Identity, 0, len(state.OperatorShares)+len(state.MissingOperators))
 	hashedShares := make(map[string][]byte)
-	for i, share := range state.OperatorShares {
-		oldNodes[i] = share.Identity
+	for _, share := range state.OperatorShares {
+		oldNodes = append(oldNodes, share.Identity)
 
 		s := sha256.New()
 		_, err := s.Write(share.EncryptedShare)
@@ -103,29 +290,57 @@ func runReshare(state api.SigningOutput, identities []crypto.Identity) ([]operat
 
 		hashedShares[share.Identity.Address] = s.Sum(nil)
 	}
+	// operators that didn't complete a fault-tolerant DKG were still part of the group,
+	// so they count towards the old threshold; without a share hash they join as new nodes
+	oldNodes = append(oldNodes, state.MissingOperators...)
 
-	for _, identity := range identities {
-		go func(identity crypto.Identity) {
-			client := api.NewSidecarClient(identity.Address)
+	// operators leaving the group only deal their share, so the reshare can carry on without some of them
+	// as long as a threshold of the operators holding shares are still dealing
+	var holders atomic.Int32
+	for _, identity := range participants {
+		if hashedShares[identity.Address] != nil {
+			holders.Add(1)
+		}
+	}
+
+	for i, identity := range participants {
+		go func(identity crypto.Identity, leaving bool) {
+			client := api.NewSidecarClientWithTimeout(identity.Address, dkgRequestTimeout)
 			reshareResponse, err := client.Reshare(api.ReshareRequest{
 				Operators: identities,
 				PreviousState: api.PreviousDKGState{
 					SessionID:                   hex.EncodeToString(state.SessionID),
 					Nodes:                       oldNodes,
 					PublicPolynomialCommitments: state.GroupPublicPolynomial,
+					WithdrawalCredentials:       state.WithdrawalCredentials,
+					Owner:                       state.Owner,
 				},
 				PreviousEncryptedShareHash: hashedShares[identity.Address],
+				ProtocolVersion:            protocolVersion,
+				OwnerConfig:                &owner,
 			})
+			if err != nil && leaving {
+				remaining := holders.Add(-1)
+				if int(remaining) < plan.oldThreshold {
+					errs <- fmt.Errorf("only %d of the operators holding key shares are left, fewer than the threshold of %d: %w", remaining, plan.oldThreshold, err)
+					return
+				}
+				log.Log(fmt.Sprintf("⚠️  leaving operator %s failed to deal its key share, continuing without it: %v", identity.Address, err))
+				wg.Done()
+				return
+			}
 			if err != nil {
 				errs <- err
-			} else {
+				return
+			}
+			if !leaving {
 				dkgResponses.Append(operatorReshareResponse{
 					identity: identity,
 					response: reshareResponse,
 				})
-				wg.Done()
 			}
-		}(identity)
+			wg.Done()
+		}(identity, i >= len(identities))
 	}
 
 	// we wait for the reshare to finish
@@ -145,6 +360,32 @@ func runReshare(state api.SigningOutput, identities []crypto.Identity) ([]operat
 	return dkgResponses.Get(), nil
 }
 
+// aggregateReshareNonceSignature verifies each operator's partial signature over the validator nonce
+// and aggregates them into a signature by the group key, returning the partial signatures too
+func aggregateReshareNonceSignature(suite crypto.ThresholdScheme, owner api.OwnerConfig, publicPolynomial []byte, groupPublicKey []byte, responses []operatorReshareResponse) ([]byte, [][]byte, error) {
+	validatorNonceMessage, err := crypto.ValidatorNonceMessage(owner.Address, owner.ValidatorNonce)
+	if err != nil {
+		return nil, nil, fmt.Errorf("your ethereum address wasn't correct: %v", err)
+	}
+
+	partials := make([][]byte, len(responses))
+	for i, r := range responses {
+		if err := suite.VerifyPartial(publicPolynomial, validatorNonceMessage, r.response.ValidatorNoncePartialSignature); err != nil {
+			return nil, nil, OperatorError{Address: r.identity.Address, Err: fmt.Errorf("invalid partial signature over the validator nonce: %w", err)}
+		}
+		partials[i] = r.response.ValidatorNoncePartialSignature
+	}
+
+	signature, err := aggregateGroupSignature(suite, partials, publicPolynomial, validatorNonceMessage, len(responses))
+	if err != nil {
+		return nil, nil, fmt.Errorf("error aggregating validator nonce signature: %v", err)
+	}
+	if err = suite.Verify(validatorNonceMessage, groupPublicKey, signature); err != nil {
+		return nil, nil, fmt.Errorf("failed to verify validator nonce signature: %v", err)
+	}
+	return signature, partials, nil
+}
+
 func verifyPublicPolynomialSameReshare(arr []operatorReshareResponse) error {
 	for i := 1; i < len(arr); i++ {
 		// all nodes should return the same group public key or someone is being naughty
@@ -0,0 +1,148 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+
+	"github.com/randa-mu/ssv-dkg/shared"
+	"github.com/randa-mu/ssv-dkg/shared/api"
+)
+
+// healthCheckTimeout is how long we wait for an operator's health check when working out which operators failed,
+// so a black-holed operator is marked as failing rather than hanging the retry
+const healthCheckTimeout = 5 * time.Second
+
+// OperatorError attributes an error to the operator that caused it,
+// so a failed ceremony can be retried with a spare operator in its place
+type OperatorError struct {
+	Address string
+	Err     error
+}
+
+func (e OperatorError) Error() string {
+	return e.Err.Error()
+}
+
+func (e OperatorError) Unwrap() error {
+	return e.Err
+}
+
+// findFailingOperators works out which operators caused a ceremony to fail.
+// Operators failing their health check are the most likely culprits; otherwise
+// we fall back to the operators whose errors differ from everyone else's, if any
+func findFailingOperators(operators []string, err error) []string {
+	var failing []string
+	for _, operator := range operators {
+		if _, healthErr := api.NewSidecarClientWithTimeout(operator, healthCheckTimeout).Health(); healthErr != nil {
+			failing = append(failing, operator)
+		}
+	}
+	if len(failing) > 0 {
+		return failing
+	}
+
+	var preflightErr PreflightError
+	if errors.As(err, &preflightErr) {
+		return preflightErr.FailingOperators()
+	}
+
+	for _, operator := range blameOperators(collectOperatorErrors(err)) {
+		if slices.Contains(operators, operator) {
+			failing = append(failing, operator)
+		}
+	}
+	return failing
+}
+
+// blameOperators returns the operators whose errors differ from the error most of them failed with.
+// A failure every operator reports, such as the DKG aborting, isn't any single operator's fault,
+// so replacing whichever of them happened to return first would swap out healthy operators
+func blameOperators(operatorErrs []OperatorError) []string {
+	if len(operatorErrs) == 1 {
+		return []string{operatorErrs[0].Address}
+	}
+
+	counts := make(map[string]int)
+	for _, e := range operatorErrs {
+		counts[normaliseOperatorError(e)]++
+	}
+	common, commonCount := "", 0
+	for message, count := range counts {
+		if count > commonCount {
+			common, commonCount = message, count
+		}
+	}
+	// if every error is different, there's nothing to tell the culprits from the rest
+	if commonCount == 1 {
+		common = ""
+	}
+
+	var blamed []string
+	for _, e := range operatorErrs {
+		if normaliseOperatorError(e) != common {
+			blamed = append(blamed, e.Address)
+		}
+	}
+	return blamed
+}
+
+// normaliseOperatorError strips anything identifying the operator from its error, so the same failure
+// reported by different operators can be compared
+func normaliseOperatorError(e OperatorError) string {
+	err := e.Err
+	if unwrapped := errors.Unwrap(err); unwrapped != nil {
+		// runDKG prefixes the error with the operator's ID
+		err = unwrapped
+	}
+	return strings.ReplaceAll(err.Error(), e.Address, "")
+}
+
+// collectOperatorErrors finds every OperatorError wrapped in err, including any joined together
+func collectOperatorErrors(err error) []OperatorError {
+	switch e := err.(type) {
+	case OperatorError:
+		return []OperatorError{e}
+	case interface{ Unwrap() []error }:
+		var all []OperatorError
+		for _, inner := range e.Unwrap() {
+			all = append(all, collectOperatorErrors(inner)...)
+		}
+		return all
+	case interface{ Unwrap() error }:
+		return collectOperatorErrors(e.Unwrap())
+	}
+	return nil
+}
+
+// substituteOperators replaces each failing operator with the next healthy spare,
+// returning the new set of operators and the spares that haven't been used yet
+func substituteOperators(operators []string, failing []string, spares []string, log shared.QuietLogger) ([]string, []string, error) {
+	next := slices.Clone(operators)
+	for _, f := range failing {
+		index := slices.Index(next, f)
+		if index == -1 {
+			continue
+		}
+
+		replaced := false
+		for len(spares) > 0 && !replaced {
+			spare := spares[0]
+			spares = spares[1:]
+			if _, err := api.NewSidecarClientWithTimeout(spare, healthCheckTimeout).Health(); err != nil {
+				log.MaybeLog(fmt.Sprintf("⚠️  spare operator %s failed its health check: %v", spare, err))
+				continue
+			}
+			log.MaybeLog(fmt.Sprintf("🔁 replacing operator %s with spare operator %s", f, spare))
+			next[index] = spare
+			replaced = true
+		}
+		if !replaced {
+			return nil, nil, fmt.Errorf("no healthy spare operators left to replace %s", f)
+		}
+	}
+	return next, spares, nil
+}
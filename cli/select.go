@@ -0,0 +1,167 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slices"
+
+	"github.com/randa-mu/ssv-dkg/shared/api"
+	"github.com/randa-mu/ssv-dkg/shared/crypto"
+	"github.com/randa-mu/ssv-dkg/shared/registry"
+)
+
+// OperatorProbe is the result of health-checking an operator from the registry
+type OperatorProbe struct {
+	Operator registry.Operator
+	Latency  time.Duration
+	// the error from the health check, if the operator couldn't be reached
+	Err error
+	// the version of the sidecar software, if it reports one
+	Version string
+	// the highest protocol version and the capabilities the sidecar advertises in its identity
+	ProtocolVersion uint32
+	Capabilities    []string
+	// the error if the sidecar's live identity doesn't verify or doesn't match its registry entry
+	IdentityErr error
+}
+
+// Healthy returns whether the operator is reachable and serving the identity it's registered with
+func (p OperatorProbe) Healthy() bool {
+	return p.Err == nil && p.IdentityErr == nil
+}
+
+// SelectionCriteria narrows down which operators from the registry can be selected
+type SelectionCriteria struct {
+	// the number of operators to select; one of the cluster sizes SSV supports
+	Count int
+	// operators that must not be selected
+	Exclude []uint32
+	// operators that must be selected, regardless of the other criteria
+	Require []uint32
+	// if set, operators slower than this to respond are not selected
+	MaxLatency time.Duration
+	// if set, only operators in one of these locations are selected
+	Locations []string
+	// if set, only operators run by one of these organisations are selected
+	Organisations []string
+}
+
+// ProbeOperators health-checks every operator in parallel, measuring how long each takes to respond
+// and checking its live identity matches its registry entry
+func ProbeOperators(operators []registry.Operator, timeout time.Duration) []OperatorProbe {
+	probes := make([]OperatorProbe, len(operators))
+	wg := sync.WaitGroup{}
+	wg.Add(len(operators))
+	for i, operator := range operators {
+		go func(i int, operator registry.Operator) {
+			defer wg.Done()
+			probes[i] = probeOperator(operator, timeout)
+		}(i, operator)
+	}
+	wg.Wait()
+	return probes
+}
+
+func probeOperator(operator registry.Operator, timeout time.Duration) OperatorProbe {
+	probe := OperatorProbe{Operator: operator}
+	client := api.NewSidecarClientWithTimeout(operator.Address, timeout)
+	start := time.Now()
+	health, err := client.Health()
+	probe.Latency = time.Since(start)
+	if err != nil {
+		probe.Err = err
+		return probe
+	}
+	probe.Version = health.Version
+
+	response, err := client.Identity()
+	if err != nil {
+		probe.IdentityErr = err
+		return probe
+	}
+	probe.ProtocolVersion = response.SupportedProtocolVersion()
+	probe.Capabilities = response.Capabilities
+
+	identity := response.Identity()
+	if err := identity.Verify(crypto.NewBLSSuite()); err != nil {
+		probe.IdentityErr = fmt.Errorf("identity did not verify: %w", err)
+	} else if err := identity.CheckExpiry(time.Now()); err != nil {
+		probe.IdentityErr = err
+	} else {
+		probe.IdentityErr = operator.Matches(identity)
+	}
+	return probe
+}
+
+// SelectOperators picks the required operators and then the fastest healthy operators matching the criteria
+func SelectOperators(probes []OperatorProbe, criteria SelectionCriteria) ([]registry.Operator, error) {
+	// SSV supports 3f+1 nodes up to f=4
+	if criteria.Count != 4 && criteria.Count != 7 && criteria.Count != 10 && criteria.Count != 13 {
+		return nil, errors.New("you must select either 4, 7, 10 or 13 operators to ensure a majority threshold")
+	}
+	if len(criteria.Require) > criteria.Count {
+		return nil, fmt.Errorf("you required %d operators, but are only selecting %d", len(criteria.Require), criteria.Count)
+	}
+
+	// sort them by latency, so we pick the fastest first
+	candidates := slices.Clone(probes)
+	slices.SortStableFunc(candidates, func(a, b OperatorProbe) int {
+		return int(a.Latency - b.Latency)
+	})
+
+	var selected []registry.Operator
+	for _, id := range criteria.Require {
+		if slices.Contains(criteria.Exclude, id) {
+			return nil, fmt.Errorf("operator %d is both required and excluded", id)
+		}
+		i := slices.IndexFunc(candidates, func(p OperatorProbe) bool {
+			return p.Operator.OperatorID == id
+		})
+		if i == -1 {
+			return nil, fmt.Errorf("required operator %d is not in the operators registry", id)
+		}
+		if !candidates[i].Healthy() {
+			return nil, fmt.Errorf("required operator %d is unhealthy: %w", id, errors.Join(candidates[i].Err, candidates[i].IdentityErr))
+		}
+		selected = append(selected, candidates[i].Operator)
+	}
+
+	for _, p := range candidates {
+		if len(selected) == criteria.Count {
+			break
+		}
+		if slices.Contains(criteria.Require, p.Operator.OperatorID) || !matches(p, criteria) {
+			continue
+		}
+		selected = append(selected, p.Operator)
+	}
+
+	if len(selected) < criteria.Count {
+		return nil, fmt.Errorf("only %d operators are healthy and match your criteria, but you asked for %d", len(selected), criteria.Count)
+	}
+
+	slices.SortStableFunc(selected, func(a, b registry.Operator) int {
+		return int(a.OperatorID) - int(b.OperatorID)
+	})
+	return selected, nil
+}
+
+// matches returns whether a healthy operator meets all the criteria
+func matches(p OperatorProbe, criteria SelectionCriteria) bool {
+	if !p.Healthy() || slices.Contains(criteria.Exclude, p.Operator.OperatorID) {
+		return false
+	}
+	if criteria.MaxLatency > 0 && p.Latency > criteria.MaxLatency {
+		return false
+	}
+	if len(criteria.Locations) > 0 && !slices.Contains(criteria.Locations, p.Operator.Location) {
+		return false
+	}
+	if len(criteria.Organisations) > 0 && !slices.Contains(criteria.Organisations, p.Operator.Organisation) {
+		return false
+	}
+	return true
+}
@@ -2,24 +2,64 @@ package cli
 
 import (
 	"bytes"
-	"crypto/sha256"
-	"encoding/binary"
 	"errors"
 	"fmt"
 	"net/url"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/drand/kyber/share/dkg"
+
 	"github.com/randa-mu/ssv-dkg/shared"
 	"github.com/randa-mu/ssv-dkg/shared/api"
 	"github.com/randa-mu/ssv-dkg/shared/crypto"
+	"github.com/randa-mu/ssv-dkg/shared/registry"
 	"golang.org/x/exp/slices"
 )
 
 // Sign performs a distributed key generation between the operators provided
 // then aggregates a group signature over the deposit data merkle root
-// then aggregates a group signature over the validator nonce
+// then aggregates a group signature over the validator nonce.
+// If the ceremony fails and spare operators were provided, the failing operators are
+// replaced and the ceremony is rerun with a fresh session ID, up to `config.MaxAttempts` times
 func Sign(config api.SignatureConfig, log shared.QuietLogger) (api.SigningOutput, error) {
+	maxAttempts := config.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	operators := config.Operators
+	spares := config.SpareOperators
+	for attempt := 1; ; attempt++ {
+		if maxAttempts > 1 {
+			log.MaybeLog(fmt.Sprintf("⏳ attempt %d of %d", attempt, maxAttempts))
+		}
+
+		attemptConfig := config
+		attemptConfig.Operators = operators
+		output, err := signOnce(attemptConfig, log)
+		if err == nil {
+			return output, nil
+		}
+		if attempt == maxAttempts || len(spares) == 0 {
+			return api.SigningOutput{}, err
+		}
+
+		log.MaybeLog(fmt.Sprintf("❌ attempt %d failed: %v", attempt, err))
+		failing := findFailingOperators(operators, err)
+		if len(failing) == 0 {
+			return api.SigningOutput{}, fmt.Errorf("could not work out which operator caused the failure: %w", err)
+		}
+		operators, spares, err = substituteOperators(operators, failing, spares, log)
+		if err != nil {
+			return api.SigningOutput{}, err
+		}
+	}
+}
+
+// signOnce runs a single signing ceremony with the operators in the config
+func signOnce(config api.SignatureConfig, log shared.QuietLogger) (api.SigningOutput, error) {
 	// SSV supports 3f+1 nodes up to f=4
 	numOfNodes := len(config.Operators)
 	if numOfNodes != 4 && numOfNodes != 7 && numOfNodes != 10 && numOfNodes != 13 {
@@ -28,25 +68,30 @@ func Sign(config api.SignatureConfig, log shared.QuietLogger) (api.SigningOutput
 
 	suite := crypto.NewBLSSuite()
 
-	// then fetch their signed public keys
+	// then fetch their signed public keys, checking they're ready to run a DKG with us
 	log.MaybeLog("⏳ contacting nodes")
-	identities, err := fetchIdentities(suite, config.Operators)
+	identities, protocolVersion, err := preflight(suite, config, log)
 	if err != nil {
 		return api.SigningOutput{}, err
 	}
 
-	sessionID, err := createSessionID()
+	sessionNonce, sessionID, err := createSessionID(config, identities)
 	if err != nil {
 		return api.SigningOutput{}, err
 	}
 
 	// then let's actually kick off the DKG
 	log.MaybeLog("⏳ starting distributed key generation")
-	responses, err := runDKG(suite, sessionID, identities, config.DepositData, config.Owner)
+	responses, err := runDKG(suite, protocolVersion, sessionNonce, sessionID, identities, config.DepositData, config.Owner, config.FaultTolerant)
 	if err != nil {
 		return api.SigningOutput{}, err
 	}
 
+	missingOperators := extractMissingOperators(identities, responses)
+	for _, o := range missingOperators {
+		log.MaybeLog(fmt.Sprintf("⚠️  operator %d (%s) did not complete the DKG and has no key share; you must reshare it in before registering the validator", o.OperatorID, o.Address))
+	}
+
 	// we sort the responses by operatorID, though they ought to already be sorted
 	slices.SortStableFunc(responses, func(a, b api.OperatorResponse) int {
 		return int(a.Identity.OperatorID) - int(b.Identity.OperatorID)
@@ -60,6 +105,12 @@ func Sign(config api.SignatureConfig, log shared.QuietLogger) (api.SigningOutput
 	publicPolynomial := responses[0].Response.PublicPolynomial
 	groupPublicKey := crypto.ExtractGroupPublicKey(suite, shared.Clone(publicPolynomial))
 
+	// and each operator's share public key, so the key shares registered with SSV are the ones that produced the group key
+	operatorShares := extractEncryptedShares(responses)
+	if err := verifySharePublicKeys(suite, publicPolynomial, numOfNodes, operatorShares); err != nil {
+		return api.SigningOutput{}, err
+	}
+
 	// then we aggregate and verify the deposit data signature
 	depositDataMessage, err := crypto.DepositMessageSigningRoot(config.DepositData.IntoMessage(shared.Clone(groupPublicKey)), config.DepositData.ForkVersion)
 	if err != nil {
@@ -71,7 +122,7 @@ func Sign(config api.SignatureConfig, log shared.QuietLogger) (api.SigningOutput
 		return api.SigningOutput{}, fmt.Errorf("error extracting deposit data partials: %v", err)
 	}
 
-	depositDataSignature, err := aggregateGroupSignature(suite, depositDataPartials, publicPolynomial, depositDataMessage)
+	depositDataSignature, err := aggregateGroupSignature(suite, depositDataPartials, publicPolynomial, depositDataMessage, numOfNodes)
 	if err != nil {
 		return api.SigningOutput{}, fmt.Errorf("error aggregating deposit data signature: %v", err)
 	}
@@ -89,7 +140,7 @@ func Sign(config api.SignatureConfig, log shared.QuietLogger) (api.SigningOutput
 	if err != nil {
 		return api.SigningOutput{}, fmt.Errorf("error extracting partials for validator nonce signature: %v", err)
 	}
-	validatorNonceSignature, err := aggregateGroupSignature(suite, validatorNoncePartials, publicPolynomial, validatorNonceMessage)
+	validatorNonceSignature, err := aggregateGroupSignature(suite, validatorNoncePartials, publicPolynomial, validatorNonceMessage, numOfNodes)
 	if err != nil {
 		return api.SigningOutput{}, fmt.Errorf("error aggregating validator nonce signature: %v", err)
 	}
@@ -102,43 +153,84 @@ func Sign(config api.SignatureConfig, log shared.QuietLogger) (api.SigningOutput
 	output := api.SigningOutput{
 		SessionID:               sessionID,
 		GroupPublicPolynomial:   publicPolynomial,
-		OperatorShares:          extractEncryptedShares(responses),
+		OperatorShares:          operatorShares,
 		DepositDataSignature:    depositDataSignature,
 		ValidatorNonceSignature: validatorNonceSignature,
+		MissingOperators:        missingOperators,
+		WithdrawalCredentials:   config.DepositData.WithdrawalCredentials,
+		Owner:                   config.Owner.Address,
 	}
 
 	return output, nil
 }
 
-func fetchIdentities(suite crypto.ThresholdScheme, operators []string) ([]crypto.Identity, error) {
+// fetchIdentities fetches the identity of every operator, checking each supports the given capabilities
+// and, if a registry is passed, that it matches the identity registered for it.
+// It also returns the highest protocol version they all speak
+func fetchIdentities(suite crypto.ThresholdScheme, operators []string, operatorsRegistry *registry.Registry, capabilities ...string) ([]crypto.Identity, uint32, error) {
 	identities := make([]crypto.Identity, len(operators))
+	versions := make([]uint32, len(operators))
 	for i, operator := range operators {
 		// first we parse the operator address to ensure it's correct
 		address, err := parseOperator(operator)
 		if err != nil {
-			return nil, err
+			return nil, 0, OperatorError{Address: operator, Err: err}
 		}
 
-		// then we fetch the keys for the node
-		// perhaps these should be checked against the ones registered in the repo
-		client := api.NewSidecarClient(address)
-		response, err := client.Identity()
+		identity, response, err := fetchIdentity(suite, address)
 		if err != nil {
-			return nil, fmt.Errorf("☹️\tthere was an error health-checking %s: %w", operator, err)
+			return nil, 0, OperatorError{Address: operator, Err: err}
 		}
-		identity := crypto.Identity{
-			Address:    address,
-			OperatorID: response.OperatorID,
-			Public:     response.PublicKey,
-			Signature:  response.Signature,
+		if err := checkCapabilities(response, capabilities...); err != nil {
+			return nil, 0, OperatorError{Address: operator, Err: err}
 		}
-		if err = identity.Verify(suite); err != nil {
-			return nil, fmt.Errorf("☹️\tthere was an error verifying the identity of operator %s: %w", operator, err)
+		if operatorsRegistry != nil {
+			if err := operatorsRegistry.Pin(identity); err != nil {
+				return nil, 0, OperatorError{Address: operator, Err: err}
+			}
 		}
-
 		identities[i] = identity
+		versions[i] = response.SupportedProtocolVersion()
+	}
+
+	protocolVersion, err := api.NegotiateProtocolVersion(versions...)
+	if err != nil {
+		return nil, 0, err
 	}
-	return identities, nil
+	return identities, protocolVersion, nil
+}
+
+// fetchIdentity fetches the signed public key of a node and verifies its signature.
+// The raw response is also returned, as it advertises the protocol versions and capabilities the node supports
+func fetchIdentity(suite crypto.ThresholdScheme, address string) (crypto.Identity, api.SidecarIdentityResponse, error) {
+	client := api.NewSidecarClientWithTimeout(address, preflightTimeout)
+	response, err := client.Identity()
+	if err != nil {
+		return crypto.Identity{}, api.SidecarIdentityResponse{}, fmt.Errorf("☹️\tthere was an error health-checking %s: %w", address, err)
+	}
+	identity := response.Identity()
+	identity.Address = address
+	if err = identity.Verify(suite); err != nil {
+		return crypto.Identity{}, api.SidecarIdentityResponse{}, fmt.Errorf("☹️\tthere was an error verifying the identity of operator %s: %w", address, err)
+	}
+	if err = identity.CheckExpiry(time.Now()); err != nil {
+		return crypto.Identity{}, api.SidecarIdentityResponse{}, fmt.Errorf("☹️\tthe identity of operator %s is no longer valid: %w", address, err)
+	}
+	return identity, response, nil
+}
+
+// checkCapabilities returns an error naming any of the capabilities the node doesn't advertise
+func checkCapabilities(response api.SidecarIdentityResponse, capabilities ...string) error {
+	var missing []string
+	for _, c := range capabilities {
+		if !response.SupportsCapability(c) {
+			missing = append(missing, c)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("operator does not support %s; it must upgrade its sidecar", strings.Join(missing, ", "))
+	}
+	return nil
 }
 
 func extractEncryptedShares(arr []api.OperatorResponse) []api.OperatorShare {
@@ -160,7 +252,14 @@ func parseOperator(input string) (string, error) {
 	return input, nil
 }
 
-func runDKG(suite crypto.ThresholdScheme, sessionID []byte, identities []crypto.Identity, depositData api.UnsignedDepositData, owner api.OwnerConfig) ([]api.OperatorResponse, error) {
+// dkgRequestTimeout is how long we wait for an operator to respond to a DKG request. Sidecars give up on a DKG
+// after a minute, so an operator taking much longer than that has stopped responding, and we shouldn't wait on it forever
+const dkgRequestTimeout = 2 * time.Minute
+
+// runDKG asks every operator to run the DKG and sign the deposit data.
+// Unless `faultTolerant` is set, it fails if any operator returns an error;
+// otherwise it only fails if fewer than the threshold succeeded
+func runDKG(suite crypto.ThresholdScheme, protocolVersion uint32, sessionNonce []byte, sessionID []byte, identities []crypto.Identity, depositData api.UnsignedDepositData, owner api.OwnerConfig, faultTolerant bool) ([]api.OperatorResponse, error) {
 	dkgResponses := shared.SafeList[api.OperatorResponse]{}
 	errs := make(chan error, len(identities))
 	wg := sync.WaitGroup{}
@@ -168,59 +267,77 @@ func runDKG(suite crypto.ThresholdScheme, sessionID []byte, identities []crypto.
 
 	for _, identity := range identities {
 		go func(identity crypto.Identity) {
-			dkgResponse, err := singleNodeRunDKG(suite, identity, sessionID, identities, depositData, owner)
+			defer wg.Done()
+			dkgResponse, err := singleNodeRunDKG(suite, protocolVersion, identity, sessionNonce, sessionID, identities, depositData, owner, faultTolerant)
 			if err != nil {
-				errs <- err
-			} else {
-				dkgResponses.Append(api.OperatorResponse{
-					Identity: identity,
-					Response: dkgResponse,
-				})
-				wg.Done()
+				errs <- OperatorError{Address: identity.Address, Err: fmt.Errorf("operator %d: %w", identity.OperatorID, err)}
+				return
 			}
+			dkgResponses.Append(api.OperatorResponse{
+				Identity: identity,
+				Response: dkgResponse,
+			})
 		}(identity)
 	}
 
-	// we wait for the DKG to finish
-	done := make(chan struct{})
-	go func() {
-		wg.Wait()
-		close(done)
-	}()
+	// we wait for every operator to finish even if one has already failed,
+	// so none of them are still running this session if the DKG is retried
+	wg.Wait()
+	close(errs)
+	var allErrs []error
+	for err := range errs {
+		allErrs = append(allErrs, err)
+	}
 
-	select {
-	case err := <-errs:
-		return nil, err
-	case <-done:
-		break
+	responses := dkgResponses.Get()
+	if !faultTolerant && len(allErrs) > 0 {
+		return nil, errors.Join(allErrs...)
+	}
+	if threshold := dkg.MinimumT(len(identities)); len(responses) < threshold {
+		return nil, fmt.Errorf("only %d of %d operators completed the DKG, but %d are required: %w", len(responses), len(identities), threshold, errors.Join(allErrs...))
 	}
+	return responses, nil
+}
 
-	return dkgResponses.Get(), nil
+// extractMissingOperators returns the operators that didn't return a response from the DKG
+func extractMissingOperators(identities []crypto.Identity, responses []api.OperatorResponse) []crypto.Identity {
+	var missing []crypto.Identity
+	for _, identity := range identities {
+		if !slices.ContainsFunc(responses, func(r api.OperatorResponse) bool {
+			return r.Identity.OperatorID == identity.OperatorID
+		}) {
+			missing = append(missing, identity)
+		}
+	}
+	return missing
 }
 
-// a sessionID is used in the DKG to avoid replay attacks
-func createSessionID() ([]byte, error) {
-	now := time.Now().Unix()
-	buf := bytes.NewBuffer(make([]byte, binary.MaxVarintLen64))
-	if err := binary.Write(buf, binary.BigEndian, now); err != nil {
-		return nil, err
+// a sessionID is used in the DKG to avoid replay attacks.
+// It's derived from a random nonce and the validator being created, so sidecars can check the binding
+func createSessionID(config api.SignatureConfig, identities []crypto.Identity) ([]byte, []byte, error) {
+	nonce, err := api.NewSessionNonce()
+	if err != nil {
+		return nil, nil, err
 	}
-	s := sha256.New()
-	if _, err := s.Write(buf.Bytes()); err != nil {
-		return nil, err
+	sessionID, err := api.SessionID(nonce, config.Owner, identities, config.DepositData.ForkVersion, config.FaultTolerant)
+	if err != nil {
+		return nil, nil, err
 	}
-	return s.Sum(nil), nil
+	return nonce, sessionID, nil
 }
 
 // singleNodeRunDKG kicks off the DKG for a single node, waits for its response and verifies the necessary fields
-func singleNodeRunDKG(suite crypto.ThresholdScheme, identity crypto.Identity, sessionID []byte, identities []crypto.Identity, depositData api.UnsignedDepositData, owner api.OwnerConfig) (api.SignResponse, error) {
-	client := api.NewSidecarClient(identity.Address)
+func singleNodeRunDKG(suite crypto.ThresholdScheme, protocolVersion uint32, identity crypto.Identity, sessionNonce []byte, sessionID []byte, identities []crypto.Identity, depositData api.UnsignedDepositData, owner api.OwnerConfig, faultTolerant bool) (api.SignResponse, error) {
+	client := api.NewSidecarClientWithTimeout(identity.Address, dkgRequestTimeout)
 
 	data := api.SignRequest{
-		DepositData: depositData,
-		Operators:   identities,
-		SessionID:   sessionID,
-		OwnerConfig: owner,
+		DepositData:     depositData,
+		Operators:       identities,
+		SessionID:       sessionID,
+		SessionNonce:    sessionNonce,
+		OwnerConfig:     owner,
+		FaultTolerant:   faultTolerant,
+		ProtocolVersion: protocolVersion,
 	}
 	response, err := client.Sign(data)
 	if err != nil {
@@ -259,9 +376,10 @@ func signatureResponseVerifies(suite crypto.ThresholdScheme, identity crypto.Ide
 	return nil
 }
 
-// aggregateGroupSignature aggregates partials from all the operators and verifies the output
-func aggregateGroupSignature(suite crypto.ThresholdScheme, partials [][]byte, groupPublicPolynomial []byte, message []byte) ([]byte, error) {
-	signature, err := suite.RecoverSignature(message, groupPublicPolynomial, partials, len(partials))
+// aggregateGroupSignature aggregates partials from the operators and verifies the output.
+// `nodeCount` is the size of the whole group, which may be larger than the number of partials
+func aggregateGroupSignature(suite crypto.ThresholdScheme, partials [][]byte, groupPublicPolynomial []byte, message []byte, nodeCount int) ([]byte, error) {
+	signature, err := suite.RecoverSignature(message, groupPublicPolynomial, partials, nodeCount)
 	if err != nil {
 		return nil, fmt.Errorf("error aggregating signature: %v", err)
 	}
@@ -274,6 +392,20 @@ func aggregateGroupSignature(suite crypto.ThresholdScheme, partials [][]byte, gr
 	return signature, nil
 }
 
+// verifySharePublicKeys checks the public polynomial has the threshold expected for `numOfNodes` operators,
+// and that every operator's share public key is the polynomial evaluated at their index
+func verifySharePublicKeys(suite crypto.ThresholdScheme, publicPolynomial []byte, numOfNodes int, shares []api.OperatorShare) error {
+	if err := crypto.VerifyPolynomialThreshold(suite, publicPolynomial, dkg.MinimumT(numOfNodes)); err != nil {
+		return fmt.Errorf("the group public polynomial was invalid: %w", err)
+	}
+	for _, s := range shares {
+		if err := crypto.VerifySharePublicKey(suite, publicPolynomial, s.Identity.OperatorID, s.SharePublicKey); err != nil {
+			return OperatorError{Address: s.Identity.Address, Err: err}
+		}
+	}
+	return nil
+}
+
 func verifyPublicPolynomialSame(arr []api.OperatorResponse) error {
 	for i := 1; i < len(arr); i++ {
 		// all nodes should return the same group public key or someone is being naughty
@@ -0,0 +1,121 @@
+package cli
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/drand/kyber/share/dkg"
+
+	"github.com/randa-mu/ssv-dkg/shared"
+	"github.com/randa-mu/ssv-dkg/shared/api"
+	"github.com/randa-mu/ssv-dkg/shared/crypto"
+)
+
+// topUpRequestTimeout is how long we wait for an operator to sign a top-up. Each operator only signs with the share
+// it already holds, so one that takes longer is treated as unreachable rather than hanging the top-up
+const topUpRequestTimeout = 30 * time.Second
+
+// TopUp asks the operators of an existing validator cluster to threshold sign a new deposit
+// for the validator's group public key, without running a new DKG.
+// It returns a copy of the state with the deposit data signature replaced by the top-up's
+func TopUp(depositData api.UnsignedDepositData, state api.SigningOutput, log shared.QuietLogger) (api.SigningOutput, error) {
+	if err := depositData.ValidateTopUp(); err != nil {
+		return api.SigningOutput{}, fmt.Errorf("invalid deposit data: %w", err)
+	}
+	// the operators refuse to sign for any other withdrawal credentials, so there's no point asking them
+	if len(state.WithdrawalCredentials) > 0 && !bytes.Equal(state.WithdrawalCredentials, depositData.WithdrawalCredentials) {
+		return api.SigningOutput{}, fmt.Errorf("the validator was created with withdrawal credentials 0x%x, not 0x%x", []byte(state.WithdrawalCredentials), []byte(depositData.WithdrawalCredentials))
+	}
+
+	// operators missing from a fault-tolerant DKG still count towards the size of the group
+	numOfNodes := len(state.OperatorShares) + len(state.MissingOperators)
+	if len(state.OperatorShares) == 0 {
+		return api.SigningOutput{}, errors.New("the state contains no operators")
+	}
+	threshold := dkg.MinimumT(numOfNodes)
+
+	suite := crypto.NewBLSSuite()
+	groupPublicKey := crypto.ExtractGroupPublicKey(suite, shared.Clone(state.GroupPublicPolynomial))
+	message, err := crypto.DepositMessageSigningRoot(depositData.IntoMessage(shared.Clone(groupPublicKey)), depositData.ForkVersion)
+	if err != nil {
+		return api.SigningOutput{}, fmt.Errorf("failed to create deposit data message: %w", err)
+	}
+
+	log.MaybeLog("⏳ requesting partial signatures from operators")
+	partials := collectTopUpPartials(suite, state, depositData, message, log)
+	if len(partials) < threshold {
+		return api.SigningOutput{}, fmt.Errorf("only %d of %d operators signed the top-up, but %d are required", len(partials), numOfNodes, threshold)
+	}
+
+	signature, err := suite.RecoverSignature(message, state.GroupPublicPolynomial, partials, numOfNodes)
+	if err != nil {
+		return api.SigningOutput{}, fmt.Errorf("error aggregating top-up signature: %w", err)
+	}
+	if err = suite.Verify(message, groupPublicKey, signature); err != nil {
+		return api.SigningOutput{}, fmt.Errorf("failed to verify top-up signature: %w", err)
+	}
+
+	output := state
+	output.DepositDataSignature = signature
+	return output, nil
+}
+
+// collectTopUpPartials requests a partial signature from every operator in parallel,
+// dropping any that error or don't verify, as we only need a threshold of them
+func collectTopUpPartials(suite crypto.ThresholdScheme, state api.SigningOutput, depositData api.UnsignedDepositData, message []byte, log shared.QuietLogger) [][]byte {
+	partials := shared.SafeList[[]byte]{}
+	wg := sync.WaitGroup{}
+	wg.Add(len(state.OperatorShares))
+
+	for _, operatorShare := range state.OperatorShares {
+		go func(operatorShare api.OperatorShare) {
+			defer wg.Done()
+			encryptedShareHash := sha256.Sum256(operatorShare.EncryptedShare)
+			client := api.NewSidecarClientWithTimeout(operatorShare.Identity.Address, topUpRequestTimeout)
+
+			// operators don't talk to each other during a top-up, so each can use its own protocol version
+			protocolVersion, err := negotiateCapability(client, api.CapabilityTopUp, api.CapabilityValidatorBinding)
+			if err != nil {
+				log.MaybeLog(fmt.Sprintf("⚠️  operator %d can't sign the top-up: %v", operatorShare.Identity.OperatorID, err))
+				return
+			}
+			response, err := client.TopUp(api.TopUpRequest{
+				SessionID:          hex.EncodeToString(state.SessionID),
+				EncryptedShareHash: encryptedShareHash[:],
+				DepositData:        depositData,
+				ProtocolVersion:    protocolVersion,
+			})
+			if err != nil {
+				log.MaybeLog(fmt.Sprintf("⚠️  operator %d failed to sign the top-up: %v", operatorShare.Identity.OperatorID, err))
+				return
+			}
+
+			if err = suite.VerifyPartial(state.GroupPublicPolynomial, message, response.DepositDataPartialSignature); err != nil {
+				log.MaybeLog(fmt.Sprintf("⚠️  operator %d returned an invalid partial signature: %v", operatorShare.Identity.OperatorID, err))
+				return
+			}
+			partials.Append(response.DepositDataPartialSignature)
+		}(operatorShare)
+	}
+
+	wg.Wait()
+	return partials.Get()
+}
+
+// negotiateCapability checks the operator supports the capabilities and returns the protocol version to use with it,
+// for requests that only involve a single operator
+func negotiateCapability(client api.Sidecar, capabilities ...string) (uint32, error) {
+	identity, err := client.Identity()
+	if err != nil {
+		return 0, err
+	}
+	if err := checkCapabilities(identity, capabilities...); err != nil {
+		return 0, err
+	}
+	return api.NegotiateProtocolVersion(identity.SupportedProtocolVersion())
+}
@@ -15,7 +15,9 @@ require (
 	github.com/spf13/cobra v1.8.1
 	github.com/ssvlabs/ssv v1.2.1-0.20250204135044-7fcd336c827f
 	github.com/stretchr/testify v1.10.0
+	golang.org/x/crypto v0.32.0
 	golang.org/x/exp v0.0.0-20241108190413-2d47ceb2692f
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -42,10 +44,8 @@ require (
 	github.com/prysmaticlabs/go-bitfield v0.0.0-20240618144021-706c95b2dd15 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
 	github.com/ssvlabs/ssv-spec v1.0.2 // indirect
-	golang.org/x/crypto v0.32.0 // indirect
 	golang.org/x/sys v0.29.0 // indirect
 	golang.org/x/xerrors v0.0.0-20240903120638-7835f813f4da // indirect
 	gopkg.in/check.v1 v1.0.0-20201130134442-10cb98267c6c // indirect
 	gopkg.in/yaml.v2 v2.4.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
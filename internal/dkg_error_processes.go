@@ -10,11 +10,11 @@ import (
 
 type ErrorStartingDKG struct{}
 
-func (e ErrorStartingDKG) RunDKG(identities []crypto.Identity, sessionID []byte, keypair crypto.Keypair) (*dkg.Output, error) {
+func (e ErrorStartingDKG) RunDKG(identities []crypto.Identity, sessionID []byte, keypair crypto.Keypair, protocolVersion uint32, faultTolerant bool) (*dkg.Output, error) {
 	return nil, errors.New("simulated error starting DKG")
 }
 
-func (e ErrorStartingDKG) RunReshare(identities []crypto.Identity, sessionID []byte, keypair crypto.Keypair, state dkg.GroupFile) (*dkg.Output, error) {
+func (e ErrorStartingDKG) RunReshare(identities []crypto.Identity, sessionID []byte, keypair crypto.Keypair, state dkg.GroupFile, newOwner []byte, protocolVersion uint32) (*dkg.Output, error) {
 	return nil, errors.New("simulated error running reshare")
 }
 
@@ -27,12 +27,12 @@ type ErrorDuringDKG struct {
 	scheme crypto.ThresholdScheme
 }
 
-func (e ErrorDuringDKG) RunDKG(identities []crypto.Identity, sessionID []byte, keypair crypto.Keypair) (*dkg.Output, error) {
+func (e ErrorDuringDKG) RunDKG(identities []crypto.Identity, sessionID []byte, keypair crypto.Keypair, protocolVersion uint32, faultTolerant bool) (*dkg.Output, error) {
 	d := dkg.NewDKGCoordinator(e.url, e.scheme)
-	return d.RunDKG(identities, sessionID, keypair)
+	return d.RunDKG(identities, sessionID, keypair, protocolVersion, faultTolerant)
 }
 
-func (e ErrorDuringDKG) RunReshare(identities []crypto.Identity, sessionID []byte, keypair crypto.Keypair, state dkg.GroupFile) (*dkg.Output, error) {
+func (e ErrorDuringDKG) RunReshare(identities []crypto.Identity, sessionID []byte, keypair crypto.Keypair, state dkg.GroupFile, newOwner []byte, protocolVersion uint32) (*dkg.Output, error) {
 	return nil, errors.New("simulated error running reshare")
 }
 
@@ -14,6 +14,7 @@ import (
 	"github.com/randa-mu/ssv-dkg/shared/api"
 	"github.com/randa-mu/ssv-dkg/shared/crypto"
 	"github.com/randa-mu/ssv-dkg/shared/files"
+	"github.com/randa-mu/ssv-dkg/shared/network"
 	"github.com/randa-mu/ssv-dkg/sidecar"
 	"github.com/stretchr/testify/require"
 )
@@ -43,7 +44,7 @@ func TestPrintHolesky(t *testing.T) {
 			Address:        ownerAddress,
 			ValidatorNonce: validatorNonce,
 		},
-		SsvClient: api.HoleskySsvClient(),
+		SsvClient: api.NewSsvClient(network.Holesky.SsvApiUrl),
 	}
 	res, err := cli.Sign(config, shared.QuietLogger{Quiet: false})
 	require.NoError(t, err)
@@ -81,14 +82,14 @@ func createHoleskyDaemon(t *testing.T, index int) sidecar.Daemon {
 		t.Fatal(err)
 	}
 	url := fmt.Sprintf("http://127.0.0.1:888%d", index)
-	_, err = sidecar.SignKey(url, stateDir, uint32(port))
+	_, err = sidecar.SignKey(url, stateDir, uint32(port), time.Hour, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	keyPath := fmt.Sprintf("encrypted_private_key%d.json", index)
 	operatorId := 1450 + index
-	d, err := sidecar.NewDaemon(uint(port), url, stateDir, keyPath, uint32(operatorId))
+	d, err := sidecar.NewDaemon(uint(port), url, stateDir, keyPath, uint32(operatorId), []network.Network{network.Holesky})
 	if err != nil {
 		t.Fatal(err)
 	}
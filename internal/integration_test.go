@@ -1,20 +1,29 @@
 package internal
 
 import (
+	"crypto/ecdsa"
+	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path"
 	"strconv"
 	"testing"
 	"time"
 
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/slices"
 
 	"github.com/randa-mu/ssv-dkg/cli"
 	"github.com/randa-mu/ssv-dkg/shared"
 	"github.com/randa-mu/ssv-dkg/shared/api"
 	"github.com/randa-mu/ssv-dkg/shared/crypto"
+	"github.com/randa-mu/ssv-dkg/shared/network"
+	"github.com/randa-mu/ssv-dkg/shared/registry"
 	"github.com/randa-mu/ssv-dkg/sidecar"
 )
 
@@ -47,15 +56,23 @@ func TestSuccessfulSigningAndResharing(t *testing.T) {
 	require.NotEmpty(t, signingOutput.GroupPublicPolynomial)
 	require.NotEmpty(t, signingOutput.OperatorShares)
 
-	signingOutput, err = cli.Reshare(operators, signingOutput, log)
+	// the validator must be registered again with the next nonce once its shares have moved
+	owner := api.OwnerConfig{ValidatorNonce: 2, Address: address}
+	signingOutput, err = cli.Reshare(operators, signingOutput, owner, nil, log)
 	require.NoError(t, err)
 	require.NotEmpty(t, signingOutput)
 	require.NotEmpty(t, signingOutput.DepositDataSignature)
 	require.NotEmpty(t, signingOutput.GroupPublicPolynomial)
 	require.NotEmpty(t, signingOutput.OperatorShares)
 
+	suite := crypto.NewBLSSuite()
+	nonceMessage, err := crypto.ValidatorNonceMessage(owner.Address, owner.ValidatorNonce)
+	require.NoError(t, err)
+	groupPublicKey := crypto.ExtractGroupPublicKey(suite, signingOutput.GroupPublicPolynomial)
+	require.NoError(t, suite.Verify(nonceMessage, groupPublicKey, signingOutput.ValidatorNonceSignature))
+
 	// reshare a second time with the same group just to confirm the polynomial commitments have been saved as expected
-	signingOutput, err = cli.Reshare(operators, signingOutput, log)
+	signingOutput, err = cli.Reshare(operators, signingOutput, args.Owner, nil, log)
 	require.NoError(t, err)
 	require.NotEmpty(t, signingOutput)
 	require.NotEmpty(t, signingOutput.DepositDataSignature)
@@ -65,7 +82,7 @@ func TestSuccessfulSigningAndResharing(t *testing.T) {
 	// reshare a third time with a slightly different group
 	startSidecars(t, []uint{10005})
 	operators = append(operators[0:3], "http://127.0.0.1:10005")
-	signingOutput, err = cli.Reshare(operators, signingOutput, log)
+	signingOutput, err = cli.Reshare(operators, signingOutput, args.Owner, nil, log)
 	require.NoError(t, err)
 	require.NotEmpty(t, signingOutput)
 	require.NotEmpty(t, signingOutput.DepositDataSignature)
@@ -105,7 +122,7 @@ func TestResharingNewNode(t *testing.T) {
 	// reshare a third time with a slightly different group
 	startSidecars(t, []uint{10006})
 	operators = append(operators[0:3], "http://127.0.0.1:10006")
-	signingOutput, err = cli.Reshare(operators, signingOutput, log)
+	signingOutput, err = cli.Reshare(operators, signingOutput, args.Owner, nil, log)
 	require.NoError(t, err)
 	require.NotEmpty(t, signingOutput)
 	require.NotEmpty(t, signingOutput.DepositDataSignature)
@@ -113,6 +130,492 @@ func TestResharingNewNode(t *testing.T) {
 	require.NotEmpty(t, signingOutput.OperatorShares)
 }
 
+func TestTopUp(t *testing.T) {
+	ports := []uint{10031, 10032, 10033, 10034}
+	startSidecars(t, ports)
+
+	operators := fmap(ports, func(o uint) string {
+		return fmt.Sprintf("http://127.0.0.1:%d", o)
+	})
+
+	log := shared.QuietLogger{Quiet: false}
+	address, err := hex.DecodeString("aA184b86B4cdb747F4A3BF6e6FCd5e27c1d92c5c")
+	require.NoError(t, err)
+	args := api.SignatureConfig{
+		Operators:   operators,
+		DepositData: createUnsignedDepositData(),
+		Owner: api.OwnerConfig{
+			ValidatorNonce: 0,
+			Address:        address,
+		},
+	}
+	signingOutput, err := cli.Sign(args, log)
+	require.NoError(t, err)
+
+	topUp := createUnsignedDepositData()
+	topUp.Amount = crypto.GweiPerEth
+	topUpOutput, err := cli.TopUp(topUp, signingOutput, log)
+	require.NoError(t, err)
+	require.Equal(t, signingOutput.GroupPublicPolynomial, topUpOutput.GroupPublicPolynomial)
+	require.NotEqual(t, signingOutput.DepositDataSignature, topUpOutput.DepositDataSignature)
+
+	suite := crypto.NewBLSSuite()
+	groupPublicKey := crypto.ExtractGroupPublicKey(suite, signingOutput.GroupPublicPolynomial)
+	message, err := crypto.DepositMessageSigningRoot(topUp.IntoMessage(groupPublicKey), topUp.ForkVersion)
+	require.NoError(t, err)
+	require.NoError(t, suite.Verify(message, groupPublicKey, topUpOutput.DepositDataSignature))
+
+	// a top-up for more than the credentials allow should be rejected by the sidecars
+	topUp.Amount = crypto.MaxEffectiveBalance
+	_, err = cli.TopUp(topUp, signingOutput, log)
+	require.Error(t, err)
+
+	// as should a top-up for withdrawal credentials other than the validator's, even if the client doesn't check them
+	topUp.Amount = crypto.GweiPerEth
+	forged := topUp
+	forged.WithdrawalCredentials = slices.Clone(topUp.WithdrawalCredentials)
+	forged.WithdrawalCredentials[len(forged.WithdrawalCredentials)-1] ^= 0xff
+	_, err = cli.TopUp(forged, signingOutput, log)
+	require.ErrorContains(t, err, "the validator was created with withdrawal credentials")
+	unchecked := signingOutput
+	unchecked.WithdrawalCredentials = nil
+	_, err = cli.TopUp(forged, unchecked, log)
+	require.ErrorContains(t, err, "only 0 of 4 operators signed the top-up")
+}
+
+func TestTopUpAfterReshareKeepsWithdrawalCredentials(t *testing.T) {
+	ports := []uint{10035, 10036, 10037, 10038, 10039}
+	startSidecars(t, ports)
+
+	operators := fmap(ports, func(o uint) string {
+		return fmt.Sprintf("http://127.0.0.1:%d", o)
+	})
+
+	log := shared.QuietLogger{Quiet: false}
+	address, err := hex.DecodeString("aA184b86B4cdb747F4A3BF6e6FCd5e27c1d92c5c")
+	require.NoError(t, err)
+	args := api.SignatureConfig{
+		Operators:   operators[0:4],
+		DepositData: createUnsignedDepositData(),
+		Owner: api.OwnerConfig{
+			ValidatorNonce: 0,
+			Address:        address,
+		},
+	}
+	signingOutput, err := cli.Sign(args, log)
+	require.NoError(t, err)
+	require.Equal(t, args.DepositData.WithdrawalCredentials, signingOutput.WithdrawalCredentials)
+
+	// the operator joining in the reshare learns the withdrawal credentials from the operators already holding shares
+	args.Owner.ValidatorNonce = 1
+	reshared, err := cli.Reshare(operators[1:5], signingOutput, args.Owner, nil, log)
+	require.NoError(t, err)
+	require.Equal(t, signingOutput.WithdrawalCredentials, reshared.WithdrawalCredentials)
+
+	// so it can sign a top-up for them; leaving another operator out means the top-up needs its partial signature
+	topUp := createUnsignedDepositData()
+	topUp.Amount = crypto.GweiPerEth
+	needsJoiner := reshared
+	needsJoiner.OperatorShares = nil
+	for _, share := range reshared.OperatorShares {
+		if share.Identity.OperatorID == 10036 {
+			needsJoiner.MissingOperators = append(needsJoiner.MissingOperators, share.Identity)
+		} else {
+			needsJoiner.OperatorShares = append(needsJoiner.OperatorShares, share)
+		}
+	}
+	_, err = cli.TopUp(topUp, needsJoiner, log)
+	require.NoError(t, err)
+
+	// a reshare can't change the withdrawal credentials either
+	forged := reshared
+	forged.WithdrawalCredentials = slices.Clone(reshared.WithdrawalCredentials)
+	forged.WithdrawalCredentials[len(forged.WithdrawalCredentials)-1] ^= 0xff
+	args.Owner.ValidatorNonce = 2
+	_, err = cli.Reshare(operators[1:5], forged, args.Owner, nil, log)
+	require.Error(t, err)
+}
+
+func TestFaultTolerantSigningAndResharing(t *testing.T) {
+	ports := []uint{10051, 10052, 10053}
+	startSidecars(t, ports)
+	startErrorSidecars(t, []uint{10054}, ErrorStartingDKG{})
+
+	operators := fmap(append(ports, 10054), func(o uint) string {
+		return fmt.Sprintf("http://127.0.0.1:%d", o)
+	})
+
+	log := shared.QuietLogger{Quiet: false}
+	address, err := hex.DecodeString("deadbeef")
+	require.NoError(t, err)
+	args := api.SignatureConfig{
+		Operators:   operators,
+		DepositData: createUnsignedDepositData(),
+		Owner: api.OwnerConfig{
+			ValidatorNonce: 0,
+			Address:        address,
+		},
+	}
+
+	// without opting in, a single failing operator fails the whole ceremony
+	_, err = cli.Sign(args, log)
+	require.Error(t, err)
+
+	args.FaultTolerant = true
+	signingOutput, err := cli.Sign(args, log)
+	require.NoError(t, err)
+	require.Len(t, signingOutput.OperatorShares, 3)
+	require.Len(t, signingOutput.MissingOperators, 1)
+	require.Equal(t, uint32(10054), signingOutput.MissingOperators[0].OperatorID)
+
+	// the missing operator can then be replaced by resharing to a new one
+	startSidecars(t, []uint{10055})
+	newOperators := fmap([]uint{10051, 10052, 10053, 10055}, func(o uint) string {
+		return fmt.Sprintf("http://127.0.0.1:%d", o)
+	})
+	reshareOutput, err := cli.Reshare(newOperators, signingOutput, args.Owner, nil, log)
+	require.NoError(t, err)
+	require.Len(t, reshareOutput.OperatorShares, 4)
+	require.Empty(t, reshareOutput.MissingOperators)
+}
+
+func TestSigningRetriesWithSpareOperator(t *testing.T) {
+	ports := []uint{10061, 10062, 10063}
+	startSidecars(t, ports)
+	startErrorSidecars(t, []uint{10064}, ErrorStartingDKG{})
+	startSidecars(t, []uint{10065})
+
+	operators := fmap(append(ports, 10064), func(o uint) string {
+		return fmt.Sprintf("http://127.0.0.1:%d", o)
+	})
+
+	address, err := hex.DecodeString("deadbeef")
+	require.NoError(t, err)
+	args := api.SignatureConfig{
+		Operators:   operators,
+		DepositData: createUnsignedDepositData(),
+		Owner: api.OwnerConfig{
+			ValidatorNonce: 0,
+			Address:        address,
+		},
+		SpareOperators: []string{"http://127.0.0.1:10065"},
+		MaxAttempts:    2,
+	}
+	signingOutput, err := cli.Sign(args, shared.QuietLogger{Quiet: false})
+	require.NoError(t, err)
+
+	operatorIDs := fmap(signingOutput.OperatorShares, func(o api.OperatorShare) uint32 {
+		return o.Identity.OperatorID
+	})
+	require.ElementsMatch(t, []uint32{10061, 10062, 10063, 10065}, operatorIDs)
+}
+
+func TestSigningDoesNotReplaceOperatorsForSharedFailure(t *testing.T) {
+	ports := []uint{10218, 10219, 10220, 10221}
+	startErrorSidecars(t, ports, ErrorStartingDKG{})
+	startSidecars(t, []uint{10222})
+
+	operators := fmap(ports, func(o uint) string {
+		return fmt.Sprintf("http://127.0.0.1:%d", o)
+	})
+	args := api.SignatureConfig{
+		Operators:   operators,
+		DepositData: createUnsignedDepositData(),
+		Owner: api.OwnerConfig{
+			ValidatorNonce: 0,
+			Address:        []byte{0xde, 0xad, 0xbe, 0xef},
+		},
+		SpareOperators: []string{"http://127.0.0.1:10222"},
+		MaxAttempts:    2,
+	}
+
+	// every operator fails in the same way, so none of them can be blamed and replaced by the spare
+	_, err := cli.Sign(args, shared.QuietLogger{Quiet: false})
+	require.ErrorContains(t, err, "could not work out which operator caused the failure")
+}
+
+func TestPreflightFailsForOperatorMissingFromRegistry(t *testing.T) {
+	ports := []uint{10071, 10072, 10073, 10074}
+	startSidecars(t, ports)
+
+	operators := fmap(ports, func(o uint) string {
+		return fmt.Sprintf("http://127.0.0.1:%d", o)
+	})
+
+	// the registry contains every operator except the last
+	registered := registryEntries(t, operators[:3])
+
+	address, err := hex.DecodeString("deadbeef")
+	require.NoError(t, err)
+	args := api.SignatureConfig{
+		Operators:   operators,
+		DepositData: createUnsignedDepositData(),
+		Owner: api.OwnerConfig{
+			ValidatorNonce: 0,
+			Address:        address,
+		},
+		Registry: &registry.Registry{Operators: registered},
+	}
+	_, err = cli.Sign(args, shared.QuietLogger{Quiet: false})
+	require.Error(t, err)
+
+	var preflightErr cli.PreflightError
+	require.ErrorAs(t, err, &preflightErr)
+	require.Equal(t, []string{operators[3]}, preflightErr.FailingOperators())
+}
+
+func TestPreflightFailsForLegacySidecar(t *testing.T) {
+	ports := []uint{10215, 10216, 10217}
+	startSidecars(t, ports)
+	operators := fmap(ports, func(o uint) string {
+		return fmt.Sprintf("http://127.0.0.1:%d", o)
+	})
+
+	// legacy sidecars return an empty health response and have no identity endpoint
+	legacy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != api.SidecarHealthPath {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(legacy.Close)
+	operators = append(operators, legacy.URL)
+
+	args := api.SignatureConfig{
+		Operators:   operators,
+		DepositData: createUnsignedDepositData(),
+		Owner:       api.OwnerConfig{Address: []byte{0xde, 0xad, 0xbe, 0xef}},
+	}
+	_, err := cli.Sign(args, shared.QuietLogger{Quiet: false})
+
+	var preflightErr cli.PreflightError
+	require.ErrorAs(t, err, &preflightErr)
+	require.Equal(t, []string{legacy.URL}, preflightErr.FailingOperators())
+	require.ErrorContains(t, err, "too old to report its version")
+}
+
+func TestPreflightFailsForOperatorNotSignedBySsvKey(t *testing.T) {
+	ports := []uint{10121, 10122, 10123, 10124}
+	ssvKeys := startSidecarsWithSsvKeys(t, ports)
+	ssvApi := startSsvApi(t, ssvKeys)
+
+	operators := fmap(ports, func(o uint) string {
+		return fmt.Sprintf("http://127.0.0.1:%d", o)
+	})
+
+	// every operator's registry entry is signed by its key on SSV
+	registered := registryEntries(t, operators)
+	for i := range registered {
+		var err error
+		registered[i].Identity, err = registered[i].SignWithSsvKey(ssvKeys[ports[i]].Private)
+		require.NoError(t, err)
+	}
+
+	// except the last, which is signed by some other key
+	impostor, err := crypto.NewRSASuite().CreateKeypair()
+	require.NoError(t, err)
+	registered[3].Identity, err = registered[3].SignWithSsvKey(impostor.Private)
+	require.NoError(t, err)
+
+	args := ssvSignatureConfig(t, operators, ssvApi)
+	args.Registry = &registry.Registry{Operators: registered}
+	_, err = cli.Sign(args, shared.QuietLogger{Quiet: false})
+	require.Error(t, err)
+
+	var preflightErr cli.PreflightError
+	require.ErrorAs(t, err, &preflightErr)
+	require.Equal(t, []string{operators[3]}, preflightErr.FailingOperators())
+
+	// operators whose entry isn't signed at all also fail when the binding is required
+	registered[3].SsvSignature = nil
+	args.RequireSsvBinding = true
+	_, err = cli.Sign(args, shared.QuietLogger{Quiet: false})
+	require.ErrorAs(t, err, &preflightErr)
+	require.Equal(t, []string{operators[3]}, preflightErr.FailingOperators())
+	require.ErrorContains(t, err, "registry entry has no SSV signature")
+}
+
+func TestPreflightFailsForOperatorWithMisconfiguredSsvKey(t *testing.T) {
+	ports := []uint{10131, 10132, 10133, 10134}
+	ssvKeys := startSidecarsWithSsvKeys(t, ports)
+
+	// the last operator is registered on SSV with a different key to the one its sidecar encrypts to
+	otherKey, err := crypto.NewRSASuite().CreateKeypair()
+	require.NoError(t, err)
+	ssvKeys[ports[3]] = otherKey
+	ssvApi := startSsvApi(t, ssvKeys)
+
+	operators := fmap(ports, func(o uint) string {
+		return fmt.Sprintf("http://127.0.0.1:%d", o)
+	})
+	// requiring the binding doesn't fail the others, as there's no registry to bind them with
+	args := ssvSignatureConfig(t, operators, ssvApi)
+	args.RequireSsvBinding = true
+	_, err = cli.Sign(args, shared.QuietLogger{Quiet: false})
+	require.Error(t, err)
+
+	var preflightErr cli.PreflightError
+	require.ErrorAs(t, err, &preflightErr)
+	require.Equal(t, []string{operators[3]}, preflightErr.FailingOperators())
+}
+
+// startSidecarsWithSsvKeys starts sidecars that each encrypt shares to their own SSV key, returning the keys by port
+func startSidecarsWithSsvKeys(t *testing.T, ports []uint) map[uint]crypto.Keypair {
+	keys := make(map[uint]crypto.Keypair)
+	for _, port := range ports {
+		kp, err := crypto.NewRSASuite().CreateKeypair()
+		require.NoError(t, err)
+		keys[port] = kp
+
+		stateDir := path.Join(t.TempDir(), strconv.Itoa(int(port)))
+		require.NoError(t, os.MkdirAll(stateDir, 0o755))
+		keyFile, err := json.Marshal(map[string][]byte{"pubKey": kp.Public})
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(path.Join(stateDir, "pub.json"), keyFile, 0o644))
+		startDaemonInDir(t, port, stateDir)
+	}
+	return keys
+}
+
+// startSsvApi serves the public keys of the SSV operators, whose IDs are their ports
+func startSsvApi(t *testing.T, keys map[uint]crypto.Keypair) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for port, kp := range keys {
+			if r.URL.Path == fmt.Sprintf("/operators/%d", port) {
+				_ = json.NewEncoder(w).Encode(api.SsvApiResponse{PublicKey: kp.Public})
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func ssvSignatureConfig(t *testing.T, operators []string, ssvApi *httptest.Server) api.SignatureConfig {
+	address, err := hex.DecodeString("deadbeef")
+	require.NoError(t, err)
+	return api.SignatureConfig{
+		Operators:   operators,
+		DepositData: createUnsignedDepositData(),
+		Owner: api.OwnerConfig{
+			ValidatorNonce: 0,
+			Address:        address,
+		},
+		SsvClient: api.NewSsvClient(ssvApi.URL),
+	}
+}
+
+func TestReshareFailsForOperatorNotMatchingRegistry(t *testing.T) {
+	ports := []uint{10091, 10092, 10093, 10094}
+	startSidecars(t, ports)
+
+	operators := fmap(ports, func(o uint) string {
+		return fmt.Sprintf("http://127.0.0.1:%d", o)
+	})
+
+	// the last operator is registered at a different address to the one it's serving from
+	registered := registryEntries(t, operators)
+	registered[3].Address = "http://127.0.0.1:10095"
+
+	_, err := cli.Reshare(operators, api.SigningOutput{}, api.OwnerConfig{}, &registry.Registry{Operators: registered}, shared.QuietLogger{Quiet: false})
+	require.Error(t, err)
+
+	var operatorErr cli.OperatorError
+	require.ErrorAs(t, err, &operatorErr)
+	require.Equal(t, operators[3], operatorErr.Address)
+}
+
+func TestSelectOperators(t *testing.T) {
+	ports := []uint{10101, 10102, 10103, 10104, 10105}
+	startSidecars(t, ports)
+
+	operators := fmap(ports, func(o uint) string {
+		return fmt.Sprintf("http://127.0.0.1:%d", o)
+	})
+
+	// the registry also lists an operator that isn't running
+	registered := registryEntries(t, operators)
+	offline := registered[0]
+	offline.OperatorID = 10106
+	offline.Address = "http://127.0.0.1:10106"
+	registered = append(registered, offline)
+
+	probes := cli.ProbeOperators(registered, time.Second)
+	selected, err := cli.SelectOperators(probes, cli.SelectionCriteria{Count: 4, Exclude: []uint32{10102}})
+	require.NoError(t, err)
+	selectedIDs := fmap(selected, func(o registry.Operator) uint32 {
+		return o.OperatorID
+	})
+	require.Equal(t, []uint32{10101, 10103, 10104, 10105}, selectedIDs)
+
+	// there aren't enough healthy operators left once two are excluded
+	_, err = cli.SelectOperators(probes, cli.SelectionCriteria{Count: 4, Exclude: []uint32{10102, 10103}})
+	require.Error(t, err)
+
+	// operators can't be required if they're offline
+	_, err = cli.SelectOperators(probes, cli.SelectionCriteria{Count: 4, Require: []uint32{10106}})
+	require.Error(t, err)
+
+	// operators serving a different key to the one registered are unhealthy
+	tampered := registered[0]
+	tampered.Public = registered[1].Public
+	probes = cli.ProbeOperators([]registry.Operator{registered[0], tampered}, time.Second)
+	require.True(t, probes[0].Healthy())
+	require.Equal(t, api.ProtocolVersion, probes[0].ProtocolVersion)
+	require.False(t, probes[1].Healthy())
+	require.Error(t, probes[1].IdentityErr)
+}
+
+func TestSigningForUnsupportedNetwork(t *testing.T) {
+	ports := []uint{10041, 10042, 10043}
+	startSidecars(t, ports)
+
+	operators := fmap(ports, func(o uint) string {
+		return fmt.Sprintf("http://127.0.0.1:%d", o)
+	})
+
+	// the sidecars are only configured for holesky
+	depositData := createUnsignedDepositData()
+	depositData.ForkVersion = network.Mainnet.GenesisForkVersion
+	depositData.NetworkName = network.Mainnet.Name
+	address, err := hex.DecodeString("deadbeef")
+	require.NoError(t, err)
+	args := api.SignatureConfig{
+		Operators:   operators,
+		DepositData: depositData,
+		Owner: api.OwnerConfig{
+			ValidatorNonce: 0,
+			Address:        address,
+		},
+	}
+	_, err = cli.Sign(args, shared.QuietLogger{Quiet: false})
+	require.Error(t, err)
+}
+
+func TestSidecarNegotiatesProtocolVersion(t *testing.T) {
+	ports := []uint{10081}
+	startSidecars(t, ports)
+	client := api.NewSidecarClient("http://127.0.0.1:10081")
+
+	identity, err := client.Identity()
+	require.NoError(t, err)
+	require.Equal(t, api.ProtocolVersion, identity.SupportedProtocolVersion())
+	require.True(t, identity.SupportsCapability(api.CapabilityFaultTolerant))
+
+	// requests from a newer CLI speaking a version the sidecar doesn't know are rejected before any DKG starts
+	_, err = client.Sign(api.SignRequest{
+		DepositData:     createUnsignedDepositData(),
+		ProtocolVersion: api.ProtocolVersion + 1,
+	})
+	require.Error(t, err)
+
+	// as are requests from legacy CLIs, which don't state a protocol version
+	_, err = client.Sign(api.SignRequest{
+		DepositData: createUnsignedDepositData(),
+	})
+	require.Error(t, err)
+}
+
 func TestErroneousNodeOnStartup(t *testing.T) {
 	ports := []uint{10011, 10012, 10013}
 	startSidecars(t, ports)
@@ -161,6 +664,373 @@ func TestErroneousNodeOnRunningDKG(t *testing.T) {
 	require.Error(t, err)
 }
 
+// registryEntries creates registry entries from the identities the operators report
+func registryEntries(t *testing.T, operators []string) []registry.Operator {
+	suite := crypto.NewBLSSuite()
+	entries := make([]registry.Operator, len(operators))
+	for i, o := range operators {
+		response, err := api.NewSidecarClient(o).Identity()
+		require.NoError(t, err)
+		identity := response.Identity()
+		require.NoError(t, identity.Verify(suite))
+		entries[i] = registry.Operator{Identity: identity}
+	}
+	return entries
+}
+
+func TestReshareAfterKeyRotation(t *testing.T) {
+	ports := []uint{10111, 10112, 10113}
+	startSidecars(t, ports)
+
+	// the last operator is started by hand, so it can be restarted with a rotated key
+	rotatingPort := uint(10114)
+	stateDir := path.Join(t.TempDir(), strconv.Itoa(int(rotatingPort)))
+	rotating := startDaemonInDir(t, rotatingPort, stateDir)
+
+	operators := fmap(append(ports, rotatingPort), func(o uint) string {
+		return fmt.Sprintf("http://127.0.0.1:%d", o)
+	})
+	log := shared.QuietLogger{Quiet: false}
+	address, err := hex.DecodeString("aA184b86B4cdb747F4A3BF6e6FCd5e27c1d92c5c")
+	require.NoError(t, err)
+	args := api.SignatureConfig{
+		Operators:   operators,
+		DepositData: createUnsignedDepositData(),
+		Owner: api.OwnerConfig{
+			ValidatorNonce: 0,
+			Address:        address,
+		},
+	}
+	signingOutput, err := cli.Sign(args, log)
+	require.NoError(t, err)
+
+	rotating.Stop()
+	signedIdentity, err := sidecar.RotateKey(operators[3], stateDir, uint32(rotatingPort), time.Hour, nil)
+	require.NoError(t, err)
+	startDaemonInDir(t, rotatingPort, stateDir)
+
+	// the operator now serves its new key
+	response, err := api.NewSidecarClient(operators[3]).Identity()
+	require.NoError(t, err)
+	var rotated crypto.Identity
+	require.NoError(t, json.Unmarshal(signedIdentity, &rotated))
+	require.Equal(t, rotated.Public, response.Identity().Public)
+	oldKey := keyOf(t, signingOutput, uint32(rotatingPort))
+	require.NotEqual(t, oldKey, rotated.Public)
+
+	// but can still reshare the validator created with its old one
+	reshared, err := cli.Reshare(operators, signingOutput, args.Owner, nil, log)
+	require.NoError(t, err)
+	require.Equal(t, oldKey, keyOf(t, reshared, uint32(rotatingPort)))
+
+	// unless the old key has been revoked, in which case the operator has to leave and rejoin with its new key
+	revoked := registry.Registry{
+		Operators:   registryEntries(t, operators),
+		Revocations: []registry.Revocation{{Public: oldKey, Reason: "compromised"}},
+	}
+	_, err = cli.Reshare(operators, reshared, args.Owner, &revoked, log)
+	require.ErrorContains(t, err, "previous key can't be used to reshare")
+
+	// and new validators use the new key
+	args.Owner.ValidatorNonce = 1
+	newOutput, err := cli.Sign(args, log)
+	require.NoError(t, err)
+	require.Equal(t, []byte(rotated.Public), keyOf(t, newOutput, uint32(rotatingPort)))
+}
+
+func TestReshareOnlyTransfersOwnerWithAuthorisation(t *testing.T) {
+	ports := []uint{10223, 10224, 10225, 10226}
+	startSidecars(t, ports)
+	operators := fmap(ports, func(o uint) string {
+		return fmt.Sprintf("http://127.0.0.1:%d", o)
+	})
+
+	ownerKey, err := ethcrypto.GenerateKey()
+	require.NoError(t, err)
+	newOwnerKey, err := ethcrypto.GenerateKey()
+	require.NoError(t, err)
+	owner := ethcrypto.PubkeyToAddress(ownerKey.PublicKey).Bytes()
+	newOwner := ethcrypto.PubkeyToAddress(newOwnerKey.PublicKey).Bytes()
+
+	log := shared.QuietLogger{Quiet: false}
+	args := api.SignatureConfig{
+		Operators:   operators,
+		DepositData: createUnsignedDepositData(),
+		Owner: api.OwnerConfig{
+			ValidatorNonce: 0,
+			Address:        owner,
+		},
+	}
+	signingOutput, err := cli.Sign(args, log)
+	require.NoError(t, err)
+	require.Equal(t, owner, []byte(signingOutput.Owner))
+
+	// the operators won't sign the validator nonce of a new owner the current owner hasn't authorised
+	transfer := api.OwnerConfig{Address: newOwner, ValidatorNonce: 0}
+	_, err = cli.Reshare(operators, signingOutput, transfer, nil, log)
+	require.Error(t, err)
+
+	transfer.TransferSignature = signOwnerTransfer(t, newOwnerKey, signingOutput.SessionID, transfer)
+	_, err = cli.Reshare(operators, signingOutput, transfer, nil, log)
+	require.Error(t, err)
+
+	// nor can the client claim the validator has a different owner
+	claimed := signingOutput
+	claimed.Owner = newOwner
+	_, err = cli.Reshare(operators, claimed, transfer, nil, log)
+	require.Error(t, err)
+
+	transfer.TransferSignature = signOwnerTransfer(t, ownerKey, signingOutput.SessionID, transfer)
+	transferred, err := cli.Reshare(operators, signingOutput, transfer, nil, log)
+	require.NoError(t, err)
+	require.Equal(t, newOwner, []byte(transferred.Owner))
+
+	// after which the old owner can't take it back without the new owner's say-so
+	_, err = cli.Reshare(operators, transferred, api.OwnerConfig{Address: owner, ValidatorNonce: 1}, nil, log)
+	require.Error(t, err)
+}
+
+// signOwnerTransfer signs the transfer like a wallet's `personal_sign` would
+func signOwnerTransfer(t *testing.T, key *ecdsa.PrivateKey, sessionID []byte, transfer api.OwnerConfig) []byte {
+	signature, err := ethcrypto.Sign(crypto.OwnerTransferHash(sessionID, transfer.Address, transfer.ValidatorNonce), key)
+	require.NoError(t, err)
+	signature[ethcrypto.RecoveryIDOffset] += 27
+	return signature
+}
+
+func keyOf(t *testing.T, output api.SigningOutput, operatorID uint32) []byte {
+	for _, share := range output.OperatorShares {
+		if share.Identity.OperatorID == operatorID {
+			return share.Identity.Public
+		}
+	}
+	t.Fatalf("operator %d is not in the output", operatorID)
+	return nil
+}
+
+func TestReshareResizesCluster(t *testing.T) {
+	ports := []uint{10141, 10142, 10143, 10144, 10145, 10146, 10147, 10148}
+	startSidecars(t, ports)
+	operators := fmap(ports, func(o uint) string {
+		return fmt.Sprintf("http://127.0.0.1:%d", o)
+	})
+
+	log := shared.QuietLogger{Quiet: false}
+	address, err := hex.DecodeString("aA184b86B4cdb747F4A3BF6e6FCd5e27c1d92c5c")
+	require.NoError(t, err)
+	args := api.SignatureConfig{
+		Operators:   operators[0:4],
+		DepositData: createUnsignedDepositData(),
+		Owner: api.OwnerConfig{
+			ValidatorNonce: 0,
+			Address:        address,
+		},
+	}
+	signingOutput, err := cli.Sign(args, log)
+	require.NoError(t, err)
+	suite := crypto.NewBLSSuite()
+	groupPublicKey := crypto.ExtractGroupPublicKey(suite, signingOutput.GroupPublicPolynomial)
+
+	// growing the cluster from 4 to 7 operators
+	grown, err := cli.Reshare(operators[0:7], signingOutput, args.Owner, nil, log)
+	require.NoError(t, err)
+	require.Len(t, grown.OperatorShares, 7)
+	require.Equal(t, groupPublicKey, crypto.ExtractGroupPublicKey(suite, grown.GroupPublicPolynomial))
+
+	// shrinking it to 4 operators, only 3 of which hold shares, needs operators leaving to deal theirs too,
+	// though the reshare carries on without a leaving operator that can't deal its share
+	undealt := grown
+	undealt.OperatorShares = slices.Clone(grown.OperatorShares)
+	for i, share := range undealt.OperatorShares {
+		if share.Identity.OperatorID == 10141 {
+			undealt.OperatorShares[i].EncryptedShare = []byte("not the share operator 10141 holds")
+		}
+	}
+	shrunk, err := cli.Reshare(operators[4:8], undealt, args.Owner, nil, log)
+	require.NoError(t, err)
+	require.Len(t, shrunk.OperatorShares, 4)
+	require.Equal(t, groupPublicKey, crypto.ExtractGroupPublicKey(suite, shrunk.GroupPublicPolynomial))
+
+	// if too few of the operators holding shares can take part, we fail before running the reshare
+	unreachable := shrunk
+	unreachable.OperatorShares = slices.Clone(shrunk.OperatorShares)
+	for i, share := range unreachable.OperatorShares {
+		if share.Identity.OperatorID == 10145 || share.Identity.OperatorID == 10146 {
+			unreachable.OperatorShares[i].Identity.Address = "http://127.0.0.1:10149"
+		}
+	}
+	_, err = cli.Reshare(operators[0:4], unreachable, args.Owner, nil, log)
+	require.ErrorContains(t, err, "only 2 operators holding key shares can take part")
+}
+
+func TestReshareDryRun(t *testing.T) {
+	ports := []uint{10201, 10202, 10203, 10204, 10205}
+	startSidecars(t, ports)
+	operators := fmap(ports, func(o uint) string {
+		return fmt.Sprintf("http://127.0.0.1:%d", o)
+	})
+
+	log := shared.QuietLogger{Quiet: false}
+	address, err := hex.DecodeString("aA184b86B4cdb747F4A3BF6e6FCd5e27c1d92c5c")
+	require.NoError(t, err)
+	args := api.SignatureConfig{
+		Operators:   operators[0:4],
+		DepositData: createUnsignedDepositData(),
+		Owner: api.OwnerConfig{
+			ValidatorNonce: 0,
+			Address:        address,
+		},
+	}
+	signingOutput, err := cli.Sign(args, log)
+	require.NoError(t, err)
+
+	require.NoError(t, cli.DryRunReshare(operators[0:4], signingOutput, nil, log))
+	require.NoError(t, cli.DryRunReshare([]string{operators[0], operators[1], operators[2], operators[4]}, signingOutput, nil, log))
+
+	// operators don't hold shares they weren't given
+	tampered := signingOutput
+	tampered.OperatorShares = slices.Clone(signingOutput.OperatorShares)
+	for i := 0; i < 2; i++ {
+		tampered.OperatorShares[i].EncryptedShare = []byte("not the share we gave them")
+	}
+	err = cli.DryRunReshare(operators[0:4], tampered, nil, log)
+	require.ErrorContains(t, err, "only 2 operators hold a valid key share")
+
+	// nor shares for a different validator
+	tampered = signingOutput
+	tampered.GroupPublicPolynomial = slices.Clone(signingOutput.GroupPublicPolynomial)
+	tampered.GroupPublicPolynomial[len(tampered.GroupPublicPolynomial)-1] ^= 1
+	require.Error(t, cli.DryRunReshare(operators[0:4], tampered, nil, log))
+
+	// and the dry runs left the cluster able to reshare
+	_, err = cli.Reshare(operators[0:4], signingOutput, args.Owner, nil, log)
+	require.NoError(t, err)
+}
+
+func TestRefreshKeepsOperatorsAndGroupKey(t *testing.T) {
+	ports := []uint{10211, 10212, 10213, 10214}
+	startSidecars(t, ports)
+	operators := fmap(ports, func(o uint) string {
+		return fmt.Sprintf("http://127.0.0.1:%d", o)
+	})
+
+	log := shared.QuietLogger{Quiet: false}
+	address, err := hex.DecodeString("aA184b86B4cdb747F4A3BF6e6FCd5e27c1d92c5c")
+	require.NoError(t, err)
+	args := api.SignatureConfig{
+		Operators:   operators,
+		DepositData: createUnsignedDepositData(),
+		Owner: api.OwnerConfig{
+			ValidatorNonce: 0,
+			Address:        address,
+		},
+	}
+	signingOutput, err := cli.Sign(args, log)
+	require.NoError(t, err)
+
+	suite := crypto.NewBLSSuite()
+	groupPublicKey := crypto.ExtractGroupPublicKey(suite, signingOutput.GroupPublicPolynomial)
+	owner := api.OwnerConfig{ValidatorNonce: 1, Address: address}
+	refreshed, err := cli.Refresh(signingOutput, owner, nil, log)
+	require.NoError(t, err)
+	require.Equal(t, groupPublicKey, crypto.ExtractGroupPublicKey(suite, refreshed.GroupPublicPolynomial))
+	require.Len(t, refreshed.OperatorShares, 4)
+	for _, o := range ports {
+		require.NotEqual(t, shareOf(t, signingOutput, uint32(o)).SharePublicKey, shareOf(t, refreshed, uint32(o)).SharePublicKey)
+	}
+
+	nonceMessage, err := crypto.ValidatorNonceMessage(owner.Address, owner.ValidatorNonce)
+	require.NoError(t, err)
+	require.NoError(t, suite.Verify(nonceMessage, groupPublicKey, refreshed.ValidatorNonceSignature))
+
+	// once the refresh is confirmed, the operators delete the shares it superseded, so they can't be used any more
+	require.Error(t, cli.DryRunReshare(operators, signingOutput, nil, log))
+	require.NoError(t, cli.DryRunReshare(operators, refreshed, nil, log))
+	topUp := createUnsignedDepositData()
+	topUp.Amount = crypto.GweiPerEth
+	_, err = cli.TopUp(topUp, signingOutput, log)
+	require.Error(t, err)
+	_, err = cli.TopUp(topUp, refreshed, log)
+	require.NoError(t, err)
+	oldNodes := make([]crypto.Identity, len(signingOutput.OperatorShares))
+	for i, share := range signingOutput.OperatorShares {
+		oldNodes[i] = share.Identity
+	}
+	for _, share := range signingOutput.OperatorShares {
+		oldHash := sha256.Sum256(share.EncryptedShare)
+		client := api.NewSidecarClient(share.Identity.Address)
+		_, err = client.VerifyShare(api.VerifyShareRequest{
+			SessionID:          hex.EncodeToString(signingOutput.SessionID),
+			EncryptedShareHash: oldHash[:],
+			PublicPolynomial:   signingOutput.GroupPublicPolynomial,
+			ProtocolVersion:    api.ProtocolVersion,
+		})
+		require.Error(t, err)
+		_, err = client.Reshare(api.ReshareRequest{
+			Operators: oldNodes,
+			PreviousState: api.PreviousDKGState{
+				SessionID:                   hex.EncodeToString(signingOutput.SessionID),
+				Nodes:                       oldNodes,
+				PublicPolynomialCommitments: signingOutput.GroupPublicPolynomial,
+				WithdrawalCredentials:       signingOutput.WithdrawalCredentials,
+				Owner:                       signingOutput.Owner,
+			},
+			PreviousEncryptedShareHash: oldHash[:],
+			ProtocolVersion:            api.ProtocolVersion,
+			OwnerConfig:                &owner,
+		})
+		require.Error(t, err)
+	}
+
+	// and the refreshed shares can be refreshed again
+	owner.ValidatorNonce = 2
+	refreshedAgain, err := cli.Refresh(refreshed, owner, nil, log)
+	require.NoError(t, err)
+	require.Equal(t, groupPublicKey, crypto.ExtractGroupPublicKey(suite, refreshedAgain.GroupPublicPolynomial))
+
+	// but nobody can make the operators prune in favour of a refresh the group hasn't confirmed
+	share := shareOf(t, refreshedAgain, uint32(ports[0]))
+	hash := sha256.Sum256(share.EncryptedShare)
+	_, err = api.NewSidecarClient(share.Identity.Address).Prune(api.PruneRequest{
+		SessionID:          hex.EncodeToString(refreshedAgain.SessionID),
+		EncryptedShareHash: hash[:],
+		ValidatorNonce:     owner.ValidatorNonce,
+		ProtocolVersion:    api.ProtocolVersion,
+	})
+	require.Error(t, err)
+}
+
+func shareOf(t *testing.T, output api.SigningOutput, operatorID uint32) api.OperatorShare {
+	for _, share := range output.OperatorShares {
+		if share.Identity.OperatorID == operatorID {
+			return share
+		}
+	}
+	t.Fatalf("operator %d is not in the output", operatorID)
+	return api.OperatorShare{}
+}
+
+// startDaemonInDir starts a sidecar whose state is kept in the given directory, creating any keys that don't exist
+func startDaemonInDir(t *testing.T, port uint, stateDir string) sidecar.Daemon {
+	if _, err := os.Stat(path.Join(stateDir, "keypair.json")); err != nil {
+		require.NoError(t, sidecar.GenerateKey(stateDir))
+	}
+	ssvKeyPath := path.Join(stateDir, "pub.json")
+	if _, err := os.Stat(ssvKeyPath); err != nil {
+		require.NoError(t, generateRSAKey(ssvKeyPath))
+	}
+
+	url := fmt.Sprintf("http://127.0.0.1:%d", port)
+	d, err := sidecar.NewDaemon(port, url, stateDir, ssvKeyPath, uint32(port), []network.Network{network.Holesky})
+	require.NoError(t, err)
+	go func() {
+		d.Start()
+	}()
+	require.NoError(t, awaitSidecarHealthy(port))
+	t.Cleanup(d.Stop)
+	return d
+}
+
 func startSidecars(t *testing.T, ports []uint) []sidecar.Daemon {
 	out := make([]sidecar.Daemon, len(ports))
 	for i, o := range ports {
@@ -216,11 +1086,11 @@ func createErrorDaemon(t *testing.T, port uint, errorCoordinator sidecar.DKGProt
 		t.Fatal(err)
 	}
 	url := fmt.Sprintf("http://127.0.0.1:%d", port)
-	_, err = sidecar.SignKey(url, stateDir, uint32(port))
+	_, err = sidecar.SignKey(url, stateDir, uint32(port), time.Hour, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
-	d, err := sidecar.NewDaemonWithDKG(port, url, stateDir, errorCoordinator, ssvKeyPath, uint32(port))
+	d, err := sidecar.NewDaemonWithDKG(port, url, stateDir, errorCoordinator, ssvKeyPath, uint32(port), []network.Network{network.Holesky})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -240,11 +1110,11 @@ func createDaemon(t *testing.T, port uint) sidecar.Daemon {
 	}
 
 	url := fmt.Sprintf("http://127.0.0.1:%d", port)
-	_, err = sidecar.SignKey(url, stateDir, uint32(port))
+	_, err = sidecar.SignKey(url, stateDir, uint32(port), time.Hour, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
-	d, err := sidecar.NewDaemon(port, url, stateDir, ssvKeyPath, uint32(port))
+	d, err := sidecar.NewDaemon(port, url, stateDir, ssvKeyPath, uint32(port), []network.Network{network.Holesky})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -260,13 +1130,13 @@ func fmap[T any, U any](arr []T, f func(T) U) []U {
 }
 
 type healthCheck interface {
-	Health() error
+	Health() (api.SidecarHealthResponse, error)
 }
 
 func awaitHealthy(h healthCheck) error {
 	var err error
 	for i := 0; i < 5; i++ {
-		if err = h.Health(); err == nil {
+		if _, err = h.Health(); err == nil {
 			return nil
 		}
 		time.Sleep(1 * time.Second)
@@ -284,7 +1154,7 @@ func createUnsignedDepositData() api.UnsignedDepositData {
 	forkVersion, _ := hex.DecodeString("01017000")
 	return api.UnsignedDepositData{
 		WithdrawalCredentials: withdrawal,
-		Amount:                1,
+		Amount:                32000000000,
 		ForkVersion:           forkVersion,
 		NetworkName:           "holesky",
 		DepositCLIVersion:     "2.8.0",
@@ -1,8 +1,11 @@
 package api
 
 import (
+	"fmt"
+
 	"github.com/randa-mu/ssv-dkg/shared/crypto"
 	"github.com/randa-mu/ssv-dkg/shared/encoding"
+	"github.com/randa-mu/ssv-dkg/shared/registry"
 )
 
 type SignatureConfig struct {
@@ -10,11 +13,26 @@ type SignatureConfig struct {
 	DepositData UnsignedDepositData
 	Owner       OwnerConfig
 	SsvClient   SsvClient
+	// FaultTolerant allows the DKG to complete with only a threshold of the operators
+	FaultTolerant bool
+	// SpareOperators replace failing operators when the DKG is retried
+	SpareOperators []string
+	// MaxAttempts is the number of times the DKG is run before giving up
+	MaxAttempts int
+	// Registry, if set, is checked for each operator's identity before the DKG starts
+	Registry *registry.Registry
+	// RequireSsvBinding fails operators whose registry entry isn't signed by their SSV operator key,
+	// rather than only warning about them. Operators without a registry entry are only warned about either way,
+	// and it has no effect without an SSV API to fetch the keys from
+	RequireSsvBinding bool
 }
 
 type OwnerConfig struct {
 	Address        encoding.HexBytes `json:"address"`
 	ValidatorNonce uint32            `json:"validator_nonce"`
+	// TransferSignature is the previous owner's signature over crypto.OwnerTransferMessage,
+	// which operators require before signing the validator nonce of a new owner when resharing
+	TransferSignature encoding.HexBytes `json:"transfer_signature,omitempty"`
 }
 
 type UnsignedDepositData struct {
@@ -43,6 +61,13 @@ type SigningOutput struct {
 	OperatorShares          []OperatorShare        `json:"operator_shares"`
 	DepositDataSignature    encoding.UnpaddedBytes `json:"deposit_data_signature"`
 	ValidatorNonceSignature encoding.UnpaddedBytes `json:"validator_nonce_signature"`
+	// operators that were part of the DKG but didn't receive a key share; they must be reshared in
+	MissingOperators []crypto.Identity `json:"missing_operators,omitempty"`
+	// the withdrawal credentials the validator was created with, which the operators only sign top-ups for.
+	// States created by older versions don't have them
+	WithdrawalCredentials encoding.HexBytes `json:"withdrawal_credentials,omitempty"`
+	// the owner the operators have bound the validator to, who must authorise transferring it to anyone else
+	Owner encoding.HexBytes `json:"owner,omitempty"`
 }
 
 type OperatorShare struct {
@@ -57,6 +82,22 @@ type OperatorResponse struct {
 	Response     SignResponse
 }
 
+// Validate checks the deposit data describes a valid deposit for creating a new validator
+func (u UnsignedDepositData) Validate() error {
+	if len(u.ForkVersion) != 4 {
+		return fmt.Errorf("fork version must be 4 bytes; got %d", len(u.ForkVersion))
+	}
+	return crypto.ValidateInitialDepositAmount(u.WithdrawalCredentials, u.Amount)
+}
+
+// ValidateTopUp checks the deposit data describes a valid deposit for adding stake to an existing validator
+func (u UnsignedDepositData) ValidateTopUp() error {
+	if len(u.ForkVersion) != 4 {
+		return fmt.Errorf("fork version must be 4 bytes; got %d", len(u.ForkVersion))
+	}
+	return crypto.ValidateTopUpAmount(u.WithdrawalCredentials, u.Amount)
+}
+
 func (u UnsignedDepositData) IntoMessage(groupPublicKey []byte) crypto.DepositMessage {
 	return crypto.DepositMessage{
 		WithdrawalCredentials: u.WithdrawalCredentials,
@@ -11,6 +11,8 @@ type SidecarDKGPacket struct {
 	Deal          *Deal
 	Response      *Response
 	Justification *Justification
+	// the protocol version of the DKG the packet belongs to; 0 means the legacy version
+	ProtocolVersion uint32
 }
 
 type Deal struct {
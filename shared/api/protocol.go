@@ -0,0 +1,75 @@
+package api
+
+import (
+	"fmt"
+
+	"golang.org/x/exp/slices"
+)
+
+const (
+	// ProtocolVersion is the highest version of the sidecar protocol this build speaks.
+	// It's only bumped for changes every party to a request must agree on, such as the session nonces version 2 added;
+	// optional features are advertised as capabilities instead
+	ProtocolVersion uint32 = 2
+	// MinimumProtocolVersion is the lowest version of the sidecar protocol this build speaks.
+	// Sidecars from before version 2 don't state a protocol version and can't verify session IDs,
+	// so clients and sidecars refuse to talk to them
+	MinimumProtocolVersion = ProtocolVersion
+)
+
+// Capabilities are optional features a sidecar advertises in its identity
+const (
+	CapabilityReshare       = "reshare"
+	CapabilityTopUp         = "topup"
+	CapabilityFaultTolerant = "fault-tolerant"
+	// CapabilityReshareNonce means the sidecar signs a new validator nonce when resharing
+	CapabilityReshareNonce = "reshare-nonce"
+	// CapabilityReshareLeave means the sidecar can deal its key share to a new group it isn't part of
+	CapabilityReshareLeave = "reshare-leave"
+	// CapabilityVerifyShare means the sidecar can check it still holds a valid key share without resharing it
+	CapabilityVerifyShare = "verify-share"
+	// CapabilityValidatorBinding means the sidecar binds its key share to the validator's withdrawal credentials and owner,
+	// only signing top-ups for those credentials and validator nonces for that owner, and carrying them through reshares
+	CapabilityValidatorBinding = "validator-binding"
+	// CapabilityPrune means the sidecar deletes the key shares a refresh superseded once the refreshed shares are confirmed
+	CapabilityPrune = "prune"
+)
+
+// Capabilities returns the features supported by this build of the sidecar
+func Capabilities() []string {
+	return []string{CapabilityReshare, CapabilityTopUp, CapabilityFaultTolerant, CapabilityReshareNonce, CapabilityReshareLeave, CapabilityVerifyShare, CapabilityValidatorBinding, CapabilityPrune}
+}
+
+// SupportedProtocolVersion returns the highest protocol version the sidecar speaks,
+// which is zero for sidecars from before protocol versions were stated
+func (s SidecarIdentityResponse) SupportedProtocolVersion() uint32 {
+	return s.ProtocolVersion
+}
+
+// SupportsCapability returns whether the sidecar has advertised a given feature
+func (s SidecarIdentityResponse) SupportsCapability(capability string) bool {
+	return slices.Contains(s.Capabilities, capability)
+}
+
+// NegotiateProtocolVersion picks the highest protocol version spoken by us and every one of the sidecars,
+// given the highest version each of them speaks
+func NegotiateProtocolVersion(versions ...uint32) (uint32, error) {
+	negotiated := ProtocolVersion
+	for _, v := range versions {
+		if v < negotiated {
+			negotiated = v
+		}
+	}
+	if negotiated < MinimumProtocolVersion {
+		return 0, fmt.Errorf("an operator only speaks protocol version %d, but this CLI requires at least version %d; the operator must upgrade their sidecar", negotiated, MinimumProtocolVersion)
+	}
+	return negotiated, nil
+}
+
+// CheckProtocolVersion checks a request or packet uses a protocol version this sidecar speaks
+func CheckProtocolVersion(version uint32) error {
+	if version < MinimumProtocolVersion || version > ProtocolVersion {
+		return fmt.Errorf("protocol version %d is not supported; this sidecar supports versions %d to %d", version, MinimumProtocolVersion, ProtocolVersion)
+	}
+	return nil
+}
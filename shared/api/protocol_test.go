@@ -0,0 +1,44 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiateProtocolVersion(t *testing.T) {
+	v, err := NegotiateProtocolVersion(ProtocolVersion, ProtocolVersion, ProtocolVersion)
+	require.NoError(t, err)
+	require.Equal(t, ProtocolVersion, v)
+
+	// sidecars from before protocol versions were stated are too old to take part
+	_, err = NegotiateProtocolVersion(ProtocolVersion, 0)
+	require.ErrorContains(t, err, "must upgrade their sidecar")
+
+	// sidecars newer than us speak our version too
+	v, err = NegotiateProtocolVersion(ProtocolVersion+1, ProtocolVersion)
+	require.NoError(t, err)
+	require.Equal(t, ProtocolVersion, v)
+
+	_, err = NegotiateProtocolVersion(0)
+	require.Error(t, err)
+}
+
+func TestCheckProtocolVersion(t *testing.T) {
+	require.Error(t, CheckProtocolVersion(0))
+	require.Error(t, CheckProtocolVersion(MinimumProtocolVersion-1))
+	require.NoError(t, CheckProtocolVersion(ProtocolVersion))
+	require.Error(t, CheckProtocolVersion(ProtocolVersion+1))
+}
+
+func TestIdentityCapabilities(t *testing.T) {
+	// sidecars from before capabilities were advertised don't support any of them
+	legacy := SidecarIdentityResponse{}
+	require.Equal(t, uint32(0), legacy.SupportedProtocolVersion())
+	require.False(t, legacy.SupportsCapability(CapabilityReshare))
+	require.False(t, legacy.SupportsCapability(CapabilityFaultTolerant))
+
+	current := SidecarIdentityResponse{ProtocolVersion: ProtocolVersion, Capabilities: Capabilities()}
+	require.Equal(t, ProtocolVersion, current.SupportedProtocolVersion())
+	require.True(t, current.SupportsCapability(CapabilityFaultTolerant))
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+
+	"golang.org/x/exp/slices"
+
+	"github.com/randa-mu/ssv-dkg/shared/crypto"
+)
+
+// SessionNonceLength is the number of random bytes mixed into every session ID
+const SessionNonceLength = 32
+
+// sessionIDDomain separates session IDs from any other sha256 hashes we create
+const sessionIDDomain = "ssv-dkg/session-id/v1"
+
+// NewSessionNonce creates the random part of a session ID, so two users starting a DKG
+// at the same time with the same operators still end up with different sessions
+func NewSessionNonce() ([]byte, error) {
+	nonce := make([]byte, SessionNonceLength)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("error creating session nonce: %w", err)
+	}
+	return nonce, nil
+}
+
+// SessionID binds a DKG session to the validator it is creating: the owner, their validator nonce,
+// the operators in the cluster and the network (by its fork version). It also binds whether the DKG is fault-tolerant,
+// so every operator in the session agrees on whether it can complete without all of them.
+// Operators are sorted by ID first, so the order they were passed in doesn't matter
+func SessionID(nonce []byte, owner OwnerConfig, operators []crypto.Identity, forkVersion []byte, faultTolerant bool) ([]byte, error) {
+	if len(nonce) != SessionNonceLength {
+		return nil, fmt.Errorf("session nonce must be %d bytes; got %d", SessionNonceLength, len(nonce))
+	}
+
+	operatorIDs := make([]uint32, len(operators))
+	for i, o := range operators {
+		operatorIDs[i] = o.OperatorID
+	}
+	slices.Sort(operatorIDs)
+
+	h := sha256.New()
+	h.Write([]byte(sessionIDDomain))
+	h.Write(nonce)
+	writeLengthPrefixed(h, owner.Address)
+	_ = binary.Write(h, binary.BigEndian, owner.ValidatorNonce)
+	_ = binary.Write(h, binary.BigEndian, uint32(len(operatorIDs)))
+	_ = binary.Write(h, binary.BigEndian, operatorIDs)
+	writeLengthPrefixed(h, forkVersion)
+	_ = binary.Write(h, binary.BigEndian, faultTolerant)
+	return h.Sum(nil), nil
+}
+
+// VerifySessionID checks the session ID in the request was derived from the rest of the request,
+// so a client can't reuse a session ID for a different owner, set of operators or network
+func (s SignRequest) VerifySessionID() error {
+	if len(s.SessionID) == 0 {
+		return errors.New("sessionID cannot be empty")
+	}
+	expected, err := SessionID(s.SessionNonce, s.OwnerConfig, s.Operators, s.DepositData.ForkVersion, s.FaultTolerant)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(expected, s.SessionID) {
+		return errors.New("sessionID was not derived from the session nonce, owner, operators, network and fault tolerance of the request")
+	}
+	return nil
+}
+
+func writeLengthPrefixed(h hash.Hash, b []byte) {
+	_ = binary.Write(h, binary.BigEndian, uint32(len(b)))
+	h.Write(b)
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/randa-mu/ssv-dkg/shared/crypto"
+)
+
+func TestSessionIDIsBoundToRequest(t *testing.T) {
+	nonce, err := NewSessionNonce()
+	require.NoError(t, err)
+	owner := OwnerConfig{Address: []byte{0xde, 0xad, 0xbe, 0xef}, ValidatorNonce: 1}
+	operators := []crypto.Identity{{OperatorID: 3}, {OperatorID: 1}, {OperatorID: 2}, {OperatorID: 4}}
+	forkVersion := []byte{0x01, 0x01, 0x70, 0x00}
+
+	sessionID, err := SessionID(nonce, owner, operators, forkVersion, false)
+	require.NoError(t, err)
+
+	// the order of operators shouldn't matter
+	reordered := []crypto.Identity{{OperatorID: 1}, {OperatorID: 2}, {OperatorID: 3}, {OperatorID: 4}}
+	same, err := SessionID(nonce, owner, reordered, forkVersion, false)
+	require.NoError(t, err)
+	require.Equal(t, sessionID, same)
+
+	otherNonce, err := NewSessionNonce()
+	require.NoError(t, err)
+	different, err := SessionID(otherNonce, owner, operators, forkVersion, false)
+	require.NoError(t, err)
+	require.NotEqual(t, sessionID, different)
+
+	different, err = SessionID(nonce, OwnerConfig{Address: owner.Address, ValidatorNonce: 2}, operators, forkVersion, false)
+	require.NoError(t, err)
+	require.NotEqual(t, sessionID, different)
+
+	different, err = SessionID(nonce, owner, operators, []byte{0x00, 0x00, 0x00, 0x00}, false)
+	require.NoError(t, err)
+	require.NotEqual(t, sessionID, different)
+
+	different, err = SessionID(nonce, owner, operators, forkVersion, true)
+	require.NoError(t, err)
+	require.NotEqual(t, sessionID, different)
+
+	_, err = SessionID(nonce[1:], owner, operators, forkVersion, false)
+	require.Error(t, err)
+}
+
+func TestVerifySessionID(t *testing.T) {
+	nonce, err := NewSessionNonce()
+	require.NoError(t, err)
+	request := SignRequest{
+		SessionNonce: nonce,
+		DepositData:  UnsignedDepositData{ForkVersion: []byte{0x01, 0x01, 0x70, 0x00}},
+		OwnerConfig:  OwnerConfig{Address: []byte{0xde, 0xad, 0xbe, 0xef}},
+		Operators:    []crypto.Identity{{OperatorID: 1}, {OperatorID: 2}, {OperatorID: 3}, {OperatorID: 4}},
+	}
+	request.SessionID, err = SessionID(request.SessionNonce, request.OwnerConfig, request.Operators, request.DepositData.ForkVersion, request.FaultTolerant)
+	require.NoError(t, err)
+	require.NoError(t, request.VerifySessionID())
+
+	// the client can't tell some operators the DKG is fault-tolerant and others that it isn't
+	request.FaultTolerant = true
+	require.Error(t, request.VerifySessionID())
+	request.FaultTolerant = false
+
+	// reusing the session ID for a different set of operators should fail
+	request.Operators = request.Operators[1:]
+	require.Error(t, request.VerifySessionID())
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"golang.org/x/exp/slog"
@@ -12,18 +13,26 @@ import (
 )
 
 type Sidecar interface {
-	Health() error
+	Health() (SidecarHealthResponse, error)
 	Sign(request SignRequest) (SignResponse, error)
 	Reshare(request ReshareRequest) (ReshareResponse, error)
+	TopUp(request TopUpRequest) (TopUpResponse, error)
+	VerifyShare(request VerifyShareRequest) (VerifyShareResponse, error)
+	Prune(request PruneRequest) (PruneResponse, error)
 	Identity() (SidecarIdentityResponse, error)
 	BroadcastDKG(packet SidecarDKGPacket) error
 }
 
 type SignRequest struct {
-	SessionID   []byte              `json:"session_id"`
-	DepositData UnsignedDepositData `json:"deposit_data"`
-	OwnerConfig OwnerConfig         `json:"owner_config"`
-	Operators   []crypto.Identity   `json:"operators"`
+	SessionID    []byte              `json:"session_id"`
+	SessionNonce []byte              `json:"session_nonce"`
+	DepositData  UnsignedDepositData `json:"deposit_data"`
+	OwnerConfig  OwnerConfig         `json:"owner_config"`
+	Operators    []crypto.Identity   `json:"operators"`
+	// if true, the DKG succeeds as long as a threshold of operators qualify
+	FaultTolerant bool `json:"fault_tolerant,omitempty"`
+	// the protocol version negotiated with every operator; 0 means the legacy version
+	ProtocolVersion uint32 `json:"protocol_version,omitempty"`
 }
 
 type SignResponse struct {
@@ -48,12 +57,22 @@ type ReshareRequest struct {
 	Operators                  []crypto.Identity `json:"operators"`
 	PreviousState              PreviousDKGState  `json:"previous_state"`
 	PreviousEncryptedShareHash []byte            `json:"previous_encrypted_share_hash"`
+	// the protocol version negotiated with every operator; 0 means the legacy version
+	ProtocolVersion uint32 `json:"protocol_version,omitempty"`
+	// the owner and nonce to sign for re-registering the validator with the new operators.
+	// Legacy clients don't send it, in which case no validator nonce is signed
+	OwnerConfig *OwnerConfig `json:"owner_config,omitempty"`
 }
 
 type PreviousDKGState struct {
 	SessionID                   string            `json:"session_id"`
 	Nodes                       []crypto.Identity `json:"nodes"`
 	PublicPolynomialCommitments []byte            `json:"public_polynomial_commitments"`
+	// the withdrawal credentials the validator was created with; operators already holding a share check them against their own,
+	// and every operator binds them into the reshare, so new operators can trust them
+	WithdrawalCredentials []byte `json:"withdrawal_credentials,omitempty"`
+	// the validator's current owner, who must sign any transfer to a new owner; it's checked and bound in the same way
+	Owner []byte `json:"owner,omitempty"`
 }
 
 type ReshareResponse struct {
@@ -68,6 +87,80 @@ type ReshareResponse struct {
 	// it should be the same as the initial sharing, but always good to check
 	// the 0th commitment is the group public key
 	PublicPolynomial []byte `json:"public_polynomial"`
+
+	// a partial signature over the validator's nonce's SHA256 hash, if the request had an owner config
+	ValidatorNoncePartialSignature []byte `json:"validator_nonce_partial_signature,omitempty"`
+}
+
+type TopUpRequest struct {
+	// the sessionID of the DKG that created the validator
+	SessionID string `json:"session_id"`
+
+	// the hash of the encrypted share the node holds, so it can pick
+	// the correct share if it has been reshared
+	EncryptedShareHash []byte `json:"encrypted_share_hash"`
+
+	// the deposit data for the top-up; the public key is taken from the node's stored state
+	DepositData UnsignedDepositData `json:"deposit_data"`
+
+	// the protocol version negotiated with the operator; 0 means the legacy version
+	ProtocolVersion uint32 `json:"protocol_version,omitempty"`
+}
+
+type TopUpResponse struct {
+	// a partial signature over the deposit message signing root for the top-up
+	DepositDataPartialSignature []byte `json:"deposit_data_partial_signature"`
+}
+
+type VerifyShareRequest struct {
+	// the sessionID of the DKG that created the validator
+	SessionID string `json:"session_id"`
+
+	// the hash of the encrypted share the node should hold
+	EncryptedShareHash []byte `json:"encrypted_share_hash"`
+
+	// the group's public polynomial held by the client, which the node's stored one must match
+	PublicPolynomial []byte `json:"public_polynomial"`
+
+	// the protocol version negotiated with the operator; 0 means the legacy version
+	ProtocolVersion uint32 `json:"protocol_version,omitempty"`
+}
+
+type VerifyShareResponse struct {
+	// the public key of the stored key share, which verified against the public polynomial
+	SharePublicKey []byte `json:"share_public_key"`
+}
+
+type PruneRequest struct {
+	// the sessionID of the DKG that created the validator
+	SessionID string `json:"session_id"`
+
+	// the hash of the encrypted share the node holds after a refresh; the shares it holds from before it are deleted
+	EncryptedShareHash []byte `json:"encrypted_share_hash"`
+
+	// the validator nonce the refreshed group signed, and the partial signatures a threshold of the group made over it
+	// with their refreshed key shares, which prove the refreshed shares can be used in place of the ones they superseded
+	ValidatorNonce                  uint32   `json:"validator_nonce"`
+	ValidatorNoncePartialSignatures [][]byte `json:"validator_nonce_partial_signatures"`
+
+	// the protocol version negotiated with the operator; 0 means the legacy version
+	ProtocolVersion uint32 `json:"protocol_version,omitempty"`
+}
+
+type PruneResponse struct {
+	// the number of superseded key shares the node deleted
+	Pruned int `json:"pruned"`
+}
+
+type SidecarHealthResponse struct {
+	// the version of the sidecar, which must be compatible with the CLI's
+	Version string `json:"version"`
+
+	// the names of the networks the sidecar will sign deposits for
+	Networks []string `json:"networks"`
+
+	// the sidecar's current time, used to detect clock skew between nodes
+	Timestamp time.Time `json:"timestamp"`
 }
 
 type SidecarIdentityResponse struct {
@@ -75,14 +168,39 @@ type SidecarIdentityResponse struct {
 	PublicKey  []byte `json:"data"`
 	Address    string `json:"address"`
 	Signature  []byte `json:"signature"`
+	// unix timestamps covered by the signature; older sidecars sign legacy identities without them
+	IssuedAt  int64 `json:"issued_at,omitempty"`
+	ExpiresAt int64 `json:"expires_at,omitempty"`
+	// the sha256 of the SSV operator public key the sidecar encrypts shares to; older sidecars don't report it
+	SsvKeyHash []byte `json:"ssv_key_hash,omitempty"`
+
+	// the highest protocol version the sidecar speaks; 0 means the legacy version
+	ProtocolVersion uint32 `json:"protocol_version,omitempty"`
+	// the optional features the sidecar supports
+	Capabilities []string `json:"capabilities,omitempty"`
+}
+
+// Identity returns the signed identity in the response, to be verified by the caller
+func (s SidecarIdentityResponse) Identity() crypto.Identity {
+	return crypto.Identity{
+		OperatorID: s.OperatorID,
+		Address:    s.Address,
+		Public:     s.PublicKey,
+		Signature:  s.Signature,
+		IssuedAt:   s.IssuedAt,
+		ExpiresAt:  s.ExpiresAt,
+	}
 }
 
 var (
-	SidecarSignPath     = "/sign"
-	SidecarResharePath  = "/reshare"
-	SidecarHealthPath   = "/health"
-	SidecarIdentityPath = "/identity"
-	SidecarDKGPath      = "/dkg"
+	SidecarSignPath        = "/sign"
+	SidecarResharePath     = "/reshare"
+	SidecarTopUpPath       = "/topup"
+	SidecarVerifySharePath = "/verify-share"
+	SidecarPrunePath       = "/prune"
+	SidecarHealthPath      = "/health"
+	SidecarIdentityPath    = "/identity"
+	SidecarDKGPath         = "/dkg"
 )
 
 func BindSidecarAPI(router *chi.Mux, node Sidecar) {
@@ -90,16 +208,30 @@ func BindSidecarAPI(router *chi.Mux, node Sidecar) {
 	router.Get(SidecarIdentityPath, createSidecarIdentityAPI(node))
 	router.Post(SidecarSignPath, createSignAPI(node))
 	router.Post(SidecarResharePath, createReshareAPI(node))
+	router.Post(SidecarTopUpPath, createTopUpAPI(node))
+	router.Post(SidecarVerifySharePath, createVerifyShareAPI(node))
+	router.Post(SidecarPrunePath, createPruneAPI(node))
 	router.Post(SidecarDKGPath, createSidecarDKGAPI(node))
 }
 
 func createHealthAPI(node Sidecar) http.HandlerFunc {
 	return func(writer http.ResponseWriter, request *http.Request) {
-		err := node.Health()
+		response, err := node.Health()
 		if err != nil {
 			writer.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		j, err := json.Marshal(response)
+		if err != nil {
+			slog.Error("error marshalling health response", "err", err)
+			writer.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, err = writer.Write(j)
+		if err != nil {
+			slog.Error("error writing a health HTTP Response", "err", err)
 		}
-		writer.WriteHeader(http.StatusOK)
 	}
 }
 
@@ -123,7 +255,9 @@ func createSignAPI(node Sidecar) http.HandlerFunc {
 		response, err := node.Sign(requestBody)
 		if err != nil {
 			slog.Error("error signing deposit data", "err", err)
+			// the error is returned so clients can tell which operators caused a failed DKG
 			writer.WriteHeader(http.StatusInternalServerError)
+			_, _ = writer.Write([]byte(err.Error()))
 			return
 		}
 
@@ -174,6 +308,107 @@ func createReshareAPI(node Sidecar) http.HandlerFunc {
 	}
 }
 
+func createTopUpAPI(node Sidecar) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		bytes, err := io.ReadAll(request.Body)
+		if err != nil {
+			writer.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		var requestBody TopUpRequest
+		err = json.Unmarshal(bytes, &requestBody)
+		if err != nil {
+			writer.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		topUpResponse, err := node.TopUp(requestBody)
+		if err != nil {
+			slog.Error("error signing top-up", "err", err)
+			writer.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		j, err := json.Marshal(topUpResponse)
+		if err != nil {
+			slog.Error("error marshalling top-up response", "err", err)
+			writer.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, err = writer.Write(j)
+		if err != nil {
+			slog.Error("error writing a top-up HTTP Response", "err", err)
+		}
+	}
+}
+
+func createVerifyShareAPI(node Sidecar) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		bytes, err := io.ReadAll(request.Body)
+		if err != nil {
+			writer.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		var requestBody VerifyShareRequest
+		err = json.Unmarshal(bytes, &requestBody)
+		if err != nil {
+			writer.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		verifyResponse, err := node.VerifyShare(requestBody)
+		if err != nil {
+			slog.Error("error verifying key share", "err", err)
+			writer.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		j, err := json.Marshal(verifyResponse)
+		if err != nil {
+			slog.Error("error marshalling verify share response", "err", err)
+			writer.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, err = writer.Write(j)
+		if err != nil {
+			slog.Error("error writing a verify share HTTP Response", "err", err)
+		}
+	}
+}
+
+func createPruneAPI(node Sidecar) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		bytes, err := io.ReadAll(request.Body)
+		if err != nil {
+			writer.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		var requestBody PruneRequest
+		err = json.Unmarshal(bytes, &requestBody)
+		if err != nil {
+			writer.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		pruneResponse, err := node.Prune(requestBody)
+		if err != nil {
+			slog.Error("error pruning key shares", "err", err)
+			writer.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		j, err := json.Marshal(pruneResponse)
+		if err != nil {
+			slog.Error("error marshalling prune response", "err", err)
+			writer.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, err = writer.Write(j)
+		if err != nil {
+			slog.Error("error writing a prune HTTP Response", "err", err)
+		}
+	}
+}
 func createSidecarIdentityAPI(node Sidecar) http.HandlerFunc {
 	return func(writer http.ResponseWriter, request *http.Request) {
 		identity, err := node.Identity()
@@ -6,29 +6,58 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"time"
 
 	"golang.org/x/exp/slog"
 )
 
 type SidecarClient struct {
-	url string
+	url    string
+	client *http.Client
 }
 
 func NewSidecarClient(url string) Sidecar {
-	return SidecarClient{url: url}
+	return SidecarClient{url: url, client: http.DefaultClient}
 }
 
-func (s SidecarClient) Health() error {
+// NewSidecarClientWithTimeout creates a client whose requests fail if the sidecar doesn't respond in time,
+// so unreachable sidecars can't hang callers probing many of them
+func NewSidecarClientWithTimeout(url string, timeout time.Duration) Sidecar {
+	return SidecarClient{url: url, client: &http.Client{Timeout: timeout}}
+}
+
+func (s SidecarClient) Health() (SidecarHealthResponse, error) {
 	url := fmt.Sprintf("%s%s", s.url, SidecarHealthPath)
 	slog.Info("Sidecar running health check against", "url", url)
-	res, err := http.Get(url)
+	res, err := s.client.Get(url)
 	if err != nil {
-		return err
+		return SidecarHealthResponse{}, err
 	}
+	defer res.Body.Close()
 	if res.StatusCode != 200 {
-		return fmt.Errorf("sidecar health returned %d", res.StatusCode)
+		return SidecarHealthResponse{}, fmt.Errorf("sidecar health returned %d", res.StatusCode)
 	}
-	return nil
+
+	responseBytes, err := io.ReadAll(res.Body)
+	if err != nil {
+		return SidecarHealthResponse{}, fmt.Errorf("error reading response bytes: %w", err)
+	}
+
+	// older sidecars return an empty body
+	var healthResponse SidecarHealthResponse
+	if len(responseBytes) > 0 {
+		if err = json.Unmarshal(responseBytes, &healthResponse); err != nil {
+			return SidecarHealthResponse{}, fmt.Errorf("error unmarshalling health response: %w", err)
+		}
+	}
+
+	// if the sidecar doesn't give us a timestamp, the HTTP date header is almost as good
+	if healthResponse.Timestamp.IsZero() {
+		if date, err := http.ParseTime(res.Header.Get("Date")); err == nil {
+			healthResponse.Timestamp = date
+		}
+	}
+	return healthResponse, nil
 }
 
 func (s SidecarClient) Sign(request SignRequest) (SignResponse, error) {
@@ -36,13 +65,15 @@ func (s SidecarClient) Sign(request SignRequest) (SignResponse, error) {
 	if err != nil {
 		return SignResponse{}, err
 	}
-	response, err := http.Post(fmt.Sprintf("%s%s", s.url, SidecarSignPath), "application/json", bytes.NewBuffer(j))
+	response, err := s.client.Post(fmt.Sprintf("%s%s", s.url, SidecarSignPath), "application/json", bytes.NewBuffer(j))
 	if err != nil {
 		return SignResponse{}, fmt.Errorf("error signing with validator %s: %w", s.url, err)
 	}
+	defer response.Body.Close()
 
 	if response.StatusCode != http.StatusOK {
-		return SignResponse{}, fmt.Errorf("error signing with validator %s. Node returned status code %d", s.url, response.StatusCode)
+		reason, _ := io.ReadAll(response.Body)
+		return SignResponse{}, fmt.Errorf("error signing with validator %s. Node returned status code %d: %s", s.url, response.StatusCode, reason)
 	}
 
 	responseBytes, err := io.ReadAll(response.Body)
@@ -60,10 +91,11 @@ func (s SidecarClient) Reshare(request ReshareRequest) (ReshareResponse, error)
 	if err != nil {
 		return ReshareResponse{}, err
 	}
-	response, err := http.Post(fmt.Sprintf("%s%s", s.url, SidecarResharePath), "application/json", bytes.NewBuffer(j))
+	response, err := s.client.Post(fmt.Sprintf("%s%s", s.url, SidecarResharePath), "application/json", bytes.NewBuffer(j))
 	if err != nil {
 		return ReshareResponse{}, fmt.Errorf("error resharing with validator %s: %w", s.url, err)
 	}
+	defer response.Body.Close()
 
 	if response.StatusCode != http.StatusOK {
 		return ReshareResponse{}, fmt.Errorf("error resharing with validator %s. Node returned status code %d", s.url, response.StatusCode)
@@ -79,11 +111,87 @@ func (s SidecarClient) Reshare(request ReshareRequest) (ReshareResponse, error)
 	return reshareResponse, err
 }
 
+func (s SidecarClient) TopUp(request TopUpRequest) (TopUpResponse, error) {
+	j, err := json.Marshal(request)
+	if err != nil {
+		return TopUpResponse{}, err
+	}
+	response, err := s.client.Post(fmt.Sprintf("%s%s", s.url, SidecarTopUpPath), "application/json", bytes.NewBuffer(j))
+	if err != nil {
+		return TopUpResponse{}, fmt.Errorf("error signing top-up with validator %s: %w", s.url, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return TopUpResponse{}, fmt.Errorf("error signing top-up with validator %s. Node returned status code %d", s.url, response.StatusCode)
+	}
+
+	responseBytes, err := io.ReadAll(response.Body)
+	if err != nil {
+		return TopUpResponse{}, fmt.Errorf("error reading response bytes: %w", err)
+	}
+
+	var topUpResponse TopUpResponse
+	err = json.Unmarshal(responseBytes, &topUpResponse)
+	return topUpResponse, err
+}
+
+func (s SidecarClient) VerifyShare(request VerifyShareRequest) (VerifyShareResponse, error) {
+	j, err := json.Marshal(request)
+	if err != nil {
+		return VerifyShareResponse{}, err
+	}
+	response, err := s.client.Post(fmt.Sprintf("%s%s", s.url, SidecarVerifySharePath), "application/json", bytes.NewBuffer(j))
+	if err != nil {
+		return VerifyShareResponse{}, fmt.Errorf("error verifying key share with validator %s: %w", s.url, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return VerifyShareResponse{}, fmt.Errorf("error verifying key share with validator %s. Node returned status code %d", s.url, response.StatusCode)
+	}
+
+	responseBytes, err := io.ReadAll(response.Body)
+	if err != nil {
+		return VerifyShareResponse{}, fmt.Errorf("error reading response bytes: %w", err)
+	}
+
+	var verifyResponse VerifyShareResponse
+	err = json.Unmarshal(responseBytes, &verifyResponse)
+	return verifyResponse, err
+}
+
+func (s SidecarClient) Prune(request PruneRequest) (PruneResponse, error) {
+	j, err := json.Marshal(request)
+	if err != nil {
+		return PruneResponse{}, err
+	}
+	response, err := s.client.Post(fmt.Sprintf("%s%s", s.url, SidecarPrunePath), "application/json", bytes.NewBuffer(j))
+	if err != nil {
+		return PruneResponse{}, fmt.Errorf("error pruning key shares with validator %s: %w", s.url, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return PruneResponse{}, fmt.Errorf("error pruning key shares with validator %s. Node returned status code %d", s.url, response.StatusCode)
+	}
+
+	responseBytes, err := io.ReadAll(response.Body)
+	if err != nil {
+		return PruneResponse{}, fmt.Errorf("error reading response bytes: %w", err)
+	}
+
+	var pruneResponse PruneResponse
+	err = json.Unmarshal(responseBytes, &pruneResponse)
+	return pruneResponse, err
+}
+
 func (s SidecarClient) Identity() (SidecarIdentityResponse, error) {
-	res, err := http.Get(fmt.Sprintf("%s%s", s.url, SidecarIdentityPath))
+	res, err := s.client.Get(fmt.Sprintf("%s%s", s.url, SidecarIdentityPath))
 	if err != nil {
 		return SidecarIdentityResponse{}, fmt.Errorf("error making HTTP request: %w", err)
 	}
+	defer res.Body.Close()
 
 	if res.StatusCode != http.StatusOK {
 		return SidecarIdentityResponse{}, fmt.Errorf("error retrieving Identity for %s. Node returned status code %d", s.url, res.StatusCode)
@@ -108,10 +216,11 @@ func (s SidecarClient) BroadcastDKG(packet SidecarDKGPacket) error {
 		return fmt.Errorf("error marshalling json: %w", err)
 	}
 
-	res, err := http.Post(fmt.Sprintf("%s%s", s.url, SidecarDKGPath), "application/json", bytes.NewBuffer(requestBytes))
+	res, err := s.client.Post(fmt.Sprintf("%s%s", s.url, SidecarDKGPath), "application/json", bytes.NewBuffer(requestBytes))
 	if err != nil {
 		return fmt.Errorf("error making HTTP request: %w", err)
 	}
+	defer res.Body.Close()
 	if res.StatusCode != http.StatusNoContent {
 		return fmt.Errorf("error broadcasting DKG to %s. Node returned status code %d", s.url, res.StatusCode)
 	}
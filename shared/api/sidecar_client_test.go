@@ -4,6 +4,7 @@ import (
 	"errors"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/jarcoal/httpmock"
 	"github.com/stretchr/testify/require"
@@ -26,17 +27,41 @@ func TestSidecarHealthUp(t *testing.T) {
 	defer httpmock.DeactivateAndReset()
 
 	httpmock.RegisterResponder("GET", "https://example.org/health", httpmock.NewStringResponder(http.StatusOK, ""))
-	err := client.Health()
+	_, err := client.Health()
 	require.NoError(t, err)
 	require.Equal(t, 1, httpmock.GetTotalCallCount())
 }
 
+func TestSidecarHealthParsesResponse(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	body := `{"version":"v0.0.1","networks":["hoodi"],"timestamp":"2024-01-02T03:04:05Z"}`
+	httpmock.RegisterResponder("GET", "https://example.org/health", httpmock.NewStringResponder(http.StatusOK, body))
+	response, err := client.Health()
+	require.NoError(t, err)
+	require.Equal(t, "v0.0.1", response.Version)
+	require.Equal(t, []string{"hoodi"}, response.Networks)
+	require.Equal(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), response.Timestamp)
+}
+
+func TestSidecarHealthFallsBackToDateHeader(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	responder := httpmock.NewStringResponder(http.StatusOK, "").HeaderSet(http.Header{"Date": []string{"Tue, 02 Jan 2024 03:04:05 GMT"}})
+	httpmock.RegisterResponder("GET", "https://example.org/health", responder)
+	response, err := client.Health()
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), response.Timestamp)
+}
+
 func TestSidecarHealthDown(t *testing.T) {
 	httpmock.Activate()
 	defer httpmock.DeactivateAndReset()
 
 	httpmock.RegisterResponder("GET", "https://example.org/health", httpmock.NewStringResponder(http.StatusServiceUnavailable, ""))
-	err := client.Health()
+	_, err := client.Health()
 	require.Error(t, err)
 	require.Equal(t, 1, httpmock.GetTotalCallCount())
 }
@@ -48,7 +73,7 @@ func TestSidecarHealthErr(t *testing.T) {
 	expectedErr := errors.New("downstream")
 	httpmock.RegisterResponder("GET", "https://example.org/health", httpmock.NewErrorResponder(expectedErr))
 
-	err := client.Health()
+	_, err := client.Health()
 	require.ErrorIs(t, err, expectedErr)
 	require.Equal(t, 1, httpmock.GetTotalCallCount())
 }
@@ -11,22 +11,16 @@ type SsvClient struct {
 	baseUrl string
 }
 
-func MainnetSsvClient() SsvClient {
+// NewSsvClient creates a client for the SSV API of a given network, e.g. from `network.Network.SsvApiUrl`
+func NewSsvClient(baseUrl string) SsvClient {
 	return SsvClient{
-		baseUrl: "https://api.ssv.network/api/v4/mainnet",
+		baseUrl: baseUrl,
 	}
 }
 
-func HoleskySsvClient() SsvClient {
-	return SsvClient{
-		baseUrl: "https://api.ssv.network/api/v4/holesky",
-	}
-}
-
-func HoodiSsvClient() SsvClient {
-	return SsvClient{
-		baseUrl: "https://api.ssv.network/api/v4/hoodi",
-	}
+// IsConfigured returns false for a zero SsvClient, which can't make any requests
+func (s SsvClient) IsConfigured() bool {
+	return s.baseUrl != ""
 }
 
 type SsvApiResponse struct {
@@ -55,3 +49,34 @@ func (s SsvClient) FetchPublicKeyFromSsv(operatorID uint32) (SsvApiResponse, err
 	}
 	return apiResponse, nil
 }
+
+type SsvAccountResponse struct {
+	Data struct {
+		// the owner's next validator nonce in the SSV contract, which goes up every time they register a validator
+		Nonce uint32 `json:"nonce"`
+	} `json:"data"`
+}
+
+// FetchOwnerNonce returns the validator nonce the owner must use to register their next validator on SSV
+func (s SsvClient) FetchOwnerNonce(owner []byte) (uint32, error) {
+	res, err := http.Get(fmt.Sprintf("%s/accounts/0x%x", s.baseUrl, owner))
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("SSV API returned status code %d", res.StatusCode)
+	}
+
+	responseBytes, err := io.ReadAll(res.Body)
+	if err != nil {
+		return 0, fmt.Errorf("error reading response body: %w", err)
+	}
+
+	var apiResponse SsvAccountResponse
+	if err := json.Unmarshal(responseBytes, &apiResponse); err != nil {
+		return 0, fmt.Errorf("error marshalling response body: %w", err)
+	}
+	return apiResponse.Data.Nonce, nil
+}
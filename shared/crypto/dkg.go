@@ -92,6 +92,39 @@ func UnmarshalPubPoly(scheme ThresholdScheme, b []byte) (share.PubPoly, error) {
 	return *share.NewPubPoly(group, base, commits), nil
 }
 
+// VerifySharePublicKey checks an operator's share public key is the public polynomial evaluated at the operator's index.
+// The DKG indexes operators from operatorID-1, as kyber evaluates index i at x=i+1
+func VerifySharePublicKey(scheme ThresholdScheme, publicPolynomial []byte, operatorID uint32, sharePublicKey []byte) error {
+	if operatorID == 0 {
+		return errors.New("operator ID must be greater than 0")
+	}
+	pubPoly, err := UnmarshalPubPoly(scheme, publicPolynomial)
+	if err != nil {
+		return err
+	}
+	expected, err := pubPoly.Eval(int(operatorID - 1)).V.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(expected, sharePublicKey) {
+		return fmt.Errorf("the share public key of operator %d does not match the public polynomial", operatorID)
+	}
+	return nil
+}
+
+// VerifyPolynomialThreshold checks the public polynomial has exactly `threshold` coefficients,
+// i.e. that exactly `threshold` shares are needed to recover the key
+func VerifyPolynomialThreshold(scheme ThresholdScheme, publicPolynomial []byte, threshold int) error {
+	pubPoly, err := UnmarshalPubPoly(scheme, publicPolynomial)
+	if err != nil {
+		return err
+	}
+	if pubPoly.Threshold() != threshold {
+		return fmt.Errorf("the public polynomial has a threshold of %d, but %d was expected", pubPoly.Threshold(), threshold)
+	}
+	return nil
+}
+
 func ExtractGroupPublicKey(scheme ThresholdScheme, publicPolynomial []byte) []byte {
 	return publicPolynomial[0:scheme.KeyGroup().PointLen()]
 }
@@ -59,3 +59,38 @@ func TestUnmarshallingShortDistKeyDoesntPanic(t *testing.T) {
 	_, err = UnmarshalDistKey(scheme, tooShortButHasFirstParam)
 	require.Error(t, err)
 }
+
+func TestVerifySharePublicKey(t *testing.T) {
+	scheme := NewBLSSuite()
+	rand := scheme.suite.RandomStream()
+
+	priv := share.NewPriPoly(scheme.KeyGroup(), 3, nil, rand)
+	pub := priv.Commit(scheme.KeyGroup().Point().Base())
+	polynomial, err := MarshalPubPoly(pub)
+	require.NoError(t, err)
+
+	shares := priv.Shares(4)
+	for _, s := range shares {
+		sharePublicKey, err := scheme.KeyGroup().Point().Mul(s.V, nil).MarshalBinary()
+		require.NoError(t, err)
+		operatorID := uint32(s.I + 1)
+
+		require.NoError(t, VerifySharePublicKey(scheme, polynomial, operatorID, sharePublicKey))
+		require.Error(t, VerifySharePublicKey(scheme, polynomial, operatorID%4+1, sharePublicKey))
+	}
+
+	require.Error(t, VerifySharePublicKey(scheme, polynomial, 0, []byte{}))
+}
+
+func TestVerifyPolynomialThreshold(t *testing.T) {
+	scheme := NewBLSSuite()
+	rand := scheme.suite.RandomStream()
+
+	priv := share.NewPriPoly(scheme.KeyGroup(), 3, nil, rand)
+	polynomial, err := MarshalPubPoly(priv.Commit(scheme.KeyGroup().Point().Base()))
+	require.NoError(t, err)
+
+	require.NoError(t, VerifyPolynomialThreshold(scheme, polynomial, 3))
+	require.Error(t, VerifyPolynomialThreshold(scheme, polynomial, 2))
+	require.Error(t, VerifyPolynomialThreshold(scheme, polynomial, 4))
+}
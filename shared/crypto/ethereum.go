@@ -15,6 +15,21 @@ import (
 	"github.com/protolambda/ztyp/tree"
 )
 
+// deposit amounts are denominated in gwei
+const (
+	GweiPerEth           uint64 = 1_000_000_000
+	MinDepositAmount            = 1 * GweiPerEth
+	MinActivationBalance        = 32 * GweiPerEth
+	MaxEffectiveBalance         = 2048 * GweiPerEth
+)
+
+// the first byte of the withdrawal credentials denotes how withdrawals are processed
+const (
+	BLSWithdrawalPrefix         byte = 0x00
+	ExecutionWithdrawalPrefix   byte = 0x01
+	CompoundingWithdrawalPrefix byte = 0x02
+)
+
 type DepositMessage struct {
 	WithdrawalCredentials []byte
 	Amount                uint64
@@ -116,7 +131,9 @@ func DepositMessageSigningRoot(data DepositMessage, forkVersion []byte) ([]byte,
 		return nil, fmt.Errorf("genesis fork version must be 4 bytes; got %d", len(forkVersion))
 	}
 
-	// seems the spectests was missing this one
+	// seems the spectests was missing this one.
+	// The deposit domain deliberately uses a zero genesis validators root, as deposits can be made
+	// before genesis; the fork version alone is what binds a deposit to a chain
 	forkData := &ForkData{
 		forkVersion,
 		make([]byte, 32),
@@ -141,8 +158,8 @@ func DepositMessageSigningRoot(data DepositMessage, forkVersion []byte) ([]byte,
 
 // DepositMessageRoot is the merkle root included in the deposit data
 func DepositMessageRoot(data DepositMessage) ([]byte, error) {
-	if len(data.WithdrawalCredentials) != 32 {
-		return nil, errors.New("withdrawal credentials must be 32 bytes; actual length " + strconv.Itoa(len(data.WithdrawalCredentials)))
+	if err := ValidateWithdrawalCredentials(data.WithdrawalCredentials); err != nil {
+		return nil, err
 	}
 
 	if data.Amount <= 0 {
@@ -186,8 +203,8 @@ func (d DepositData) AsETH() spectests.DepositData {
 
 // DepositDataRoot is the merkle root included in the deposit data
 func DepositDataRoot(data DepositData) ([]byte, error) {
-	if len(data.WithdrawalCredentials) != 32 {
-		return nil, errors.New("withdrawal credentials must be 32 bytes; actual length " + strconv.Itoa(len(data.WithdrawalCredentials)))
+	if err := ValidateWithdrawalCredentials(data.WithdrawalCredentials); err != nil {
+		return nil, err
 	}
 
 	if data.Amount <= 0 {
@@ -217,6 +234,41 @@ func ValidatorNonceMessage(address []byte, validatorNonce uint32) ([]byte, error
 	return crypto.Keccak256(msg), nil
 }
 
+// OwnerTransferMessage is the message a validator's current owner signs to authorise a reshare transferring the validator
+// to a new owner. It names the validator's sessionID and the new owner's nonce, so the signature can't be reused for another transfer
+func OwnerTransferMessage(sessionID []byte, newOwner []byte, validatorNonce uint32) string {
+	return fmt.Sprintf("ssv-dkg: transfer the validator with session ID 0x%x to %s with validator nonce %d", sessionID, FormatAddress(newOwner), validatorNonce)
+}
+
+// OwnerTransferHash is the EIP-191 hash of the OwnerTransferMessage, which the owner's wallet signs
+func OwnerTransferHash(sessionID []byte, newOwner []byte, validatorNonce uint32) []byte {
+	message := OwnerTransferMessage(sessionID, newOwner, validatorNonce)
+	return crypto.Keccak256([]byte(fmt.Sprintf("\x19Ethereum Signed Message:\n%d%s", len(message), message)))
+}
+
+// VerifyOwnerTransfer checks the signature is an EIP-191 signature by the current owner over the message authorising
+// the transfer to the new owner, as produced by wallets' `personal_sign`
+func VerifyOwnerTransfer(owner []byte, sessionID []byte, newOwner []byte, validatorNonce uint32, signature []byte) error {
+	if len(signature) != crypto.SignatureLength {
+		return fmt.Errorf("the owner transfer signature must be %d bytes; got %d", crypto.SignatureLength, len(signature))
+	}
+	// wallets add 27 to the recovery ID
+	sig := bytes.Clone(signature)
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+
+	publicKey, err := crypto.SigToPub(OwnerTransferHash(sessionID, newOwner, validatorNonce), sig)
+	if err != nil {
+		return fmt.Errorf("invalid owner transfer signature: %w", err)
+	}
+	signer := crypto.PubkeyToAddress(*publicKey)
+	if signer != common.BytesToAddress(owner) {
+		return fmt.Errorf("the owner transfer was signed by %s, not the validator's owner %s", signer.String(), FormatAddress(owner))
+	}
+	return nil
+}
+
 func FormatAddress(address []byte) string {
 	return common.BytesToAddress(address).String()
 }
@@ -234,3 +286,88 @@ func hashToRoot(data HashToRootable) ([]byte, error) {
 	}
 	return buf.Bytes(), nil
 }
+
+// NewWithdrawalCredentials creates execution layer withdrawal credentials for the given ETH address,
+// i.e. the prefix followed by 11 zero bytes and the 20 byte address
+func NewWithdrawalCredentials(prefix byte, address []byte) ([]byte, error) {
+	if prefix != ExecutionWithdrawalPrefix && prefix != CompoundingWithdrawalPrefix {
+		return nil, fmt.Errorf("withdrawal credentials for an address must have type 0x01 or 0x02; got 0x%02x", prefix)
+	}
+	if len(address) != common.AddressLength {
+		return nil, fmt.Errorf("withdrawal address must be %d bytes; actual length %d", common.AddressLength, len(address))
+	}
+
+	credentials := make([]byte, 32)
+	credentials[0] = prefix
+	copy(credentials[12:], address)
+	return credentials, nil
+}
+
+// ValidateWithdrawalCredentials checks the credentials are the right length and of a known type
+func ValidateWithdrawalCredentials(credentials []byte) error {
+	if len(credentials) != 32 {
+		return errors.New("withdrawal credentials must be 32 bytes; actual length " + strconv.Itoa(len(credentials)))
+	}
+
+	switch credentials[0] {
+	case BLSWithdrawalPrefix:
+		return nil
+	case ExecutionWithdrawalPrefix, CompoundingWithdrawalPrefix:
+		// execution layer credentials are padded with zeros before the address
+		if !bytes.Equal(credentials[1:12], make([]byte, 11)) {
+			return fmt.Errorf("withdrawal credentials of type 0x%02x must be zero padded before the address", credentials[0])
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown withdrawal credentials type 0x%02x", credentials[0])
+	}
+}
+
+// MaxDepositAmount returns the largest balance a validator with the given withdrawal credentials can make use of
+func MaxDepositAmount(credentials []byte) uint64 {
+	if len(credentials) > 0 && credentials[0] == CompoundingWithdrawalPrefix {
+		return MaxEffectiveBalance
+	}
+	return MinActivationBalance
+}
+
+// ValidateInitialDepositAmount checks that the amount is enough to activate a new validator
+// and is consistent with the type of its withdrawal credentials
+func ValidateInitialDepositAmount(credentials []byte, amount uint64) error {
+	if err := ValidateWithdrawalCredentials(credentials); err != nil {
+		return err
+	}
+
+	if amount < MinActivationBalance {
+		return fmt.Errorf("a new validator requires a deposit of at least %d gwei; got %d", MinActivationBalance, amount)
+	}
+
+	return validateDepositAmount(credentials, amount)
+}
+
+// ValidateTopUpAmount checks that the amount can be deposited to an existing validator
+// and is consistent with the type of its withdrawal credentials. Top-ups to 0x01 validators are capped at
+// MinActivationBalance like their initial deposit: it can't tell how much the validator already holds,
+// but any balance above that is swept, so a single larger top-up is always a mistake
+func ValidateTopUpAmount(credentials []byte, amount uint64) error {
+	if err := ValidateWithdrawalCredentials(credentials); err != nil {
+		return err
+	}
+
+	if amount < MinDepositAmount {
+		return fmt.Errorf("a deposit must be at least %d gwei; got %d", MinDepositAmount, amount)
+	}
+
+	return validateDepositAmount(credentials, amount)
+}
+
+func validateDepositAmount(credentials []byte, amount uint64) error {
+	if amount <= 0 {
+		return errors.New("amount must be greater than zero")
+	}
+
+	if maxAmount := MaxDepositAmount(credentials); amount > maxAmount {
+		return fmt.Errorf("withdrawal credentials of type 0x%02x allow a deposit of at most %d gwei; got %d", credentials[0], maxAmount, amount)
+	}
+	return nil
+}
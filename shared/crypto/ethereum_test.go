@@ -53,6 +53,75 @@ func TestDepositDataRoot(t *testing.T) {
 
 }
 
+func TestNewWithdrawalCredentials(t *testing.T) {
+	address, err := hex.DecodeString("81592c3de184a3e2c0dcb5a261bc107bfa91f494")
+	require.NoError(t, err)
+
+	credentials, err := NewWithdrawalCredentials(ExecutionWithdrawalPrefix, address)
+	require.NoError(t, err)
+	require.Equal(t, "01000000000000000000000081592c3de184a3e2c0dcb5a261bc107bfa91f494", hex.EncodeToString(credentials))
+
+	credentials, err = NewWithdrawalCredentials(CompoundingWithdrawalPrefix, address)
+	require.NoError(t, err)
+	require.Equal(t, "02000000000000000000000081592c3de184a3e2c0dcb5a261bc107bfa91f494", hex.EncodeToString(credentials))
+
+	_, err = NewWithdrawalCredentials(BLSWithdrawalPrefix, address)
+	require.Error(t, err)
+
+	_, err = NewWithdrawalCredentials(ExecutionWithdrawalPrefix, address[1:])
+	require.Error(t, err)
+}
+
+func TestDepositAmountMatchesCredentialType(t *testing.T) {
+	address, err := hex.DecodeString("81592c3de184a3e2c0dcb5a261bc107bfa91f494")
+	require.NoError(t, err)
+	execution, err := NewWithdrawalCredentials(ExecutionWithdrawalPrefix, address)
+	require.NoError(t, err)
+	compounding, err := NewWithdrawalCredentials(CompoundingWithdrawalPrefix, address)
+	require.NoError(t, err)
+	unpadded := append([]byte{ExecutionWithdrawalPrefix, 0x01}, make([]byte, 30)...)
+	unknown := append([]byte{0x03}, make([]byte, 31)...)
+
+	tests := []struct {
+		name        string
+		credentials []byte
+		amount      uint64
+		shouldError bool
+	}{
+		{name: "0x01 with 32 ETH", credentials: execution, amount: MinActivationBalance},
+		{name: "0x01 with more than 32 ETH", credentials: execution, amount: MinActivationBalance + 1, shouldError: true},
+		{name: "0x01 with less than 32 ETH", credentials: execution, amount: MinActivationBalance - 1, shouldError: true},
+		{name: "0x02 with 32 ETH", credentials: compounding, amount: MinActivationBalance},
+		{name: "0x02 with 2048 ETH", credentials: compounding, amount: MaxEffectiveBalance},
+		{name: "0x02 with more than 2048 ETH", credentials: compounding, amount: MaxEffectiveBalance + 1, shouldError: true},
+		{name: "0x01 without zero padding", credentials: unpadded, amount: MinActivationBalance, shouldError: true},
+		{name: "unknown credentials type", credentials: unknown, amount: MinActivationBalance, shouldError: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := ValidateInitialDepositAmount(test.credentials, test.amount)
+			if test.shouldError {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+
+	require.NoError(t, ValidateTopUpAmount(execution, MinDepositAmount))
+	require.Error(t, ValidateTopUpAmount(execution, MinDepositAmount-1))
+	require.Error(t, ValidateTopUpAmount(execution, MinActivationBalance+1))
+	require.NoError(t, ValidateTopUpAmount(compounding, MaxEffectiveBalance))
+
+	// the roots don't depend on the amount being valid for the credentials, only on it being set
+	_, err = DepositMessageRoot(DepositMessage{WithdrawalCredentials: execution, Amount: GweiPerEth, PublicKey: make([]byte, 48)})
+	require.NoError(t, err)
+	_, err = DepositMessageRoot(DepositMessage{WithdrawalCredentials: execution, Amount: MaxEffectiveBalance, PublicKey: make([]byte, 48)})
+	require.NoError(t, err)
+	_, err = DepositMessageRoot(DepositMessage{WithdrawalCredentials: execution, PublicKey: make([]byte, 48)})
+	require.Error(t, err)
+}
+
 func verifyDepositRoots(t *testing.T, d *DepositDataCLI) error {
 	pubKey, err := hex.DecodeString(d.PubKey)
 	if err != nil {
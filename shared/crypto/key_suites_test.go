@@ -4,6 +4,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/herumi/bls-eth-go-binary/bls"
@@ -28,6 +29,9 @@ func TestSigning(t *testing.T) {
 		t.Run("verifying a valid self-signature with the wrong key fails", CreatingKeyAndSigningItAndModifyingKey)
 		t.Run("signing arbitrary bytes can be verified", KeypairSigningArbitraryBytesVerifies)
 		t.Run("invalid message fails for signature", InvalidMessageFailsForSignature)
+		t.Run("modifying the expiry of a self-signature fails verification", ModifyingExpiryFailsVerification)
+		t.Run("expired identities fail the expiry check", ExpiredIdentityFailsExpiryCheck)
+		t.Run("legacy identities without timestamps still verify", LegacyIdentityVerifies)
 	}
 }
 
@@ -177,7 +181,7 @@ func CreatingAKeyAndSigningItAndVerifyingIt(t *testing.T) {
 	k, err := scheme.CreateKeypair()
 	require.NoError(t, err)
 
-	i, err := k.SelfSign(scheme, "hello world", 1)
+	i, err := k.SelfSign(scheme, "hello world", 1, time.Hour)
 	require.NoError(t, err)
 
 	require.NoError(t, i.Verify(scheme))
@@ -189,7 +193,7 @@ func CreatingAKeyAndSigningItAndModifyingTheSignature(t *testing.T) {
 	k, err := scheme.CreateKeypair()
 	require.NoError(t, err)
 
-	i, err := k.SelfSign(scheme, "hello world", 1)
+	i, err := k.SelfSign(scheme, "hello world", 1, time.Hour)
 	require.NoError(t, err)
 
 	i.Signature = []byte("deadbeef")
@@ -203,7 +207,7 @@ func CreatingKeyAndSigningItAndModifyingKey(t *testing.T) {
 	k, err := scheme.CreateKeypair()
 	require.NoError(t, err)
 
-	i, err := k.SelfSign(scheme, "hello world", 1)
+	i, err := k.SelfSign(scheme, "hello world", 1, time.Hour)
 	require.NoError(t, err)
 
 	i.Public = []byte("deadbeef")
@@ -243,10 +247,58 @@ func InvalidOperatorIdFailsVerification(t *testing.T) {
 	k, err := scheme.CreateKeypair()
 	require.NoError(t, err)
 
-	i, err := k.SelfSign(scheme, "hello world", 1)
+	i, err := k.SelfSign(scheme, "hello world", 1, time.Hour)
 	require.NoError(t, err)
 
 	i.OperatorID = 2
 
 	require.Error(t, i.Verify(scheme))
 }
+
+func ModifyingExpiryFailsVerification(t *testing.T) {
+	t.Parallel()
+
+	k, err := scheme.CreateKeypair()
+	require.NoError(t, err)
+
+	i, err := k.SelfSign(scheme, "hello world", 1, time.Hour)
+	require.NoError(t, err)
+
+	i.ExpiresAt += 3600
+
+	require.Error(t, i.Verify(scheme))
+}
+
+func ExpiredIdentityFailsExpiryCheck(t *testing.T) {
+	t.Parallel()
+
+	k, err := scheme.CreateKeypair()
+	require.NoError(t, err)
+
+	i, err := k.SelfSign(scheme, "hello world", 1, time.Hour)
+	require.NoError(t, err)
+
+	require.NoError(t, i.CheckExpiry(time.Now()))
+	require.Error(t, i.CheckExpiry(time.Now().Add(2*time.Hour)))
+}
+
+func LegacyIdentityVerifies(t *testing.T) {
+	t.Parallel()
+
+	k, err := scheme.CreateKeypair()
+	require.NoError(t, err)
+
+	m, err := digestFor(k.Public, "hello world", 1)
+	require.NoError(t, err)
+	sig, err := scheme.Sign(k, crypto.Keccak256(m))
+	require.NoError(t, err)
+
+	i := Identity{OperatorID: 1, Address: "hello world", Public: k.Public, Signature: sig}
+	require.True(t, i.Legacy())
+	require.NoError(t, i.Verify(scheme))
+	require.NoError(t, i.CheckExpiry(time.Now().Add(100*365*24*time.Hour)))
+
+	// a legacy signature can't be passed off as a timed one
+	i.IssuedAt = time.Now().Unix()
+	require.Error(t, i.Verify(scheme))
+}
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"crypto/sha256"
 	"encoding/binary"
+	"fmt"
+	"time"
 
 	"github.com/drand/kyber"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -38,26 +40,32 @@ type Keypair struct {
 	Public  []byte `json:"public"`
 }
 
-// SelfSign signs an address to attribute it to a given public key and returns an Identity
-func (k Keypair) SelfSign(suite SigningScheme, address string, operatorID uint32) (Identity, error) {
-	message, err := digestFor(k.Public, address, operatorID)
+// SelfSign signs an address to attribute it to a given public key and returns an Identity.
+// The identity is issued now and expires after the given validity, or never if it's 0
+func (k Keypair) SelfSign(suite SigningScheme, address string, operatorID uint32, validity time.Duration) (Identity, error) {
+	now := time.Now()
+	identity := Identity{
+		OperatorID: operatorID,
+		Address:    address,
+		Public:     k.Public,
+		IssuedAt:   now.Unix(),
+	}
+	if validity > 0 {
+		identity.ExpiresAt = now.Add(validity).Unix()
+	}
+
+	digest, err := identity.digest()
 	if err != nil {
 		return Identity{}, err
 	}
 
-	digest := crypto.Keccak256(message)
-
 	signature, err := suite.Sign(k, digest)
 	if err != nil {
 		return Identity{}, err
 	}
 
-	return Identity{
-		OperatorID: operatorID,
-		Address:    address,
-		Public:     k.Public,
-		Signature:  signature,
-	}, nil
+	identity.Signature = signature
+	return identity, nil
 }
 
 type Identity struct {
@@ -65,20 +73,83 @@ type Identity struct {
 	Address    string                 `json:"address"`
 	Public     encoding.UnpaddedBytes `json:"public"`
 	Signature  encoding.UnpaddedBytes `json:"signature"`
+	// IssuedAt and ExpiresAt are unix timestamps covered by the signature.
+	// Legacy identities have neither, and never expire
+	IssuedAt  int64 `json:"issued_at,omitempty"`
+	ExpiresAt int64 `json:"expires_at,omitempty"`
+	// SsvSignature is an optional signature over the identity by the SSV operator's RSA key,
+	// binding the sidecar to the operator ID it claims
+	SsvSignature encoding.UnpaddedBytes `json:"ssv_signature,omitempty"`
 }
 
 // Verify checks the signature for a given identity is valid, if e.g. pulled from a remote file
 func (i Identity) Verify(suite SigningScheme) error {
-	m, err := digestFor(i.Public, i.Address, i.OperatorID)
+	d, err := i.digest()
 	if err != nil {
 		return err
 	}
 
-	d := crypto.Keccak256(m)
-
 	return suite.Verify(d, i.Public, i.Signature)
 }
 
+// Legacy returns whether the identity was signed before identities carried an issue and expiry time
+func (i Identity) Legacy() bool {
+	return i.IssuedAt == 0 && i.ExpiresAt == 0
+}
+
+// CheckExpiry checks the identity hasn't expired, so an old identity can't be used after an operator moves or rotates its key
+func (i Identity) CheckExpiry(now time.Time) error {
+	if i.ExpiresAt != 0 && now.Unix() > i.ExpiresAt {
+		return fmt.Errorf("the identity of operator %d expired at %s", i.OperatorID, time.Unix(i.ExpiresAt, 0).UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+func (i Identity) digest() ([]byte, error) {
+	var m []byte
+	var err error
+	if i.Legacy() {
+		m, err = digestFor(i.Public, i.Address, i.OperatorID)
+	} else {
+		m, err = timedDigestFor(i.Public, i.Address, i.OperatorID, i.IssuedAt, i.ExpiresAt)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return crypto.Keccak256(m), nil
+}
+
+// timedDigestFor uses its own domain separation tag, so a timed signature can never be passed off as a legacy one
+func timedDigestFor(publicKey []byte, address string, operatorID uint32, issuedAt int64, expiresAt int64) ([]byte, error) {
+	buf := new(bytes.Buffer)
+
+	dst := []byte("ssv:randamu:sha256:v2")
+	if err := binary.Write(buf, binary.BigEndian, dst); err != nil {
+		return nil, err
+	}
+	if err := binary.Write(buf, binary.BigEndian, publicKey); err != nil {
+		return nil, err
+	}
+	if err := binary.Write(buf, binary.BigEndian, []byte(address)); err != nil {
+		return nil, err
+	}
+	if err := binary.Write(buf, binary.BigEndian, operatorID); err != nil {
+		return nil, err
+	}
+	if err := binary.Write(buf, binary.BigEndian, issuedAt); err != nil {
+		return nil, err
+	}
+	if err := binary.Write(buf, binary.BigEndian, expiresAt); err != nil {
+		return nil, err
+	}
+
+	out := sha256.New()
+	if _, err := out.Write(buf.Bytes()); err != nil {
+		return nil, err
+	}
+	return out.Sum(nil), nil
+}
+
 func digestFor(publicKey []byte, address string, operatorID uint32) ([]byte, error) {
 	buf := new(bytes.Buffer)
 
@@ -112,3 +112,40 @@ func NormalisePublicKeyBytes(fileBytes []byte) ([]byte, error) {
 
 	return x509.MarshalPKCS1PublicKey(parsed), nil
 }
+
+// HashSsvPublicKey hashes an SSV operator's RSA public key in any format `NormalisePublicKeyBytes` accepts,
+// so keys from different sources can be compared
+func HashSsvPublicKey(publicKey []byte) ([]byte, error) {
+	normalised, err := NormalisePublicKeyBytes(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.Sum256(normalised)
+	return h[:], nil
+}
+
+// NormalisePrivateKeyBytes takes an RSA private key in PKCS1 format, or wrapped in PEM as PKCS1 or PKCS8,
+// and transforms it into the PKCS1 format used by the RSA suite
+func NormalisePrivateKeyBytes(keyBytes []byte) ([]byte, error) {
+	if _, err := x509.ParsePKCS1PrivateKey(keyBytes); err == nil {
+		return keyBytes, nil
+	}
+
+	block, _ := pem.Decode(keyBytes)
+	if block == nil {
+		return nil, errors.New("could not decode the private key - not in PEM or PKCS1 format")
+	}
+	if _, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return block.Bytes, nil
+	}
+
+	out, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse PEM: %w", err)
+	}
+	parsed, ok := out.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("the private key wrapped in PEM is not an RSA key")
+	}
+	return x509.MarshalPKCS1PrivateKey(parsed), nil
+}
@@ -0,0 +1,52 @@
+package crypto
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ssvBindingDst separates the SSV operator key's signature over an identity from anything else the key signs
+const ssvBindingDst = "ssv:randamu:ssv-operator-binding"
+
+// SignWithSsvKey binds the identity to the SSV operator that owns the given RSA private key,
+// as only that operator can sign the identity with it
+func (i Identity) SignWithSsvKey(ssvPrivateKey []byte) (Identity, error) {
+	m, err := i.ssvBindingMessage()
+	if err != nil {
+		return Identity{}, err
+	}
+	signature, err := NewRSASuite().Sign(Keypair{Private: ssvPrivateKey}, m)
+	if err != nil {
+		return Identity{}, fmt.Errorf("failed to sign identity with SSV key: %w", err)
+	}
+	i.SsvSignature = signature
+	return i, nil
+}
+
+// VerifySsvSignature checks the identity was signed by the SSV operator with the given public key, e.g. from the SSV API
+func (i Identity) VerifySsvSignature(ssvPublicKey []byte) error {
+	if len(i.SsvSignature) == 0 {
+		return errors.New("the identity is not signed by an SSV operator key")
+	}
+	publicKey, err := NormalisePublicKeyBytes(ssvPublicKey)
+	if err != nil {
+		return err
+	}
+	m, err := i.ssvBindingMessage()
+	if err != nil {
+		return err
+	}
+	if err := NewRSASuite().Verify(m, publicKey, i.SsvSignature); err != nil {
+		return fmt.Errorf("the identity of operator %d is not signed by its SSV operator key: %w", i.OperatorID, err)
+	}
+	return nil
+}
+
+// ssvBindingMessage covers everything the identity's own signature does
+func (i Identity) ssvBindingMessage() ([]byte, error) {
+	d, err := i.digest()
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(ssvBindingDst), d...), nil
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"strings"
 )
 
 // UnpaddedBytes is a wrapper type that ensures bytes are marshalled to base64
@@ -21,7 +22,8 @@ func (b *UnpaddedBytes) UnmarshalJSON(data []byte) error {
 		return err
 	}
 
-	decoded, err := base64.RawStdEncoding.DecodeString(encoded)
+	// values marshalled without the pointer receiver are padded, so we accept both
+	decoded, err := base64.RawStdEncoding.DecodeString(strings.TrimRight(encoded, "="))
 	if err != nil {
 		return err
 	}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"time"
 
 	"github.com/randa-mu/ssv-dkg/shared/api"
 )
@@ -20,6 +21,16 @@ const StateFileName = "state.json"
 const DepositDataFileName = "signed_deposit_data.json"
 const KeyShareFileName = "keystore.json"
 
+// TopUpDepositDataFileName is timestamped, as a validator can be topped up many times
+func TopUpDepositDataFileName() string {
+	return fmt.Sprintf("topup_deposit_data_%d.json", time.Now().Unix())
+}
+
+// RefreshKeyShareFileName is timestamped, as a validator's key shares can be refreshed many times
+func RefreshKeyShareFileName() string {
+	return fmt.Sprintf("refresh_keystore_%d.json", time.Now().Unix())
+}
+
 func CreateFilename(stateDirectory string, output api.SigningOutput, filename string) string {
 	return path.Join(stateDirectory, fmt.Sprintf("%s/%s", hex.EncodeToString(output.SessionID), filename))
 }
@@ -29,7 +40,7 @@ func CreateFilename(stateDirectory string, output api.SigningOutput, filename st
 // it returns the json bytes on file write failure, so they can be printed to console
 // so users don't just lose their DKG state completely if e.g. they write somewhere without perms
 func StoreState(filepath string, state StoredState) ([]byte, error) {
-	return storeWithFlags(filepath, state, os.O_WRONLY)
+	return storeWithFlags(filepath, state, os.O_WRONLY|os.O_TRUNC)
 }
 
 // StoreStateIfNotExists stores the JSON encoded state in a flat file.
@@ -68,6 +79,12 @@ func LoadState(filepath string) (StoredState, error) {
 	}
 
 	var s StoredState
-	err = json.Unmarshal(bytes, &s)
-	return s, err
+	if err = json.Unmarshal(bytes, &s); err != nil {
+		return StoredState{}, err
+	}
+	// older states only record the owner in the owner config, which is the owner the operators were bound to
+	if len(s.SigningOutput.Owner) == 0 {
+		s.SigningOutput.Owner = s.OwnerConfig.Address
+	}
+	return s, nil
 }
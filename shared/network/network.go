@@ -0,0 +1,203 @@
+package network
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/randa-mu/ssv-dkg/shared/registry"
+)
+
+// Network describes everything the CLI and sidecar need to know about an Ethereum network SSV runs on
+type Network struct {
+	Name                  string
+	GenesisForkVersion    []byte
+	GenesisValidatorsRoot []byte
+	SsvApiUrl             string
+	SsvContracts          SsvContracts
+	OperatorsRegistryUrl  string
+	// RegistryMaintainers, if set, must sign the operators registry for it to be trusted
+	RegistryMaintainers registry.Maintainers
+}
+
+type SsvContracts struct {
+	Network string `json:"network" yaml:"network"`
+	Views   string `json:"views" yaml:"views"`
+}
+
+var (
+	Mainnet = Network{
+		Name:                  "mainnet",
+		GenesisForkVersion:    mustDecodeHex("00000000"),
+		GenesisValidatorsRoot: mustDecodeHex("4b363db94e286120d76eb905340fdd4e54bfe9f06bf33ff6cf5ad27f511bfe95"),
+		SsvApiUrl:             "https://api.ssv.network/api/v4/mainnet",
+		SsvContracts: SsvContracts{
+			Network: "0xDD9BC35aE942eF0cFa76930954a156B3fF30a4E1",
+			Views:   "0xafE830B6Ee262ba11cce5F32fDCd760FFE6a66e4",
+		},
+		OperatorsRegistryUrl: "https://raw.githubusercontent.com/randa-mu/ssv-dkg/master/nodes/operators-mainnet.json",
+	}
+
+	Holesky = Network{
+		Name:                  "holesky",
+		GenesisForkVersion:    mustDecodeHex("01017000"),
+		GenesisValidatorsRoot: mustDecodeHex("9143aa7c615a7f7115e2b6aac319c03529df8242ae705fba9df39b79c59fa8b1"),
+		SsvApiUrl:             "https://api.ssv.network/api/v4/holesky",
+		SsvContracts: SsvContracts{
+			Network: "0x38A4794cCEd47d3baf7370CcC43B560D3a1beEFA",
+			Views:   "0x352A18AEe90cdcd825d1E37d9939dCA86C00e281",
+		},
+		OperatorsRegistryUrl: "https://raw.githubusercontent.com/randa-mu/ssv-dkg/master/nodes/operators-holesky.json",
+	}
+
+	Hoodi = Network{
+		Name:                  "hoodi",
+		GenesisForkVersion:    mustDecodeHex("10000910"),
+		GenesisValidatorsRoot: mustDecodeHex("212f13fc4df078b6cb7db228f1c8307566dcecf900867401a92023d7ba99cb5f"),
+		SsvApiUrl:             "https://api.ssv.network/api/v4/hoodi",
+		SsvContracts: SsvContracts{
+			Network: "0x58410Bef803ECd7E63B23664C586A6DB72DAf59c",
+			Views:   "0x5AdDb3f1529C5ec70D77400499eE4bbF328368fe",
+		},
+		OperatorsRegistryUrl: "https://raw.githubusercontent.com/randa-mu/ssv-dkg/master/nodes/operators-hoodi.json",
+	}
+)
+
+// Builtin returns all the networks that don't require a custom network file
+func Builtin() []Network {
+	return []Network{Mainnet, Holesky, Hoodi}
+}
+
+// ByName returns the builtin network with the given name
+func ByName(name string) (Network, error) {
+	for _, n := range Builtin() {
+		if n.Name == name {
+			return n, nil
+		}
+	}
+	return Network{}, fmt.Errorf("unknown network %s; must be one of %s", name, strings.Join(builtinNames(), ", "))
+}
+
+// Resolve takes either the name of a builtin network or the path to a custom network file
+func Resolve(nameOrPath string) (Network, error) {
+	if n, err := ByName(nameOrPath); err == nil {
+		return n, nil
+	}
+
+	if _, err := os.Stat(nameOrPath); err != nil {
+		return Network{}, fmt.Errorf("network must be one of %s or the path to a network file", strings.Join(builtinNames(), ", "))
+	}
+	return Load(nameOrPath)
+}
+
+// networkFile is the on-disk format for custom networks, e.g. devnets.
+// Bytes are hex encoded, with or without a `0x` prefix
+type networkFile struct {
+	Name                  string       `json:"name" yaml:"name"`
+	GenesisForkVersion    string       `json:"genesis_fork_version" yaml:"genesis_fork_version"`
+	GenesisValidatorsRoot string       `json:"genesis_validators_root" yaml:"genesis_validators_root"`
+	SsvApiUrl             string       `json:"ssv_api_url" yaml:"ssv_api_url"`
+	SsvContracts          SsvContracts `json:"ssv_contracts" yaml:"ssv_contracts"`
+	OperatorsRegistryUrl  string       `json:"operators_registry_url" yaml:"operators_registry_url"`
+	RegistryMaintainers   struct {
+		Keys      []string `json:"keys" yaml:"keys"`
+		Threshold int      `json:"threshold" yaml:"threshold"`
+	} `json:"registry_maintainers" yaml:"registry_maintainers"`
+}
+
+// Load reads a custom network from a YAML or JSON file, depending on its extension
+func Load(path string) (Network, error) {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return Network{}, fmt.Errorf("error reading network file: %w", err)
+	}
+
+	var f networkFile
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(bytes, &f)
+	case ".json":
+		err = json.Unmarshal(bytes, &f)
+	default:
+		return Network{}, fmt.Errorf("network file %s must be .json, .yaml or .yml", path)
+	}
+	if err != nil {
+		return Network{}, fmt.Errorf("error parsing network file %s: %w", path, err)
+	}
+
+	forkVersion, err := decodeHex(f.GenesisForkVersion)
+	if err != nil {
+		return Network{}, fmt.Errorf("invalid genesis fork version: %w", err)
+	}
+	genesisValidatorsRoot, err := decodeHex(f.GenesisValidatorsRoot)
+	if err != nil {
+		return Network{}, fmt.Errorf("invalid genesis validators root: %w", err)
+	}
+
+	maintainers := registry.Maintainers{Threshold: f.RegistryMaintainers.Threshold}
+	for _, k := range f.RegistryMaintainers.Keys {
+		key, err := decodeHex(k)
+		if err != nil {
+			return Network{}, fmt.Errorf("invalid registry maintainer key: %w", err)
+		}
+		maintainers.Keys = append(maintainers.Keys, key)
+	}
+
+	n := Network{
+		Name:                  f.Name,
+		GenesisForkVersion:    forkVersion,
+		GenesisValidatorsRoot: genesisValidatorsRoot,
+		SsvApiUrl:             f.SsvApiUrl,
+		SsvContracts:          f.SsvContracts,
+		OperatorsRegistryUrl:  f.OperatorsRegistryUrl,
+		RegistryMaintainers:   maintainers,
+	}
+	return n, n.Validate()
+}
+
+// Validate checks the network has everything required to sign deposits and register validators
+func (n Network) Validate() error {
+	if n.Name == "" {
+		return errors.New("network name cannot be empty")
+	}
+	if len(n.GenesisForkVersion) != 4 {
+		return fmt.Errorf("genesis fork version must be 4 bytes; got %d", len(n.GenesisForkVersion))
+	}
+	if len(n.GenesisValidatorsRoot) != 32 {
+		return fmt.Errorf("genesis validators root must be 32 bytes; got %d", len(n.GenesisValidatorsRoot))
+	}
+	if n.SsvApiUrl == "" {
+		return errors.New("SSV API URL cannot be empty")
+	}
+	if err := n.RegistryMaintainers.Validate(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func builtinNames() []string {
+	builtin := Builtin()
+	names := make([]string, len(builtin))
+	for i, n := range builtin {
+		names[i] = n.Name
+	}
+	return names
+}
+
+func decodeHex(s string) ([]byte, error) {
+	return hex.DecodeString(strings.TrimPrefix(s, "0x"))
+}
+
+func mustDecodeHex(s string) []byte {
+	b, err := decodeHex(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
@@ -0,0 +1,94 @@
+package network
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuiltinNetworksAreValid(t *testing.T) {
+	for _, n := range Builtin() {
+		require.NoError(t, n.Validate(), n.Name)
+	}
+}
+
+func TestResolveBuiltinNetwork(t *testing.T) {
+	n, err := Resolve("hoodi")
+	require.NoError(t, err)
+	require.Equal(t, Hoodi, n)
+
+	_, err = Resolve("sepolia")
+	require.Error(t, err)
+}
+
+func TestLoadCustomNetwork(t *testing.T) {
+	yamlNetwork := `
+name: devnet
+genesis_fork_version: "0x10000001"
+genesis_validators_root: "0x212f13fc4df078b6cb7db228f1c8307566dcecf900867401a92023d7ba99cb5f"
+ssv_api_url: http://127.0.0.1:3000/api/v4/devnet
+ssv_contracts:
+  network: "0x0000000000000000000000000000000000000001"
+  views: "0x0000000000000000000000000000000000000002"
+operators_registry_url: http://127.0.0.1:3000/operators.json
+registry_maintainers:
+  keys:
+    - "0xdeadbeef"
+    - "cafebabe"
+  threshold: 2
+`
+	jsonNetwork := `{
+		"name": "devnet",
+		"genesis_fork_version": "10000001",
+		"genesis_validators_root": "212f13fc4df078b6cb7db228f1c8307566dcecf900867401a92023d7ba99cb5f",
+		"ssv_api_url": "http://127.0.0.1:3000/api/v4/devnet",
+		"ssv_contracts": {
+			"network": "0x0000000000000000000000000000000000000001",
+			"views": "0x0000000000000000000000000000000000000002"
+		},
+		"operators_registry_url": "http://127.0.0.1:3000/operators.json",
+		"registry_maintainers": {"keys": ["deadbeef", "0xcafebabe"], "threshold": 2}
+	}`
+
+	dir := t.TempDir()
+	yamlPath := path.Join(dir, "devnet.yaml")
+	require.NoError(t, os.WriteFile(yamlPath, []byte(yamlNetwork), 0o644))
+	jsonPath := path.Join(dir, "devnet.json")
+	require.NoError(t, os.WriteFile(jsonPath, []byte(jsonNetwork), 0o644))
+
+	fromYaml, err := Resolve(yamlPath)
+	require.NoError(t, err)
+	fromJson, err := Resolve(jsonPath)
+	require.NoError(t, err)
+
+	require.Equal(t, fromYaml, fromJson)
+	require.Equal(t, "devnet", fromYaml.Name)
+	require.Equal(t, []byte{0x10, 0x00, 0x00, 0x01}, fromYaml.GenesisForkVersion)
+	require.Equal(t, "0x0000000000000000000000000000000000000002", fromYaml.SsvContracts.Views)
+	require.Equal(t, [][]byte{{0xde, 0xad, 0xbe, 0xef}, {0xca, 0xfe, 0xba, 0xbe}}, fromYaml.RegistryMaintainers.Keys)
+	require.Equal(t, 2, fromYaml.RegistryMaintainers.Threshold)
+}
+
+func TestLoadInvalidNetworkFails(t *testing.T) {
+	dir := t.TempDir()
+
+	shortForkVersion := path.Join(dir, "short.json")
+	require.NoError(t, os.WriteFile(shortForkVersion, []byte(`{"name": "devnet", "genesis_fork_version": "1000", "genesis_validators_root": "212f13fc4df078b6cb7db228f1c8307566dcecf900867401a92023d7ba99cb5f", "ssv_api_url": "http://127.0.0.1"}`), 0o644))
+	_, err := Load(shortForkVersion)
+	require.Error(t, err)
+
+	unreachableThreshold := path.Join(dir, "threshold.json")
+	require.NoError(t, os.WriteFile(unreachableThreshold, []byte(`{"name": "devnet", "genesis_fork_version": "10000001", "genesis_validators_root": "212f13fc4df078b6cb7db228f1c8307566dcecf900867401a92023d7ba99cb5f", "ssv_api_url": "http://127.0.0.1", "registry_maintainers": {"keys": ["deadbeef"], "threshold": 2}}`), 0o644))
+	_, err = Load(unreachableThreshold)
+	require.Error(t, err)
+
+	wrongExtension := path.Join(dir, "devnet.toml")
+	require.NoError(t, os.WriteFile(wrongExtension, []byte(`name = "devnet"`), 0o644))
+	_, err = Load(wrongExtension)
+	require.Error(t, err)
+
+	_, err = Load(path.Join(dir, "missing.json"))
+	require.Error(t, err)
+}
@@ -0,0 +1,191 @@
+package registry
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+
+	"github.com/randa-mu/ssv-dkg/shared/crypto"
+)
+
+// Registry is a published list of DKG-compatible operators, such as the `nodes/operators-*.json` files in this repo
+type Registry struct {
+	// Serial increases every time the registry is changed
+	Serial uint64 `json:"serial,omitempty"`
+	// Expiry is when the registry stops being trusted; signed registries must have one
+	Expiry    time.Time  `json:"expiry"`
+	Operators []Operator `json:"operators"`
+	// Revocations are keys, or old registry entries of keys, that must no longer be trusted
+	Revocations []Revocation `json:"revocations,omitempty"`
+
+	// the addresses of private operators the user has chosen to trust without them being registered
+	unregistered []string
+}
+
+// Operator is an operator's signed identity, along with optional details users can select operators by
+type Operator struct {
+	crypto.Identity
+	// where the operator's sidecar is hosted, e.g. a country code or region
+	Location string `json:"location,omitempty"`
+	// the organisation running the operator, so users can avoid several operators sharing a point of failure
+	Organisation string `json:"organisation,omitempty"`
+}
+
+// Resolve loads a registry from a URL if it starts with http:// or https://, or from a local file otherwise.
+// If any maintainers are configured, the registry must be signed by a threshold of them in a detached
+// signature file next to it, and must not have expired
+func Resolve(fileOrUrl string, maintainers Maintainers) (Registry, error) {
+	read := readFile
+	if strings.HasPrefix(fileOrUrl, "http://") || strings.HasPrefix(fileOrUrl, "https://") {
+		read = fetch
+	}
+
+	contents, err := read(fileOrUrl)
+	if err != nil {
+		return Registry{}, err
+	}
+	if !maintainers.Configured() {
+		return parse(contents)
+	}
+
+	signatureContents, err := read(fileOrUrl + SignatureFileSuffix)
+	if err != nil {
+		return Registry{}, fmt.Errorf("the registry must be signed by its maintainers, but its signatures couldn't be loaded: %w", err)
+	}
+	return parseSigned(crypto.NewBLSSuite(), contents, signatureContents, maintainers)
+}
+
+// Load reads an unsigned registry from a local file
+func Load(path string) (Registry, error) {
+	contents, err := readFile(path)
+	if err != nil {
+		return Registry{}, err
+	}
+	return parse(contents)
+}
+
+// Fetch downloads an unsigned registry from a URL
+func Fetch(url string) (Registry, error) {
+	contents, err := fetch(url)
+	if err != nil {
+		return Registry{}, err
+	}
+	return parse(contents)
+}
+
+func readFile(path string) ([]byte, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("there was an error reading the registry file: %w", err)
+	}
+	return contents, nil
+}
+
+func fetch(url string) ([]byte, error) {
+	res, err := http.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach URL %s: %w", url, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("registry URL %s returned status code %d", url, res.StatusCode)
+	}
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	return body, nil
+}
+
+// FindByID returns the operator registered with the given operator ID
+func (r Registry) FindByID(operatorID uint32) (Operator, bool) {
+	for _, o := range r.Operators {
+		if o.OperatorID == operatorID {
+			return o, true
+		}
+	}
+	return Operator{}, false
+}
+
+// FindByAddress returns the operator registered at the given address
+func (r Registry) FindByAddress(address string) (Operator, bool) {
+	for _, o := range r.Operators {
+		if sameAddress(o.Address, address) {
+			return o, true
+		}
+	}
+	return Operator{}, false
+}
+
+// AllowUnregistered returns a copy of the registry that lets the identities of private operators at the given addresses
+// through Pin without being registered. Their keys aren't pinned, but they still mustn't have expired or been revoked,
+// and they can't claim the operator ID of a registered operator
+func (r Registry) AllowUnregistered(addresses ...string) Registry {
+	out := r
+	out.unregistered = append(slices.Clone(r.unregistered), addresses...)
+	return out
+}
+
+// Pin checks an identity returned by an operator matches the one registered for its operator ID,
+// so a compromised host can't swap in a key of its own. Neither identity may have expired or been revoked
+func (r Registry) Pin(identity crypto.Identity) error {
+	registered, found := r.FindByID(identity.OperatorID)
+	if !found {
+		if !slices.ContainsFunc(r.unregistered, func(address string) bool { return sameAddress(address, identity.Address) }) {
+			return fmt.Errorf("operator %d is not in the operators registry", identity.OperatorID)
+		}
+		if err := identity.CheckExpiry(time.Now()); err != nil {
+			return err
+		}
+		return r.CheckKeyRevoked(identity)
+	}
+	now := time.Now()
+	if err := registered.CheckExpiry(now); err != nil {
+		return fmt.Errorf("the operators registry entry is no longer valid: %w", err)
+	}
+	if err := identity.CheckExpiry(now); err != nil {
+		return err
+	}
+	if err := r.CheckRevoked(registered.Identity); err != nil {
+		return err
+	}
+	if err := r.CheckKeyRevoked(identity); err != nil {
+		return err
+	}
+	return registered.Matches(identity)
+}
+
+// Matches checks an identity returned by an operator has the same operator ID, address and key as the registered operator
+func (o Operator) Matches(identity crypto.Identity) error {
+	if o.OperatorID != identity.OperatorID {
+		return fmt.Errorf("operator %d returned the identity of operator %d", o.OperatorID, identity.OperatorID)
+	}
+	if !sameAddress(o.Address, identity.Address) {
+		return fmt.Errorf("operator %d is registered at %s, not %s", o.OperatorID, o.Address, identity.Address)
+	}
+	if !bytes.Equal(o.Public, identity.Public) {
+		return fmt.Errorf("operator %d's key does not match the one in the operators registry", o.OperatorID)
+	}
+	return nil
+}
+
+func sameAddress(a, b string) bool {
+	return strings.TrimSuffix(a, "/") == strings.TrimSuffix(b, "/")
+}
+
+func parse(contents []byte) (Registry, error) {
+	var r Registry
+	if err := json.Unmarshal(contents, &r); err != nil {
+		return Registry{}, fmt.Errorf("there was an error unmarshalling the registry: %w", err)
+	}
+	return r, nil
+}
@@ -0,0 +1,162 @@
+package registry
+
+import (
+	"net/http"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/slices"
+
+	"github.com/randa-mu/ssv-dkg/shared/crypto"
+)
+
+const registryJson = `{
+  "operators": [
+    {
+      "operator_id": 1,
+      "address": "https://example.org",
+      "public": "aGVsbG8",
+      "signature": "d29ybGQ"
+    },
+    {
+      "operator_id": 2,
+      "address": "https://muster.de",
+      "public": "aGVsbG8",
+      "signature": "d29ybGQ",
+      "location": "DE",
+      "organisation": "Muster GmbH"
+    }
+  ]
+}`
+
+func TestLoadRegistryFromFile(t *testing.T) {
+	p := path.Join(t.TempDir(), "operators.json")
+	require.NoError(t, os.WriteFile(p, []byte(registryJson), 0o644))
+
+	r, err := Resolve(p, Maintainers{})
+	require.NoError(t, err)
+	require.Len(t, r.Operators, 2)
+
+	operator, found := r.FindByID(2)
+	require.True(t, found)
+	require.Equal(t, "https://muster.de", operator.Address)
+	require.Equal(t, "DE", operator.Location)
+	require.Equal(t, "Muster GmbH", operator.Organisation)
+
+	_, found = r.FindByID(3)
+	require.False(t, found)
+}
+
+func TestFetchRegistry(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder("GET", "https://example.org/operators.json", httpmock.NewStringResponder(http.StatusOK, registryJson))
+	httpmock.RegisterResponder("GET", "https://example.org/missing.json", httpmock.NewStringResponder(http.StatusNotFound, ""))
+
+	r, err := Resolve("https://example.org/operators.json", Maintainers{})
+	require.NoError(t, err)
+	require.Len(t, r.Operators, 2)
+
+	_, err = Resolve("https://example.org/missing.json", Maintainers{})
+	require.Error(t, err)
+}
+
+func TestPin(t *testing.T) {
+	p := path.Join(t.TempDir(), "operators.json")
+	require.NoError(t, os.WriteFile(p, []byte(registryJson), 0o644))
+	r, err := Load(p)
+	require.NoError(t, err)
+
+	operator, found := r.FindByID(1)
+	require.True(t, found)
+	registered := operator.Identity
+	require.NoError(t, r.Pin(registered))
+
+	withTrailingSlash := registered
+	withTrailingSlash.Address = "https://example.org/"
+	require.NoError(t, r.Pin(withTrailingSlash))
+
+	unregistered := registered
+	unregistered.OperatorID = 3
+	require.Error(t, r.Pin(unregistered))
+
+	movedAddress := registered
+	movedAddress.Address = "https://evil.example.org"
+	require.Error(t, r.Pin(movedAddress))
+
+	swappedKey := registered
+	swappedKey.Public = []byte("evil")
+	require.Error(t, r.Pin(swappedKey))
+}
+
+func TestPinAllowsUnregisteredPrivateOperators(t *testing.T) {
+	p := path.Join(t.TempDir(), "operators.json")
+	require.NoError(t, os.WriteFile(p, []byte(registryJson), 0o644))
+	r, err := Load(p)
+	require.NoError(t, err)
+
+	private := crypto.Identity{OperatorID: 3, Address: "https://private.example.org", Public: []byte("private")}
+	require.Error(t, r.Pin(private))
+
+	allowed := r.AllowUnregistered("https://private.example.org/")
+	require.NoError(t, allowed.Pin(private))
+	require.Error(t, r.Pin(private), "the original registry shouldn't be changed")
+
+	// private operators can't claim the ID of a registered operator
+	impersonating := private
+	impersonating.OperatorID = 1
+	require.Error(t, allowed.Pin(impersonating))
+
+	// and their keys can still be revoked
+	allowed.Revocations = []Revocation{{Public: []byte("private"), Reason: "key leaked"}}
+	require.ErrorContains(t, allowed.Pin(private), "key leaked")
+
+	_, found := r.FindByAddress("https://muster.de/")
+	require.True(t, found)
+	_, found = r.FindByAddress("https://private.example.org")
+	require.False(t, found)
+}
+
+func TestPinRejectsExpiredAndRevokedIdentities(t *testing.T) {
+	now := time.Now().Unix()
+	r := Registry{
+		Operators: []Operator{
+			{Identity: crypto.Identity{OperatorID: 1, Address: "https://example.org", Public: []byte("hello"), IssuedAt: now, ExpiresAt: now + 3600}},
+			{Identity: crypto.Identity{OperatorID: 2, Address: "https://muster.de", Public: []byte("moved"), IssuedAt: now, ExpiresAt: now - 1}},
+			{Identity: crypto.Identity{OperatorID: 3, Address: "https://exemple.fr", Public: []byte("compromised")}},
+		},
+		Revocations: []Revocation{
+			{Public: []byte("hello"), IssuedBefore: now, Reason: "moved to https://example.org"},
+			{Public: []byte("compromised"), Reason: "key leaked"},
+		},
+	}
+
+	// registry entries issued since the revocation are still valid
+	operator, _ := r.FindByID(1)
+	require.NoError(t, r.Pin(operator.Identity))
+
+	// but older ones, including legacy ones, are not
+	old := r
+	old.Operators = slices.Clone(r.Operators)
+	old.Operators[0].IssuedAt = now - 3600
+	require.ErrorContains(t, old.Pin(operator.Identity), "revoked")
+	old.Operators[0].IssuedAt = 0
+	old.Operators[0].ExpiresAt = 0
+	require.ErrorContains(t, old.Pin(operator.Identity), "revoked")
+
+	// sidecars re-sign their identity on every request, so the live identity's issue time isn't checked against it
+	live := operator.Identity
+	live.IssuedAt = now - 3600
+	require.NoError(t, r.Pin(live))
+
+	expired, _ := r.FindByID(2)
+	require.ErrorContains(t, r.Pin(expired.Identity), "expired")
+
+	compromised, _ := r.FindByID(3)
+	require.ErrorContains(t, r.Pin(compromised.Identity), "key leaked")
+}
@@ -0,0 +1,67 @@
+package registry
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/randa-mu/ssv-dkg/shared/crypto"
+	"github.com/randa-mu/ssv-dkg/shared/encoding"
+)
+
+// Revocation stops an operator's key from being trusted, e.g. if it has been compromised,
+// or stops the key's old registry entries from being trusted, e.g. after the operator moves to a new URL
+type Revocation struct {
+	Public encoding.UnpaddedBytes `json:"public"`
+	// IssuedBefore is a unix timestamp; if set, only registry entries issued before it are revoked, including all legacy ones.
+	// Sidecars sign the identities they serve afresh on every request, so it can't revoke those; only revoking the
+	// whole key, by leaving it unset, stops a compromised key from being used
+	IssuedBefore int64  `json:"issued_before,omitempty"`
+	Reason       string `json:"reason,omitempty"`
+}
+
+// Revokes returns whether the revocation applies to the given registry entry's identity
+func (v Revocation) Revokes(identity crypto.Identity) bool {
+	if !bytes.Equal(v.Public, identity.Public) {
+		return false
+	}
+	return v.IssuedBefore == 0 || identity.IssuedAt < v.IssuedBefore
+}
+
+// RevokesKey returns whether the revocation applies to every identity with the given key
+func (v Revocation) RevokesKey(public []byte) bool {
+	return v.IssuedBefore == 0 && bytes.Equal(v.Public, public)
+}
+
+// CheckRevoked returns an error if any of the registry's revocations applies to the identity of one of its entries
+func (r Registry) CheckRevoked(identity crypto.Identity) error {
+	for _, v := range r.Revocations {
+		if v.Revokes(identity) {
+			return v.error(identity)
+		}
+	}
+	return nil
+}
+
+// CheckKeyRevoked returns an error if the key of an identity that isn't from the registry, like one served by
+// a sidecar or recorded in a state file, has been revoked. Revocations of older registry entries don't apply to them,
+// as their issue time says nothing about whether the key was trusted when the entry was
+func (r Registry) CheckKeyRevoked(identity crypto.Identity) error {
+	for _, v := range r.Revocations {
+		if v.RevokesKey(identity.Public) {
+			return v.error(identity)
+		}
+	}
+	return nil
+}
+
+func (v Revocation) error(identity crypto.Identity) error {
+	err := fmt.Errorf("the identity of operator %d at %s has been revoked", identity.OperatorID, identity.Address)
+	if v.IssuedBefore != 0 {
+		err = fmt.Errorf("%w: registry entries issued before %s are no longer valid", err, time.Unix(v.IssuedBefore, 0).UTC().Format(time.RFC3339))
+	}
+	if v.Reason != "" {
+		err = fmt.Errorf("%w (%s)", err, v.Reason)
+	}
+	return err
+}
@@ -0,0 +1,184 @@
+package registry
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/randa-mu/ssv-dkg/shared/crypto"
+	"github.com/randa-mu/ssv-dkg/shared/encoding"
+)
+
+// SignatureFileSuffix is appended to the location of a registry to find its detached signatures
+const SignatureFileSuffix = ".sig"
+
+// registrySigningDomain separates signatures over registries from any other signatures made with the same keys
+const registrySigningDomain = "ssv-dkg/operators-registry/v1"
+
+// Maintainers are the keys trusted to sign a registry, a threshold of which must sign it for it to be accepted
+type Maintainers struct {
+	Keys      [][]byte
+	Threshold int
+}
+
+// Configured returns whether any maintainers have been set, in which case registries must be signed by them
+func (m Maintainers) Configured() bool {
+	return len(m.Keys) > 0
+}
+
+// Validate checks the threshold can be met by the maintainers' keys
+func (m Maintainers) Validate() error {
+	if !m.Configured() {
+		return nil
+	}
+	if m.Threshold < 1 || m.Threshold > len(m.Keys) {
+		return fmt.Errorf("the registry signing threshold must be between 1 and %d; got %d", len(m.Keys), m.Threshold)
+	}
+	return nil
+}
+
+// SignatureFile holds the detached signatures of the maintainers over the exact bytes of a registry file
+type SignatureFile struct {
+	Signatures []MaintainerSignature `json:"signatures"`
+}
+
+type MaintainerSignature struct {
+	PublicKey encoding.UnpaddedBytes `json:"public_key"`
+	Signature encoding.UnpaddedBytes `json:"signature"`
+}
+
+// Sign creates a maintainer's detached signature over the contents of a registry file
+func Sign(suite crypto.SigningScheme, keypair crypto.Keypair, contents []byte) (MaintainerSignature, error) {
+	signature, err := suite.Sign(keypair, signingDigest(contents))
+	if err != nil {
+		return MaintainerSignature{}, err
+	}
+	return MaintainerSignature{PublicKey: keypair.Public, Signature: signature}, nil
+}
+
+// VerifySignatures checks the contents of a registry file are signed by a threshold of distinct maintainers.
+// Signatures by unknown keys are ignored
+func VerifySignatures(suite crypto.SigningScheme, contents []byte, signatures SignatureFile, maintainers Maintainers) error {
+	if err := maintainers.Validate(); err != nil {
+		return err
+	}
+	digest := signingDigest(contents)
+	signed := make([]bool, len(maintainers.Keys))
+	count := 0
+	for _, s := range signatures.Signatures {
+		for i, key := range maintainers.Keys {
+			if signed[i] || !bytes.Equal(key, s.PublicKey) {
+				continue
+			}
+			if err := suite.Verify(digest, key, s.Signature); err != nil {
+				return fmt.Errorf("invalid registry signature from maintainer %x: %w", key, err)
+			}
+			signed[i] = true
+			count++
+		}
+	}
+	if count < maintainers.Threshold {
+		return fmt.Errorf("the registry is signed by %d maintainers, but %d are required", count, maintainers.Threshold)
+	}
+	return nil
+}
+
+// CheckFresh checks a signed registry hasn't expired, so an old registry can't be replayed forever
+func (r Registry) CheckFresh(now time.Time) error {
+	if r.Expiry.IsZero() {
+		return errors.New("the registry has no expiry")
+	}
+	if now.After(r.Expiry) {
+		return fmt.Errorf("the registry (serial %d) expired at %s", r.Serial, r.Expiry.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// parseSigned verifies the signatures and expiry of a registry file before parsing it
+func parseSigned(suite crypto.SigningScheme, contents []byte, signatureContents []byte, maintainers Maintainers) (Registry, error) {
+	var signatures SignatureFile
+	if err := json.Unmarshal(signatureContents, &signatures); err != nil {
+		return Registry{}, fmt.Errorf("there was an error unmarshalling the registry signatures: %w", err)
+	}
+	if err := VerifySignatures(suite, contents, signatures, maintainers); err != nil {
+		return Registry{}, err
+	}
+
+	r, err := parse(contents)
+	if err != nil {
+		return Registry{}, err
+	}
+	if err := r.CheckFresh(time.Now()); err != nil {
+		return Registry{}, err
+	}
+	return r, nil
+}
+
+func signingDigest(contents []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte(registrySigningDomain))
+	h.Write(contents)
+	return h.Sum(nil)
+}
+
+// ErrRolledBack is returned for a signed registry older than one already seen for the same network
+var ErrRolledBack = errors.New("the registry may have been rolled back")
+
+// CheckSerial checks a signed registry's serial isn't lower than the highest one recorded for the same network
+// in the file at serialsPath. Without this, an older registry that's still validly signed and hasn't expired
+// could be replayed to bring back operators that have since been removed
+func CheckSerial(serialsPath string, networkName string, r Registry) error {
+	serials, err := loadSerials(serialsPath)
+	if err != nil {
+		return err
+	}
+	if lastSeen := serials[networkName]; r.Serial < lastSeen {
+		return fmt.Errorf("%w: the %s registry has serial %d, but serial %d has already been used", ErrRolledBack, networkName, r.Serial, lastSeen)
+	}
+	return nil
+}
+
+// RecordSerial records a signed registry's serial as the highest seen for the network in the file at serialsPath,
+// unless a higher one has already been recorded
+func RecordSerial(serialsPath string, networkName string, r Registry) error {
+	serials, err := loadSerials(serialsPath)
+	if err != nil {
+		return err
+	}
+	if r.Serial <= serials[networkName] {
+		return nil
+	}
+
+	serials[networkName] = r.Serial
+	bytes, err := json.MarshalIndent(serials, "", "  ")
+	if err != nil {
+		return fmt.Errorf("there was an error marshalling the registry serials: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(serialsPath), 0o755); err != nil {
+		return fmt.Errorf("there was an error creating the directory for the registry serials: %w", err)
+	}
+	if err := os.WriteFile(serialsPath, bytes, 0o644); err != nil {
+		return fmt.Errorf("there was an error storing the registry serials: %w", err)
+	}
+	return nil
+}
+
+func loadSerials(serialsPath string) (map[string]uint64, error) {
+	serials := make(map[string]uint64)
+	contents, err := os.ReadFile(serialsPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return serials, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("there was an error reading the registry serials: %w", err)
+	}
+	if err := json.Unmarshal(contents, &serials); err != nil {
+		return nil, fmt.Errorf("there was an error unmarshalling the registry serials in %s: %w", serialsPath, err)
+	}
+	return serials, nil
+}
@@ -0,0 +1,141 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/randa-mu/ssv-dkg/shared/crypto"
+)
+
+func TestSignedRegistry(t *testing.T) {
+	suite := crypto.NewBLSSuite()
+	keypairs := make([]crypto.Keypair, 3)
+	for i := range keypairs {
+		kp, err := suite.CreateKeypair()
+		require.NoError(t, err)
+		keypairs[i] = kp
+	}
+	maintainers := Maintainers{Keys: [][]byte{keypairs[0].Public, keypairs[1].Public, keypairs[2].Public}, Threshold: 2}
+
+	contents := signedRegistryJson(time.Now().Add(time.Hour))
+	dir := t.TempDir()
+	p := path.Join(dir, "operators.json")
+	require.NoError(t, os.WriteFile(p, contents, 0o644))
+
+	// one signature isn't enough
+	writeSignatures(t, p, suite, contents, keypairs[0])
+	_, err := Resolve(p, maintainers)
+	require.Error(t, err)
+
+	// a threshold of maintainers is
+	writeSignatures(t, p, suite, contents, keypairs[0], keypairs[2])
+	r, err := Resolve(p, maintainers)
+	require.NoError(t, err)
+	require.Equal(t, uint64(7), r.Serial)
+	require.Len(t, r.Operators, 1)
+
+	// signing the same registry twice with one key doesn't count twice
+	writeSignatures(t, p, suite, contents, keypairs[0], keypairs[0])
+	_, err = Resolve(p, maintainers)
+	require.Error(t, err)
+
+	// signatures by keys that aren't maintainers don't count
+	outsider, err := suite.CreateKeypair()
+	require.NoError(t, err)
+	writeSignatures(t, p, suite, contents, keypairs[0], outsider)
+	_, err = Resolve(p, maintainers)
+	require.Error(t, err)
+
+	// any change to the registry invalidates the signatures
+	writeSignatures(t, p, suite, contents, keypairs[0], keypairs[1])
+	require.NoError(t, os.WriteFile(p, signedRegistryJson(time.Now().Add(2*time.Hour)), 0o644))
+	_, err = Resolve(p, maintainers)
+	require.Error(t, err)
+
+	// unsigned registries are only accepted if there are no maintainers
+	unsigned := path.Join(dir, "unsigned.json")
+	require.NoError(t, os.WriteFile(unsigned, contents, 0o644))
+	_, err = Resolve(unsigned, maintainers)
+	require.Error(t, err)
+	_, err = Resolve(unsigned, Maintainers{})
+	require.NoError(t, err)
+}
+
+func TestExpiredSignedRegistry(t *testing.T) {
+	suite := crypto.NewBLSSuite()
+	kp, err := suite.CreateKeypair()
+	require.NoError(t, err)
+	maintainers := Maintainers{Keys: [][]byte{kp.Public}, Threshold: 1}
+
+	contents := signedRegistryJson(time.Now().Add(-time.Hour))
+	p := path.Join(t.TempDir(), "operators.json")
+	require.NoError(t, os.WriteFile(p, contents, 0o644))
+	writeSignatures(t, p, suite, contents, kp)
+
+	_, err = Resolve(p, maintainers)
+	require.ErrorContains(t, err, "expired")
+}
+
+func TestMaintainersValidate(t *testing.T) {
+	require.NoError(t, Maintainers{}.Validate())
+	require.NoError(t, Maintainers{Keys: [][]byte{{1}, {2}}, Threshold: 2}.Validate())
+	require.Error(t, Maintainers{Keys: [][]byte{{1}, {2}}, Threshold: 0}.Validate())
+	require.Error(t, Maintainers{Keys: [][]byte{{1}, {2}}, Threshold: 3}.Validate())
+}
+
+func signedRegistryJson(expiry time.Time) []byte {
+	return []byte(fmt.Sprintf(`{
+  "serial": 7,
+  "expiry": "%s",
+  "operators": [
+    {
+      "operator_id": 1,
+      "address": "https://example.org",
+      "public": "aGVsbG8",
+      "signature": "d29ybGQ"
+    }
+  ]
+}`, expiry.UTC().Format(time.RFC3339)))
+}
+
+func writeSignatures(t *testing.T, registryPath string, suite crypto.SigningScheme, contents []byte, keypairs ...crypto.Keypair) {
+	var f SignatureFile
+	for _, kp := range keypairs {
+		s, err := Sign(suite, kp, contents)
+		require.NoError(t, err)
+		f.Signatures = append(f.Signatures, s)
+	}
+	j, err := json.Marshal(f)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(registryPath+SignatureFileSuffix, j, 0o644))
+}
+
+func TestCheckSerialRejectsRollbacks(t *testing.T) {
+	serialsPath := path.Join(t.TempDir(), "nested", "registry-serials.json")
+
+	// checking a serial doesn't record it
+	require.NoError(t, CheckSerial(serialsPath, "hoodi", Registry{Serial: 3}))
+	_, err := os.Stat(serialsPath)
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	require.NoError(t, RecordSerial(serialsPath, "hoodi", Registry{Serial: 3}))
+	// the same registry can be used again, as can a newer one
+	require.NoError(t, CheckSerial(serialsPath, "hoodi", Registry{Serial: 3}))
+	require.NoError(t, CheckSerial(serialsPath, "hoodi", Registry{Serial: 4}))
+
+	// but not one from before the last recorded
+	require.ErrorIs(t, CheckSerial(serialsPath, "hoodi", Registry{Serial: 2}), ErrRolledBack)
+
+	// recording an older serial doesn't lower the last recorded
+	require.NoError(t, RecordSerial(serialsPath, "hoodi", Registry{Serial: 1}))
+	require.ErrorIs(t, CheckSerial(serialsPath, "hoodi", Registry{Serial: 2}), ErrRolledBack)
+
+	// every network's registry has its own serials
+	require.NoError(t, CheckSerial(serialsPath, "mainnet", Registry{Serial: 1}))
+}
@@ -0,0 +1,46 @@
+package shared
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is the version of both the CLI and the sidecar, which are released together
+const Version = "v0.0.1"
+
+// CompatibleVersion checks whether a sidecar running version `other` can take part in a DKG
+// with a CLI running version `ours`. Following semver, the major versions must match, and
+// while the major version is 0, the minor versions must match too
+func CompatibleVersion(ours, other string) error {
+	ourMajor, ourMinor, err := parseVersion(ours)
+	if err != nil {
+		return err
+	}
+	otherMajor, otherMinor, err := parseVersion(other)
+	if err != nil {
+		return err
+	}
+
+	if ourMajor != otherMajor || (ourMajor == 0 && ourMinor != otherMinor) {
+		return fmt.Errorf("version %s is not compatible with %s", other, ours)
+	}
+	return nil
+}
+
+// parseVersion extracts the major and minor versions from versions such as `v1.2.3`
+func parseVersion(version string) (int, int, error) {
+	parts := strings.SplitN(strings.TrimPrefix(version, "v"), ".", 3)
+	if len(parts) < 2 {
+		return 0, 0, fmt.Errorf("invalid version %q", version)
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid version %q: %w", version, err)
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid version %q: %w", version, err)
+	}
+	return major, minor, nil
+}
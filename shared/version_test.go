@@ -0,0 +1,31 @@
+package shared
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompatibleVersion(t *testing.T) {
+	tests := []struct {
+		ours       string
+		other      string
+		compatible bool
+	}{
+		{ours: "v0.0.1", other: "v0.0.2", compatible: true},
+		{ours: "v0.1.0", other: "v0.2.0", compatible: false},
+		{ours: "v1.2.0", other: "v1.5.3", compatible: true},
+		{ours: "v1.2.0", other: "v2.2.0", compatible: false},
+		{ours: "v1.2.0", other: "", compatible: false},
+		{ours: "v1.2.0", other: "banana", compatible: false},
+	}
+
+	for _, test := range tests {
+		err := CompatibleVersion(test.ours, test.other)
+		if test.compatible {
+			require.NoError(t, err, test.other)
+		} else {
+			require.Error(t, err, test.other)
+		}
+	}
+}
@@ -1,10 +1,12 @@
 package sidecar
 
 import (
+	"bytes"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"reflect"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/randa-mu/ssv-dkg/shared"
@@ -15,31 +17,83 @@ import (
 	"github.com/randa-mu/ssv-dkg/sidecar/dkg"
 )
 
+// liveIdentityValidity is how long the identities served by `/identity` are valid for;
+// they're signed afresh on every request, so they only need to outlive the request
+const liveIdentityValidity = 10 * time.Minute
+
 func createAPI(d Daemon) *chi.Mux {
 	router := chi.NewMux()
 	api.BindSidecarAPI(router, d)
 	return router
 }
 
-func (d Daemon) Health() error {
-	return nil
+func (d Daemon) Health() (api.SidecarHealthResponse, error) {
+	networks := make([]string, len(d.networks))
+	for i, n := range d.networks {
+		networks[i] = n.Name
+	}
+	return api.SidecarHealthResponse{
+		Version:   shared.Version,
+		Networks:  networks,
+		Timestamp: time.Now().UTC(),
+	}, nil
 }
 
 func (d Daemon) Sign(request api.SignRequest) (api.SignResponse, error) {
 	sessionID := hex.EncodeToString(request.SessionID)
 
+	if err := api.CheckProtocolVersion(request.ProtocolVersion); err != nil {
+		slog.Error("received a sign request for an unsupported protocol version", "sessionID", sessionID, "err", err)
+		return api.SignResponse{}, err
+	}
+
+	// we refuse to sign deposits that couldn't create a valid validator before we waste time on a DKG
+	if err := request.DepositData.Validate(); err != nil {
+		slog.Error("received invalid deposit data", "sessionID", sessionID, "err", err)
+		return api.SignResponse{}, fmt.Errorf("invalid deposit data: %w", err)
+	}
+	if _, err := matchNetwork(d.networks, request.DepositData); err != nil {
+		slog.Error("received deposit data for an unsupported network", "sessionID", sessionID, "err", err)
+		return api.SignResponse{}, fmt.Errorf("invalid deposit data: %w", err)
+	}
+
+	// the sessionID must be bound to this validator, and we must never run two DKGs with the same one
+	// or the state of the first would be mixed up with the second
+	if err := request.VerifySessionID(); err != nil {
+		slog.Error("received invalid sessionID", "sessionID", sessionID, "err", err)
+		return api.SignResponse{}, err
+	}
+	if !d.sessions.start(sessionID) {
+		slog.Error("received a sessionID that is already in use", "sessionID", sessionID)
+		return api.SignResponse{}, fmt.Errorf("sessionID %s is already in use", sessionID)
+	}
+	defer d.sessions.finish(sessionID)
+	existing, err := d.db.Load(sessionID)
+	if err != nil {
+		slog.Error("error loading state from database", "sessionID", sessionID, "err", err)
+		return api.SignResponse{}, err
+	}
+	if len(existing.GroupFiles) > 0 {
+		slog.Error("received a sessionID that has already been used", "sessionID", sessionID)
+		return api.SignResponse{}, fmt.Errorf("sessionID %s has already been used", sessionID)
+	}
+
 	// run the DKG protocol to retrieve a key share for signing
-	result, err := d.dkg.RunDKG(request.Operators, request.SessionID, d.key)
+	result, err := d.dkg.RunDKG(request.Operators, request.SessionID, d.keys.Current, request.ProtocolVersion, request.FaultTolerant)
 	if err != nil {
 		slog.Error("error running DKG", "sessionID", sessionID, "err", err)
 		return api.SignResponse{}, err
 	}
 
-	// blow up if any nodes failed to qualify for the final group
+	// blow up if any nodes failed to qualify for the final group, unless the client has asked us
+	// to tolerate it; the DKG itself will already have failed if fewer than the threshold qualified
 	if len(result.NodePublicKeys) != len(request.Operators) {
-		msg := "not all operators completed the DKG successfully"
-		slog.Error(msg, "sessionID", sessionID)
-		return api.SignResponse{}, errors.New(msg)
+		if !request.FaultTolerant {
+			msg := "not all operators completed the DKG successfully"
+			slog.Error(msg, "sessionID", sessionID)
+			return api.SignResponse{}, errors.New(msg)
+		}
+		slog.Warn(fmt.Sprintf("only %d of %d operators completed the DKG", len(result.NodePublicKeys), len(request.Operators)), "sessionID", sessionID)
 	}
 
 	// sign the deposit data using the key share
@@ -87,7 +141,7 @@ func (d Daemon) Sign(request api.SignRequest) (api.SignResponse, error) {
 		ValidatorNoncePartialSignature: signedNonce,
 	}
 
-	groupFile, err := dkg.NewGroupFile(sessionID, result.GroupPublicPoly, request.Operators, result.KeyShare, encryptedShare)
+	groupFile, err := dkg.NewGroupFile(sessionID, result.GroupPublicPoly, request.Operators, result.KeyShare, encryptedShare, request.DepositData.WithdrawalCredentials, request.OwnerConfig.Address)
 	if err != nil {
 		slog.Error("error creating group file", "sessionID", sessionID, "err", err)
 		return api.SignResponse{}, err
@@ -115,6 +169,10 @@ func (d Daemon) Reshare(request api.ReshareRequest) (api.ReshareResponse, error)
 		slog.Error("received invalid sessionID for reshare")
 		return api.ReshareResponse{}, errors.New("sessionID cannot be nil for a reshare")
 	}
+	if err := api.CheckProtocolVersion(request.ProtocolVersion); err != nil {
+		slog.Error("received a reshare request for an unsupported protocol version", "sessionID", sessionIDHex, "err", err)
+		return api.ReshareResponse{}, err
+	}
 
 	dkgState, err := d.db.LoadSingle(sessionIDHex, request.PreviousEncryptedShareHash)
 	if err != nil {
@@ -132,8 +190,21 @@ func (d Daemon) Reshare(request api.ReshareRequest) (api.ReshareResponse, error)
 			EncryptedKeyShareHash:       request.PreviousEncryptedShareHash,
 			// our node wasn't in the previous group, so it doesn't have a key share
 			KeyShare: nil,
+			// nor does it know the withdrawal credentials or owner; they're bound into the reshare, so the operators that do vouch for them
+			WithdrawalCredentials: request.PreviousState.WithdrawalCredentials,
+			Owner:                 request.PreviousState.Owner,
 		}
 	} else {
+		// the withdrawal credentials and owner must carry over from the previous group unchanged
+		if err := checkValidatorBinding(sessionIDHex, "withdrawal credentials", dkgState.WithdrawalCredentials, request.PreviousState.WithdrawalCredentials); err != nil {
+			slog.Error("received a reshare request with the wrong withdrawal credentials", "sessionID", sessionIDHex, "err", err)
+			return api.ReshareResponse{}, err
+		}
+		if err := checkValidatorBinding(sessionIDHex, "owner", dkgState.Owner, request.PreviousState.Owner); err != nil {
+			slog.Error("received a reshare request with the wrong owner", "sessionID", sessionIDHex, "err", err)
+			return api.ReshareResponse{}, err
+		}
+
 		// although we store a bunch of state, in case of some failures it's better to trust the client on the commitments etc
 		previousState = dkg.GroupFile{
 			SessionID:                   request.PreviousState.SessionID,
@@ -141,16 +212,45 @@ func (d Daemon) Reshare(request api.ReshareRequest) (api.ReshareResponse, error)
 			PublicPolynomialCommitments: request.PreviousState.PublicPolynomialCommitments,
 			EncryptedKeyShareHash:       request.PreviousEncryptedShareHash,
 			KeyShare:                    dkgState.KeyShare,
+			WithdrawalCredentials:       request.PreviousState.WithdrawalCredentials,
+			Owner:                       request.PreviousState.Owner,
 		}
 	}
 
+	// we only sign the validator nonce of whoever owns the validator, unless they've authorised transferring it
+	newOwner, err := reshareOwner(sessionID, previousState.Owner, request.OwnerConfig)
+	if err != nil {
+		slog.Error("received a reshare request for an owner we can't sign for", "sessionID", sessionIDHex, "err", err)
+		return api.ReshareResponse{}, err
+	}
+
+	// we must take part with whichever key we had in the previous group, even if it has since been rotated
+	keypair, err := d.reshareKeypair(previousState.Nodes, request.Operators)
+	if err != nil {
+		slog.Error("error finding keypair for resharing", "sessionID", sessionIDHex, "err", err)
+		return api.ReshareResponse{}, err
+	}
+
+	// operators leaving the group only deal their key share to the new operators, so they must have one
+	_, inNew := findOperator(request.Operators, d.operatorID)
+	if !inNew && previousState.KeyShare == nil {
+		slog.Error("received a reshare request for a group we are leaving, but we have no key share to deal", "sessionID", sessionIDHex)
+		return api.ReshareResponse{}, errors.New("operator is not in the new group and has no key share for the previous group")
+	}
+
 	// run the resharing protocol to receive a new partial key
-	result, err := d.dkg.RunReshare(request.Operators, sessionID, d.key, previousState)
+	result, err := d.dkg.RunReshare(request.Operators, sessionID, keypair, previousState, newOwner, request.ProtocolVersion)
 	if err != nil {
 		slog.Error("error running resharing", "sessionID", request.PreviousState.SessionID, "err", err)
 		return api.ReshareResponse{}, err
 	}
 
+	// we keep our old key share in case the resharing fails; the client checks the outcome with the new operators
+	if !inNew {
+		slog.Info(fmt.Sprintf("Dealt our key share for sessionID %s to the new group", sessionIDHex))
+		return api.ReshareResponse{}, nil
+	}
+
 	// blow up if any nodes failed to qualify for the final group
 	if len(result.NodePublicKeys) != len(request.Operators) {
 		msg := "not all operators completed the DKG successfully"
@@ -170,8 +270,22 @@ func (d Daemon) Reshare(request api.ReshareRequest) (api.ReshareResponse, error)
 		return api.ReshareResponse{}, err
 	}
 
+	// sign the new validator nonce, so the owner can register the validator again with the new operators
+	var signedNonce []byte
+	if request.OwnerConfig != nil {
+		validatorNonceMessage, err := crypto.ValidatorNonceMessage(request.OwnerConfig.Address, request.OwnerConfig.ValidatorNonce)
+		if err != nil {
+			return api.ReshareResponse{}, fmt.Errorf("error creating validator nonce message: %v", err)
+		}
+		signedNonce, err = d.thresholdScheme.SignWithPartial(result.KeyShare, validatorNonceMessage)
+		if err != nil {
+			slog.Error("error signing nonce", "sessionID", sessionIDHex, "err", err)
+			return api.ReshareResponse{}, err
+		}
+	}
+
 	// store the results of the resharing
-	groupFile, err := dkg.NewGroupFile(sessionIDHex, result.GroupPublicPoly, request.Operators, result.KeyShare, encryptedShare)
+	groupFile, err := dkg.NewGroupFile(sessionIDHex, result.GroupPublicPoly, request.Operators, result.KeyShare, encryptedShare, previousState.WithdrawalCredentials, newOwner)
 	if err != nil {
 		slog.Error("error creating group file", "sessionID", sessionID, "err", err)
 		return api.ReshareResponse{}, err
@@ -186,23 +300,286 @@ func (d Daemon) Reshare(request api.ReshareRequest) (api.ReshareResponse, error)
 	slog.Info(fmt.Sprintf("Resharing with sessionID %s completed successfully", sessionIDHex))
 
 	return api.ReshareResponse{
-		EncryptedShare:   encryptedShare,
-		PublicKeyShare:   result.PublicKeyShare,
-		PublicPolynomial: result.GroupPublicPoly,
+		EncryptedShare:                 encryptedShare,
+		PublicKeyShare:                 result.PublicKeyShare,
+		PublicPolynomial:               result.GroupPublicPoly,
+		ValidatorNoncePartialSignature: signedNonce,
 	}, nil
 }
 
+// reshareKeypair picks the keypair matching our identity in the previous and new groups of a reshare.
+// The DKG only allows one key per node, so if we're in both groups, they must list the same key for us
+func (d Daemon) reshareKeypair(oldNodes []crypto.Identity, newNodes []crypto.Identity) (crypto.Keypair, error) {
+	oldIdentity, inOld := findOperator(oldNodes, d.operatorID)
+	newIdentity, inNew := findOperator(newNodes, d.operatorID)
+	if inOld && inNew && !bytes.Equal(oldIdentity.Public, newIdentity.Public) {
+		return crypto.Keypair{}, fmt.Errorf("operator %d must reshare with the key it had in the previous group (0x%x), not 0x%x", d.operatorID, oldIdentity.Public, newIdentity.Public)
+	}
+
+	identity := newIdentity
+	if inOld {
+		identity = oldIdentity
+	} else if !inNew {
+		return d.keys.Current, nil
+	}
+
+	keypair, found := d.keys.Find(identity.Public)
+	if !found {
+		return crypto.Keypair{}, fmt.Errorf("the key 0x%x for operator %d is not in our keyring", identity.Public, d.operatorID)
+	}
+	return keypair, nil
+}
+
+// checkValidatorBinding checks what a client says a validator was bound to, e.g. its withdrawal credentials, matches what we stored for it.
+// Group files stored by older sidecars don't have them, in which case we trust the client's
+func checkValidatorBinding(sessionID string, name string, stored, requested []byte) error {
+	if len(stored) == 0 {
+		slog.Warn(fmt.Sprintf("no %s stored for the validator, so trusting the client's", name), "sessionID", sessionID, "value", hex.EncodeToString(requested))
+		return nil
+	}
+	if !bytes.Equal(stored, requested) {
+		return fmt.Errorf("the validator for sessionID %s has %s 0x%x, not 0x%x", sessionID, name, stored, requested)
+	}
+	return nil
+}
+
+// reshareOwner returns the owner of the validator after a reshare. Transferring it to a new owner must be authorised
+// by the current owner's signature, or anyone could get the new operators to sign a validator nonce for themselves
+func reshareOwner(sessionID []byte, currentOwner []byte, ownerConfig *api.OwnerConfig) ([]byte, error) {
+	if len(currentOwner) == 0 {
+		return nil, errors.New("the reshare request doesn't say who owns the validator")
+	}
+	if ownerConfig == nil || bytes.Equal(ownerConfig.Address, currentOwner) {
+		return currentOwner, nil
+	}
+	if len(ownerConfig.TransferSignature) == 0 {
+		return nil, fmt.Errorf("transferring the validator to %s must be authorised by its owner %s", crypto.FormatAddress(ownerConfig.Address), crypto.FormatAddress(currentOwner))
+	}
+	if err := crypto.VerifyOwnerTransfer(currentOwner, sessionID, ownerConfig.Address, ownerConfig.ValidatorNonce, ownerConfig.TransferSignature); err != nil {
+		return nil, err
+	}
+	return ownerConfig.Address, nil
+}
+
+func findOperator(identities []crypto.Identity, operatorID uint32) (crypto.Identity, bool) {
+	for _, identity := range identities {
+		if identity.OperatorID == operatorID {
+			return identity, true
+		}
+	}
+	return crypto.Identity{}, false
+}
+
+func (d Daemon) TopUp(request api.TopUpRequest) (api.TopUpResponse, error) {
+	if request.SessionID == "" {
+		return api.TopUpResponse{}, errors.New("sessionID cannot be empty for a top-up")
+	}
+	sessionID, err := dkg.ParseSessionID(request.SessionID)
+	if err != nil {
+		return api.TopUpResponse{}, err
+	}
+	request.SessionID = sessionID
+	if request.EncryptedShareHash == nil {
+		return api.TopUpResponse{}, errors.New("encrypted share hash cannot be empty for a top-up")
+	}
+	if err := api.CheckProtocolVersion(request.ProtocolVersion); err != nil {
+		slog.Error("received a top-up request for an unsupported protocol version", "sessionID", request.SessionID, "err", err)
+		return api.TopUpResponse{}, err
+	}
+
+	if err := request.DepositData.ValidateTopUp(); err != nil {
+		slog.Error("received invalid top-up deposit data", "sessionID", request.SessionID, "err", err)
+		return api.TopUpResponse{}, fmt.Errorf("invalid deposit data: %w", err)
+	}
+	if _, err := matchNetwork(d.networks, request.DepositData); err != nil {
+		slog.Error("received top-up deposit data for an unsupported network", "sessionID", request.SessionID, "err", err)
+		return api.TopUpResponse{}, fmt.Errorf("invalid deposit data: %w", err)
+	}
+
+	groupFile, err := d.db.LoadSingle(request.SessionID, request.EncryptedShareHash)
+	if err != nil {
+		slog.Error("error loading state for top-up", "sessionID", request.SessionID, "err", err)
+		return api.TopUpResponse{}, err
+	}
+	if groupFile.KeyShare == nil {
+		return api.TopUpResponse{}, fmt.Errorf("no key share stored for sessionID %s", request.SessionID)
+	}
+
+	// we only sign top-ups to the withdrawal credentials the validator was created with,
+	// so nobody can get a deposit for the validator's key signed with credentials of their own
+	if len(groupFile.WithdrawalCredentials) == 0 {
+		return api.TopUpResponse{}, fmt.Errorf("no withdrawal credentials stored for sessionID %s; reshare the validator to store them before topping it up", request.SessionID)
+	}
+	if !bytes.Equal(groupFile.WithdrawalCredentials, request.DepositData.WithdrawalCredentials) {
+		err := fmt.Errorf("the validator for sessionID %s has withdrawal credentials 0x%x, not 0x%x", request.SessionID, []byte(groupFile.WithdrawalCredentials), []byte(request.DepositData.WithdrawalCredentials))
+		slog.Error("received a top-up for the wrong withdrawal credentials", "sessionID", request.SessionID, "err", err)
+		return api.TopUpResponse{}, err
+	}
+
+	// we sign for the group public key we stored, rather than one the client sends us
+	groupPublicKey := crypto.ExtractGroupPublicKey(d.thresholdScheme, groupFile.PublicPolynomialCommitments)
+	depositDataMessage, err := crypto.DepositMessageSigningRoot(request.DepositData.IntoMessage(shared.Clone(groupPublicKey)), request.DepositData.ForkVersion)
+	if err != nil {
+		return api.TopUpResponse{}, err
+	}
+	partialSignature, err := d.thresholdScheme.SignWithPartial(groupFile.KeyShare, depositDataMessage)
+	if err != nil {
+		slog.Error("error signing top-up deposit data", "sessionID", request.SessionID, "err", err)
+		return api.TopUpResponse{}, err
+	}
+
+	slog.Info(fmt.Sprintf("Signed a top-up of %d gwei for sessionID %s", request.DepositData.Amount, request.SessionID))
+
+	return api.TopUpResponse{DepositDataPartialSignature: partialSignature}, nil
+}
+
+// VerifyShare checks we still hold the key share for a validator, and that it verifies against the public polynomial
+// we stored for it, so clients can check a reshare would succeed without running one
+func (d Daemon) VerifyShare(request api.VerifyShareRequest) (api.VerifyShareResponse, error) {
+	if request.SessionID == "" {
+		return api.VerifyShareResponse{}, errors.New("sessionID cannot be empty when verifying a key share")
+	}
+	sessionID, err := dkg.ParseSessionID(request.SessionID)
+	if err != nil {
+		return api.VerifyShareResponse{}, err
+	}
+	request.SessionID = sessionID
+	if request.EncryptedShareHash == nil {
+		return api.VerifyShareResponse{}, errors.New("encrypted share hash cannot be empty when verifying a key share")
+	}
+	if err := api.CheckProtocolVersion(request.ProtocolVersion); err != nil {
+		slog.Error("received a verify share request for an unsupported protocol version", "sessionID", request.SessionID, "err", err)
+		return api.VerifyShareResponse{}, err
+	}
+
+	groupFile, err := d.db.LoadSingle(request.SessionID, request.EncryptedShareHash)
+	if err != nil {
+		slog.Error("error loading state for verifying key share", "sessionID", request.SessionID, "err", err)
+		return api.VerifyShareResponse{}, err
+	}
+	if groupFile.KeyShare == nil {
+		return api.VerifyShareResponse{}, fmt.Errorf("no key share stored for sessionID %s", request.SessionID)
+	}
+	if !bytes.Equal(groupFile.PublicPolynomialCommitments, request.PublicPolynomial) {
+		return api.VerifyShareResponse{}, fmt.Errorf("the public polynomial stored for sessionID %s does not match the client's", request.SessionID)
+	}
+
+	keyShare, err := crypto.UnmarshalDistKey(d.thresholdScheme, groupFile.KeyShare)
+	if err != nil {
+		return api.VerifyShareResponse{}, err
+	}
+	if keyShare.I != int(d.operatorID-1) {
+		return api.VerifyShareResponse{}, fmt.Errorf("the key share stored for sessionID %s has index %d, which is not ours", request.SessionID, keyShare.I)
+	}
+	pubPoly, err := crypto.UnmarshalPubPoly(d.thresholdScheme, groupFile.PublicPolynomialCommitments)
+	if err != nil {
+		return api.VerifyShareResponse{}, err
+	}
+	expected := pubPoly.Eval(keyShare.I).V
+	actual := d.thresholdScheme.KeyGroup().Point().Mul(keyShare.V, nil)
+	if !expected.Equal(actual) {
+		slog.Error("stored key share does not verify against the public polynomial", "sessionID", request.SessionID)
+		return api.VerifyShareResponse{}, fmt.Errorf("the key share stored for sessionID %s does not verify against the public polynomial", request.SessionID)
+	}
+
+	sharePublicKey, err := actual.MarshalBinary()
+	if err != nil {
+		return api.VerifyShareResponse{}, err
+	}
+
+	slog.Info(fmt.Sprintf("Verified our key share for sessionID %s", request.SessionID))
+
+	return api.VerifyShareResponse{SharePublicKey: sharePublicKey}, nil
+}
+
+// Prune deletes the key shares for a validator that a refresh superseded, so shares leaked before the refresh can't be
+// reshared, topped up or verified any more. The refreshed share is only trusted to replace them once a threshold of
+// the refreshed group has signed the owner's validator nonce with their refreshed shares, so nobody can make us delete
+// shares in favour of one from a refresh that didn't complete
+func (d Daemon) Prune(request api.PruneRequest) (api.PruneResponse, error) {
+	if request.SessionID == "" {
+		return api.PruneResponse{}, errors.New("sessionID cannot be empty when pruning key shares")
+	}
+	sessionID, err := dkg.ParseSessionID(request.SessionID)
+	if err != nil {
+		return api.PruneResponse{}, err
+	}
+	request.SessionID = sessionID
+	if request.EncryptedShareHash == nil {
+		return api.PruneResponse{}, errors.New("encrypted share hash cannot be empty when pruning key shares")
+	}
+	if err := api.CheckProtocolVersion(request.ProtocolVersion); err != nil {
+		slog.Error("received a prune request for an unsupported protocol version", "sessionID", request.SessionID, "err", err)
+		return api.PruneResponse{}, err
+	}
+
+	groupFile, err := d.db.LoadSingle(request.SessionID, request.EncryptedShareHash)
+	if err != nil {
+		slog.Error("error loading state for pruning key shares", "sessionID", request.SessionID, "err", err)
+		return api.PruneResponse{}, err
+	}
+	if groupFile.KeyShare == nil {
+		return api.PruneResponse{}, fmt.Errorf("no key share stored for sessionID %s", request.SessionID)
+	}
+	if len(groupFile.Owner) == 0 {
+		return api.PruneResponse{}, fmt.Errorf("no owner stored for sessionID %s, so the refreshed key shares can't be confirmed", request.SessionID)
+	}
+
+	validatorNonceMessage, err := crypto.ValidatorNonceMessage(groupFile.Owner, request.ValidatorNonce)
+	if err != nil {
+		return api.PruneResponse{}, err
+	}
+	pubPoly, err := crypto.UnmarshalPubPoly(d.thresholdScheme, groupFile.PublicPolynomialCommitments)
+	if err != nil {
+		return api.PruneResponse{}, err
+	}
+	signers := make(map[int]bool)
+	for _, partial := range request.ValidatorNoncePartialSignatures {
+		index, err := crypto.SigShare(partial).Index()
+		if err != nil || signers[index] {
+			continue
+		}
+		if err := d.thresholdScheme.VerifyPartial(groupFile.PublicPolynomialCommitments, validatorNonceMessage, partial); err != nil {
+			continue
+		}
+		signers[index] = true
+	}
+	if len(signers) < pubPoly.Threshold() {
+		slog.Error("received a prune request without enough valid partial signatures", "sessionID", request.SessionID, "signers", len(signers))
+		return api.PruneResponse{}, fmt.Errorf("only %d operators signed with the refreshed key shares, but %d are required to confirm them", len(signers), pubPoly.Threshold())
+	}
+
+	pruned, err := d.db.Prune(request.SessionID, request.EncryptedShareHash)
+	if err != nil {
+		slog.Error("error pruning key shares", "sessionID", request.SessionID, "err", err)
+		return api.PruneResponse{}, err
+	}
+
+	slog.Info(fmt.Sprintf("Pruned %d superseded key shares for sessionID %s", pruned, request.SessionID))
+
+	return api.PruneResponse{Pruned: pruned}, nil
+}
+
 func (d Daemon) Identity() (api.SidecarIdentityResponse, error) {
-	identity, err := d.key.SelfSign(d.thresholdScheme, d.publicURL, d.operatorID)
+	identity, err := d.keys.Current.SelfSign(d.thresholdScheme, d.publicURL, d.operatorID, liveIdentityValidity)
+	if err != nil {
+		return api.SidecarIdentityResponse{}, err
+	}
+	ssvKeyHash, err := crypto.HashSsvPublicKey(d.ssvKey)
 	if err != nil {
 		return api.SidecarIdentityResponse{}, err
 	}
 
 	return api.SidecarIdentityResponse{
-		OperatorID: identity.OperatorID,
-		PublicKey:  identity.Public,
-		Address:    identity.Address,
-		Signature:  identity.Signature,
+		OperatorID:      identity.OperatorID,
+		PublicKey:       identity.Public,
+		Address:         identity.Address,
+		Signature:       identity.Signature,
+		IssuedAt:        identity.IssuedAt,
+		ExpiresAt:       identity.ExpiresAt,
+		SsvKeyHash:      ssvKeyHash,
+		ProtocolVersion: api.ProtocolVersion,
+		Capabilities:    api.Capabilities(),
 	}, nil
 }
 
@@ -12,6 +12,7 @@ import (
 
 	"github.com/randa-mu/ssv-dkg/shared/api"
 	"github.com/randa-mu/ssv-dkg/shared/crypto"
+	"github.com/randa-mu/ssv-dkg/shared/network"
 	"github.com/randa-mu/ssv-dkg/sidecar/dkg"
 	"github.com/randa-mu/ssv-dkg/sidecar/internal/util"
 )
@@ -22,27 +23,29 @@ type Daemon struct {
 	server           *http.Server
 	dkg              DKGProtocol
 	db               *dkg.FileStore
-	key              crypto.Keypair
+	sessions         *inFlightSessions
+	keys             util.Keyring
 	operatorID       uint32
 	ssvKey           []byte
 	stateDir         string
+	networks         []network.Network
 	thresholdScheme  crypto.ThresholdScheme
 	encryptionScheme crypto.EncryptionScheme
 }
 
 type DKGProtocol interface {
-	RunDKG(identities []crypto.Identity, sessionID []byte, keypair crypto.Keypair) (*dkg.Output, error)
-	RunReshare(identities []crypto.Identity, sessionID []byte, keypair crypto.Keypair, state dkg.GroupFile) (*dkg.Output, error)
+	RunDKG(identities []crypto.Identity, sessionID []byte, keypair crypto.Keypair, protocolVersion uint32, faultTolerant bool) (*dkg.Output, error)
+	RunReshare(identities []crypto.Identity, sessionID []byte, keypair crypto.Keypair, state dkg.GroupFile, newOwner []byte, protocolVersion uint32) (*dkg.Output, error)
 	ProcessPacket(packet api.SidecarDKGPacket) error
 }
 
-func NewDaemon(port uint, publicURL string, stateDir string, publicKeyPath string, operatorID uint32) (Daemon, error) {
+func NewDaemon(port uint, publicURL string, stateDir string, publicKeyPath string, operatorID uint32, networks []network.Network) (Daemon, error) {
 	thresholdScheme := crypto.NewBLSSuite()
 	dkgCoordinator := dkg.NewDKGCoordinator(publicURL, thresholdScheme)
-	return NewDaemonWithDKG(port, publicURL, stateDir, dkgCoordinator, publicKeyPath, operatorID)
+	return NewDaemonWithDKG(port, publicURL, stateDir, dkgCoordinator, publicKeyPath, operatorID, networks)
 }
 
-func NewDaemonWithDKG(port uint, publicURL string, stateDir string, coordinator DKGProtocol, publicKeyPath string, operatorID uint32) (Daemon, error) {
+func NewDaemonWithDKG(port uint, publicURL string, stateDir string, coordinator DKGProtocol, publicKeyPath string, operatorID uint32, networks []network.Network) (Daemon, error) {
 	if port == 0 {
 		return Daemon{}, errors.New("you must provide a port")
 	}
@@ -66,7 +69,16 @@ func NewDaemonWithDKG(port uint, publicURL string, stateDir string, coordinator
 		return Daemon{}, errors.New("you must pass a public URL flag")
 	}
 
-	keypair, err := util.LoadKeypair(stateDir)
+	if len(networks) == 0 {
+		return Daemon{}, errors.New("you must provide at least one network to sign deposits for")
+	}
+	for _, n := range networks {
+		if err := n.Validate(); err != nil {
+			return Daemon{}, fmt.Errorf("invalid network %s: %w", n.Name, err)
+		}
+	}
+
+	keys, err := util.LoadKeyring(stateDir)
 	if err != nil {
 		return Daemon{}, fmt.Errorf("error loading keypair: %w", err)
 	}
@@ -77,18 +89,21 @@ func NewDaemonWithDKG(port uint, publicURL string, stateDir string, coordinator
 	}
 
 	slog.Info(fmt.Sprintf("Keypair loaded from %s", stateDir))
-	slog.Info(fmt.Sprintf("Public key: 0x%x", keypair.Public))
+	slog.Info(fmt.Sprintf("Public key: 0x%x", keys.Current.Public))
+	slog.Info(fmt.Sprintf("Signing deposits for networks: %s", networkNames(networks)))
 
 	thresholdScheme := crypto.NewBLSSuite()
 	daemon := Daemon{
 		port:             port,
-		key:              keypair,
+		keys:             keys,
 		publicURL:        publicURL,
 		ssvKey:           ssvKey,
 		stateDir:         stateDir,
+		networks:         networks,
 		operatorID:       operatorID,
 		dkg:              coordinator,
 		db:               dkg.NewFileStore(stateDir),
+		sessions:         newInFlightSessions(),
 		thresholdScheme:  thresholdScheme,
 		encryptionScheme: crypto.NewRSASuite(),
 	}
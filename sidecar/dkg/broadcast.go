@@ -12,25 +12,27 @@ import (
 )
 
 type DKGBoard struct {
-	lock           sync.Mutex
-	senders        []string
-	packetsSeen    map[string]bool
-	deals          chan dkg.DealBundle
-	responses      chan dkg.ResponseBundle
-	justifications chan dkg.JustificationBundle
+	lock            sync.Mutex
+	senders         []string
+	protocolVersion uint32
+	packetsSeen     map[string]bool
+	deals           chan dkg.DealBundle
+	responses       chan dkg.ResponseBundle
+	justifications  chan dkg.JustificationBundle
 }
 
-func NewDKGBoard(senders []string) *DKGBoard {
+func NewDKGBoard(senders []string, protocolVersion uint32) *DKGBoard {
 	// we have filtered out our own address by senders
 	// but we actually receive a packet for ourself on each of these channels,
 	// so the capacity needs to be +1 or the channel listen will last forever
 	totalPackets := len(senders) + 1
 	return &DKGBoard{
-		senders:        senders,
-		deals:          make(chan dkg.DealBundle, totalPackets),
-		responses:      make(chan dkg.ResponseBundle, totalPackets),
-		justifications: make(chan dkg.JustificationBundle, totalPackets),
-		packetsSeen:    make(map[string]bool),
+		senders:         senders,
+		protocolVersion: protocolVersion,
+		deals:           make(chan dkg.DealBundle, totalPackets),
+		responses:       make(chan dkg.ResponseBundle, totalPackets),
+		justifications:  make(chan dkg.JustificationBundle, totalPackets),
+		packetsSeen:     make(map[string]bool),
 	}
 }
 
@@ -104,7 +106,13 @@ func (d *DKGBoard) IncomingJustification() <-chan dkg.JustificationBundle {
 	return d.justifications
 }
 
+// ProtocolVersion is the protocol version every packet in this DKG must use
+func (d *DKGBoard) ProtocolVersion() uint32 {
+	return d.protocolVersion
+}
+
 func (d *DKGBoard) gossip(packet api.SidecarDKGPacket) {
+	packet.ProtocolVersion = d.protocolVersion
 	slog.Debug("gossiping DKG packets", "to", d.senders)
 	for _, s := range d.senders {
 		go func(s string) {
@@ -1,7 +1,10 @@
 package dkg
 
 import (
+	"bytes"
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -42,7 +45,9 @@ func NewDKGCoordinator(publicURL string, scheme crypto.ThresholdScheme) *Coordin
 	}
 }
 
-func (d *Coordinator) RunDKG(identities []crypto.Identity, sessionID []byte, keypair crypto.Keypair) (*Output, error) {
+// RunDKG runs a DKG creating a new key with the given nodes. Unless it's fault-tolerant, every node must qualify,
+// otherwise only a threshold of them
+func (d *Coordinator) RunDKG(identities []crypto.Identity, sessionID []byte, keypair crypto.Keypair, protocolVersion uint32, faultTolerant bool) (*Output, error) {
 	numberOfNodes := len(identities)
 	threshold := dkg.MinimumT(numberOfNodes)
 	keyGroup := d.scheme.KeyGroup()
@@ -84,7 +89,7 @@ func (d *Coordinator) RunDKG(identities []crypto.Identity, sessionID []byte, key
 		Log:            dkgLogger{address: d.publicURL},
 	}
 
-	d.board = NewDKGBoard(addresses)
+	d.board = NewDKGBoard(addresses, protocolVersion)
 	p := dkg.NewTimePhaser(5 * time.Second)
 	protocol, err := dkg.NewProtocol(&config, d.board, p, false)
 	if err != nil {
@@ -94,7 +99,7 @@ func (d *Coordinator) RunDKG(identities []crypto.Identity, sessionID []byte, key
 	go p.Start()
 	select {
 	case result := <-protocol.WaitEnd():
-		output, err := AsResult(d.scheme, numberOfNodes, result.Result)
+		output, err := AsResult(d.scheme, numberOfNodes, result.Result, faultTolerant)
 		if err != nil {
 			return nil, err
 		}
@@ -105,7 +110,8 @@ func (d *Coordinator) RunDKG(identities []crypto.Identity, sessionID []byte, key
 	}
 }
 
-func (d *Coordinator) RunReshare(identities []crypto.Identity, sessionID []byte, keypair crypto.Keypair, state GroupFile) (*Output, error) {
+// RunReshare moves the key in `state` onto the given nodes, binding what the validator was created with and its new owner into the reshare
+func (d *Coordinator) RunReshare(identities []crypto.Identity, sessionID []byte, keypair crypto.Keypair, state GroupFile, newOwner []byte, protocolVersion uint32) (*Output, error) {
 	numberOfNodes := len(identities)
 	threshold := dkg.MinimumT(numberOfNodes)
 	oldThreshold := dkg.MinimumT(len(state.Nodes))
@@ -133,6 +139,11 @@ func (d *Coordinator) RunReshare(identities []crypto.Identity, sessionID []byte,
 		return nil, err
 	}
 
+	// nodes leaving the group only deal their share to the new nodes, and kyber gives them no result
+	leaving := !slices.ContainsFunc(identities, func(i crypto.Identity) bool {
+		return bytes.Equal(i.Public, keypair.Public)
+	})
+
 	var addresses []string
 	for _, identity := range identities {
 		// if it's not our node, we'd like to gossip packets to it
@@ -167,12 +178,12 @@ func (d *Coordinator) RunReshare(identities []crypto.Identity, sessionID []byte,
 		OldThreshold:   oldThreshold,
 		UserReaderOnly: false,
 		FastSync:       false,
-		Nonce:          sessionID,
+		Nonce:          reshareNonce(sessionID, state, newOwner),
 		Auth:           schnorr.NewScheme(&crypto.SchnorrSuite{Group: keyGroup}),
 		Log:            dkgLogger{address: d.publicURL},
 	}
 
-	d.board = NewDKGBoard(addresses)
+	d.board = NewDKGBoard(addresses, protocolVersion)
 	phaser := dkg.NewTimePhaser(5 * time.Second)
 	protocol, err := dkg.NewProtocol(&config, d.board, phaser, false)
 	if err != nil {
@@ -182,16 +193,44 @@ func (d *Coordinator) RunReshare(identities []crypto.Identity, sessionID []byte,
 	go phaser.Start()
 	select {
 	case result := <-protocol.WaitEnd():
+		if leaving {
+			if result.Error != nil && result.Error.Error() != leavingNodeDealt {
+				return nil, fmt.Errorf("error dealing our share to the new group: %w", result.Error)
+			}
+			slog.Debug("finished dealing our share to the new group", "sessionID", hex.EncodeToString(sessionID))
+			return &Output{}, nil
+		}
 		if result.Error != nil {
 			return nil, result.Error
 		}
-		output, err := AsResult(d.scheme, numberOfNodes, result.Result)
+		output, err := AsResult(d.scheme, numberOfNodes, result.Result, false)
 		return &output, err
 	case <-time.After(d.timeout):
 		return nil, fmt.Errorf("reshare with sessionID %s timed out", hex.EncodeToString(sessionID))
 	}
 }
 
+// reshareNonceDomain separates the nonce of a reshare from the sessionID it's derived from
+const reshareNonceDomain = "ssv-dkg:reshare-nonce"
+
+// reshareNonce binds what the validator was created with, and who owns it, into a reshare. Operators that already hold a share
+// only take part if the client sent them what they stored, and the deals of any operator given something else are for
+// a different nonce and get rejected, so new operators can trust what the client sent them
+func reshareNonce(sessionID []byte, state GroupFile, newOwner []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte(reshareNonceDomain))
+	h.Write(sessionID)
+	for _, field := range [][]byte{state.WithdrawalCredentials, state.Owner, newOwner} {
+		_ = binary.Write(h, binary.BigEndian, uint32(len(field)))
+		h.Write(field)
+	}
+	return h.Sum(nil)
+}
+
+// leavingNodeDealt is the error kyber ends the protocol with for nodes leaving the group once they've dealt their share,
+// as only the new nodes can process the responses to the deals
+const leavingNodeDealt = "leaving node can process responses only after creating shares"
+
 func prepareIdentities(scheme crypto.ThresholdScheme, identities []crypto.Identity) ([]dkg.Node, error) {
 	// sortby operatorID so everyone has a consistent view of the world
 	slices.SortStableFunc(identities, func(i, j crypto.Identity) int {
@@ -222,6 +261,12 @@ func (d *Coordinator) ProcessPacket(packet api.SidecarDKGPacket) error {
 	if d.board == nil {
 		return errors.New("DKG not started yet")
 	}
+	if err := api.CheckProtocolVersion(packet.ProtocolVersion); err != nil {
+		return err
+	}
+	if packet.ProtocolVersion != d.board.ProtocolVersion() {
+		return fmt.Errorf("received a packet for protocol version %d, but the DKG is running protocol version %d", packet.ProtocolVersion, d.board.ProtocolVersion())
+	}
 	if packet.Deal != nil {
 		slog.Debug(fmt.Sprintf("received deal from %d", packet.Deal.DealerIndex))
 		bundle, err := packet.Deal.ToDomain(d.scheme)
@@ -259,14 +304,20 @@ func (d dkgLogger) Error(keyvals ...interface{}) {
 	slog.Error("dkg", "error", fmt.Sprintf("%s", keyvals), "address", d.address)
 }
 
-func AsResult(scheme crypto.ThresholdScheme, countOfNodes int, result *dkg.Result) (Output, error) {
+// AsResult maps the result of the DKG into our output. Every node must have qualified, unless the DKG is fault-tolerant,
+// in which case a threshold of them is enough
+func AsResult(scheme crypto.ThresholdScheme, countOfNodes int, result *dkg.Result, faultTolerant bool) (Output, error) {
 	if result == nil || result.Key == nil {
 		return Output{}, errors.New("DKG result was nil")
 	}
 
-	if countOfNodes != len(result.QUAL) {
+	if !faultTolerant && len(result.QUAL) != countOfNodes {
 		return Output{}, fmt.Errorf("expected %d nodes to complete the DKG, but only %d completed it", countOfNodes, len(result.QUAL))
 	}
+	// below the threshold the key is unusable
+	if threshold := dkg.MinimumT(countOfNodes); len(result.QUAL) < threshold {
+		return Output{}, fmt.Errorf("expected at least %d of %d nodes to complete the DKG, but only %d completed it", threshold, countOfNodes, len(result.QUAL))
+	}
 
 	secretShare, err := crypto.MarshalDistKey(result.Key.Share)
 	if err != nil {
@@ -288,7 +339,7 @@ func AsResult(scheme crypto.ThresholdScheme, countOfNodes int, result *dkg.Resul
 		return Output{}, err
 	}
 
-	// we fail the DKG if any node doesn't make it, so we can safely assume there will be a node at each index
+	// only the qualified nodes are returned, so callers can compare the count against the nodes they expected
 	slices.SortStableFunc(result.QUAL, func(a, b dkg.Node) int {
 		return int(a.Index - b.Index)
 	})
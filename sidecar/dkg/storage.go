@@ -3,6 +3,7 @@ package dkg
 import (
 	"bytes"
 	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -27,6 +28,12 @@ type GroupFile struct {
 	PublicPolynomialCommitments encoding.UnpaddedBytes `json:"public_polynomial_commitments"`
 	KeyShare                    encoding.UnpaddedBytes `json:"key_share"`
 	EncryptedKeyShareHash       encoding.UnpaddedBytes `json:"encrypted_key_share_hash"`
+	// the withdrawal credentials the validator was created with, which we only sign top-ups for.
+	// Group files stored by older sidecars don't have them
+	WithdrawalCredentials encoding.HexBytes `json:"withdrawal_credentials,omitempty"`
+	// the owner of the validator, whose validator nonce we sign when resharing and who must authorise transferring it.
+	// Group files stored by older sidecars don't have it
+	Owner encoding.HexBytes `json:"owner,omitempty"`
 }
 
 type DistPublic struct {
@@ -41,8 +48,17 @@ type FileStore struct {
 type GroupFiles struct {
 	SessionID  string      `json:"session_id"`
 	GroupFiles []GroupFile `json:"group_files"`
+	// the hashes of the encrypted shares that were superseded by a refresh and deleted, so they can be told apart
+	// from shares we never held
+	PrunedShareHashes []encoding.UnpaddedBytes `json:"pruned_share_hashes,omitempty"`
 }
 
+// ErrInvalidSessionID is returned for a session ID that isn't the hex of a sha256 hash, which every session ID is
+var ErrInvalidSessionID = fmt.Errorf("sessionID must be %d bytes of hex", sha256.Size)
+
+// ErrShareSuperseded is returned for a key share that was deleted after a refresh superseded it
+var ErrShareSuperseded = errors.New("the key share was superseded by a refresh and has been deleted")
+
 func NewFileStore(path string) *FileStore {
 	return &FileStore{
 		lock: sync.Mutex{},
@@ -62,32 +78,81 @@ func (f *FileStore) Save(group GroupFile) error {
 		return err
 	}
 
-	var groupFiles GroupFiles
-	if reflect.DeepEqual(g, GroupFiles{}) {
-		groupFiles = GroupFiles{
-			SessionID:  sessionID,
-			GroupFiles: []GroupFile{group},
-		}
-	} else {
-		groupFiles = GroupFiles{
-			SessionID:  sessionID,
-			GroupFiles: append(g.GroupFiles, group),
-		}
+	g.SessionID = sessionID
+	g.GroupFiles = append(g.GroupFiles, group)
+	return f.write(g)
+}
+
+// Prune deletes every group file for a session stored before the one with the given encrypted share hash,
+// once a refresh has replaced them with it, returning how many were deleted. Group files stored after it are kept,
+// as they may be from a later refresh
+func (f *FileStore) Prune(sessionID string, encryptedShareHash []byte) (int, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	g, err := f.Load(sessionID)
+	if err != nil {
+		return 0, err
+	}
+	kept := slices.IndexFunc(g.GroupFiles, func(group GroupFile) bool {
+		return bytes.Equal(group.EncryptedKeyShareHash, encryptedShareHash)
+	})
+	if kept == -1 {
+		return 0, errors.New("could not find state for the encrypted share")
 	}
 
-	p := path.Join(f.path, fmt.Sprintf("%s.json", sessionID))
+	for _, group := range g.GroupFiles[:kept] {
+		g.PrunedShareHashes = append(g.PrunedShareHashes, group.EncryptedKeyShareHash)
+	}
+	g.GroupFiles = g.GroupFiles[kept:]
+	return kept, f.write(g)
+}
+
+// ParseSessionID checks a session ID from a request is the hex of a sha256 hash and returns it re-encoded,
+// so it's safe to name a state file after
+func ParseSessionID(sessionID string) (string, error) {
+	b, err := hex.DecodeString(sessionID)
+	if err != nil || len(b) != sha256.Size {
+		return "", ErrInvalidSessionID
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// sessionPath returns the path of the state file for a session,
+// refusing any session ID that could point it outside the state directory
+func (f *FileStore) sessionPath(sessionID string) (string, error) {
+	sessionID, err := ParseSessionID(sessionID)
+	if err != nil {
+		return "", err
+	}
+	return path.Join(f.path, fmt.Sprintf("%s.json", sessionID)), nil
+}
+
+func (f *FileStore) write(groupFiles GroupFiles) error {
+	p, err := f.sessionPath(groupFiles.SessionID)
+	if err != nil {
+		return err
+	}
 	b, err := json.Marshal(groupFiles)
 	if err != nil {
 		return err
 	}
 
-	return os.WriteFile(p, b, 0o755)
+	// the state holds our key shares, so only we can read it. WriteFile only sets the mode of new files,
+	// so state written by older sidecars is locked down as it's rewritten
+	if err := os.WriteFile(p, b, 0o600); err != nil {
+		return err
+	}
+	return os.Chmod(p, 0o600)
 }
 
 // Load loads a set of group files associated with a given sessionID
 // if none exist, it returns an empty `GroupFiles` object
 func (f *FileStore) Load(sessionID string) (GroupFiles, error) {
-	p := path.Join(f.path, fmt.Sprintf("%s.json", sessionID))
+	p, err := f.sessionPath(sessionID)
+	if err != nil {
+		return GroupFiles{}, err
+	}
 
 	b, err := os.ReadFile(p)
 	if err != nil {
@@ -128,6 +193,12 @@ func (f *FileStore) LoadSingle(sessionID string, encryptedShareHash []byte) (Gro
 			return g, nil
 		}
 	}
+	// a share we deleted must not be mistaken for one we never held, or we'd reshare as if we were joining the group
+	for _, pruned := range groupFiles.PrunedShareHashes {
+		if bytes.Equal(pruned, encryptedShareHash) {
+			return GroupFile{}, ErrShareSuperseded
+		}
+	}
 
 	return GroupFile{}, nil
 }
@@ -156,7 +227,7 @@ func (s *Share) Public() DistPublic {
 	return DistPublic{s.Commits}
 }
 
-func NewGroupFile(sessionID string, pubPoly []byte, nodes []crypto.Identity, share, encryptedShare []byte) (GroupFile, error) {
+func NewGroupFile(sessionID string, pubPoly []byte, nodes []crypto.Identity, share, encryptedShare, withdrawalCredentials, owner []byte) (GroupFile, error) {
 	slices.SortFunc(nodes, func(a, b crypto.Identity) int {
 		return bytes.Compare(a.Public, b.Public)
 	})
@@ -174,5 +245,7 @@ func NewGroupFile(sessionID string, pubPoly []byte, nodes []crypto.Identity, sha
 		PublicPolynomialCommitments: pubPoly,
 		KeyShare:                    share,
 		EncryptedKeyShareHash:       encryptedShareHash,
+		WithdrawalCredentials:       withdrawalCredentials,
+		Owner:                       owner,
 	}, nil
 }
@@ -0,0 +1,50 @@
+package dkg
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStoreRejectsInvalidSessionIDs(t *testing.T) {
+	dir := t.TempDir()
+	store := NewFileStore(path.Join(dir, "state"))
+	require.NoError(t, os.MkdirAll(path.Join(dir, "state"), 0o755))
+	require.NoError(t, os.WriteFile(path.Join(dir, "secret.json"), []byte(`{"session_id": "secret"}`), 0o600))
+
+	for _, sessionID := range []string{"../secret", "cafebabe", strings.Repeat("zz", 32), "../" + strings.Repeat("ab", 31)} {
+		_, err := store.Load(sessionID)
+		require.ErrorIs(t, err, ErrInvalidSessionID, sessionID)
+
+		err = store.Save(GroupFile{SessionID: sessionID})
+		require.ErrorIs(t, err, ErrInvalidSessionID, sessionID)
+	}
+}
+
+func TestFileStoreNormalisesSessionIDs(t *testing.T) {
+	store := NewFileStore(t.TempDir())
+	sessionID := strings.Repeat("ab", 32)
+
+	require.NoError(t, store.Save(GroupFile{SessionID: strings.ToUpper(sessionID), KeyShare: []byte{1}}))
+
+	g, err := store.Load(sessionID)
+	require.NoError(t, err)
+	require.Len(t, g.GroupFiles, 1)
+}
+
+func TestFileStoreOnlyLetsItsOwnerReadState(t *testing.T) {
+	dir := t.TempDir()
+	store := NewFileStore(dir)
+	sessionID := strings.Repeat("ab", 32)
+	p := path.Join(dir, sessionID+".json")
+	require.NoError(t, os.WriteFile(p, []byte(`{}`), 0o755))
+
+	require.NoError(t, store.Save(GroupFile{SessionID: sessionID}))
+
+	info, err := os.Stat(p)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+}
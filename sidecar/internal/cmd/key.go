@@ -3,7 +3,10 @@ package cmd
 import (
 	"fmt"
 	"log"
+	"os"
 	"path"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 
@@ -12,9 +15,12 @@ import (
 )
 
 var (
-	UrlFlag        string
-	OperatorIDFlag uint32
-	keyCmd         = &cobra.Command{
+	UrlFlag                       string
+	OperatorIDFlag                uint32
+	validityFlag                  time.Duration
+	ssvPrivateKeyFlag             string
+	ssvPrivateKeyPasswordFileFlag string
+	keyCmd                        = &cobra.Command{
 		Use:   "key",
 		Short: "All operations related to keys",
 	}
@@ -28,24 +34,77 @@ var (
 		Short: "Writes the signed public key and address to stdout",
 		Run:   signKey,
 	}
+	keyRotateCmd = &cobra.Command{
+		Use:   "rotate",
+		Short: "Replaces the keypair for this node, keeping the old one for reshares, and writes the new signed public key and address to stdout",
+		Run:   rotateKey,
+	}
 )
 
 func init() {
-	keyCmd.AddCommand(keyCreateCmd, keySignCmd)
-	keySignCmd.PersistentFlags().StringVarP(
+	keyCmd.AddCommand(keyCreateCmd, keySignCmd, keyRotateCmd)
+	addSigningFlags(keySignCmd)
+	addSigningFlags(keyRotateCmd)
+}
+
+func addSigningFlags(c *cobra.Command) {
+	c.PersistentFlags().StringVarP(
 		&UrlFlag,
 		"url",
 		"u",
 		"",
 		"The public URL of your sidecar node, including port and scheme",
 	)
-	keySignCmd.PersistentFlags().Uint32VarP(
+	c.PersistentFlags().Uint32VarP(
 		&OperatorIDFlag,
 		"operator-id",
 		"i",
 		0,
 		"The operatorID you received when registering your SSV node",
 	)
+	c.PersistentFlags().DurationVar(
+		&validityFlag,
+		"validity",
+		365*24*time.Hour,
+		"How long the signed identity is valid for before it must be re-signed, or 0 for no expiry",
+	)
+	c.PersistentFlags().StringVar(
+		&ssvPrivateKeyFlag,
+		"ssv-private-key",
+		"",
+		"The path to your SSV node's RSA private key, in PEM format or the `encrypted_private_key.json` created by your SSV node, to bind the identity to your operator ID",
+	)
+	c.PersistentFlags().StringVar(
+		&ssvPrivateKeyPasswordFileFlag,
+		"ssv-private-key-password-file",
+		"",
+		"The path to the file containing the password for an encrypted `ssv-private-key`",
+	)
+}
+
+// loadSsvPrivateKey returns the SSV operator's private key if one was passed, or nil otherwise
+func loadSsvPrivateKey() []byte {
+	if ssvPrivateKeyFlag == "" {
+		if ssvPrivateKeyPasswordFileFlag != "" {
+			log.Fatal("`ssv-private-key-password-file` was passed without an `ssv-private-key`")
+		}
+		return nil
+	}
+
+	var password string
+	if ssvPrivateKeyPasswordFileFlag != "" {
+		bytes, err := os.ReadFile(ssvPrivateKeyPasswordFileFlag)
+		if err != nil {
+			log.Fatalf("failed to read password file: %v", err)
+		}
+		password = strings.TrimSpace(string(bytes))
+	}
+
+	key, err := util.LoadSsvPrivateKey(ssvPrivateKeyFlag, password)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	return key
 }
 
 func createKey(_ *cobra.Command, args []string) {
@@ -73,7 +132,19 @@ func signKey(_ *cobra.Command, _ []string) {
 		log.Fatal("`operator-id` must be set and greater than 0")
 	}
 
-	signature, err := sidecar.SignKey(UrlFlag, DirectoryFlag, OperatorIDFlag)
+	signature, err := sidecar.SignKey(UrlFlag, DirectoryFlag, OperatorIDFlag, validityFlag, loadSsvPrivateKey())
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	fmt.Println(string(signature))
+}
+
+func rotateKey(_ *cobra.Command, _ []string) {
+	if OperatorIDFlag == 0 {
+		log.Fatal("`operator-id` must be set and greater than 0")
+	}
+
+	signature, err := sidecar.RotateKey(UrlFlag, DirectoryFlag, OperatorIDFlag, validityFlag, loadSsvPrivateKey())
 	if err != nil {
 		log.Fatalf("%v", err)
 	}
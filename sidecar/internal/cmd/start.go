@@ -11,6 +11,7 @@ import (
 	"github.com/spf13/cobra"
 	"golang.org/x/exp/slog"
 
+	"github.com/randa-mu/ssv-dkg/shared/network"
 	"github.com/randa-mu/ssv-dkg/sidecar"
 )
 
@@ -19,6 +20,7 @@ var (
 	PublicKeyPathFlag string
 	PublicURLFlag     string
 	VerboseFlag       bool
+	NetworkFlag       []string
 	startCmd          = &cobra.Command{
 		Use:   "start",
 		Short: "Start the DKG sidecar",
@@ -63,6 +65,13 @@ func init() {
 		0,
 		"the operator ID you received from the smart contract when registering your SSV node",
 	)
+	startCmd.PersistentFlags().StringArrayVarP(
+		&NetworkFlag,
+		"network",
+		"n",
+		[]string{"mainnet"},
+		"the networks you will sign deposits for: mainnet, hoodi, holesky or the path to a custom network file. Can be passed multiple times",
+	)
 }
 
 func Start(_ *cobra.Command, _ []string) {
@@ -73,7 +82,17 @@ func Start(_ *cobra.Command, _ []string) {
 		slog.SetDefault(l)
 	}
 
-	daemon, err := sidecar.NewDaemon(PortFlag, PublicURLFlag, DirectoryFlag, PublicKeyPathFlag, OperatorIDFlag)
+	networks := make([]network.Network, len(NetworkFlag))
+	for i, n := range NetworkFlag {
+		resolved, err := network.Resolve(n)
+		if err != nil {
+			slog.Error("error loading network", "network", n, "err", err)
+			os.Exit(1)
+		}
+		networks[i] = resolved
+	}
+
+	daemon, err := sidecar.NewDaemon(PortFlag, PublicURLFlag, DirectoryFlag, PublicKeyPathFlag, OperatorIDFlag, networks)
 	if err != nil {
 		slog.Error("error starting daemon", "err", err)
 		os.Exit(1)
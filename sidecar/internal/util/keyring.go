@@ -0,0 +1,97 @@
+package util
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/randa-mu/ssv-dkg/shared/crypto"
+)
+
+// RetiredKeysSuffix is the file that keeps keypairs that have been rotated out,
+// so the node can still take part in reshares of validators created with them
+const RetiredKeysSuffix = "retired_keypairs.json"
+
+// Keyring holds the node's current keypair, used for new sessions, along with its retired keypairs indexed by public key
+type Keyring struct {
+	Current crypto.Keypair
+	keys    map[string]crypto.Keypair
+}
+
+func NewKeyring(current crypto.Keypair, retired ...crypto.Keypair) Keyring {
+	keys := make(map[string]crypto.Keypair, len(retired)+1)
+	for _, kp := range retired {
+		keys[hex.EncodeToString(kp.Public)] = kp
+	}
+	keys[hex.EncodeToString(current.Public)] = current
+	return Keyring{Current: current, keys: keys}
+}
+
+// Find returns the keypair for the given public key, whether it's current or retired
+func (k Keyring) Find(public []byte) (crypto.Keypair, bool) {
+	kp, ok := k.keys[hex.EncodeToString(public)]
+	return kp, ok
+}
+
+// LoadKeyring loads the current keypair and any retired keypairs from the state directory
+func LoadKeyring(stateDir string) (Keyring, error) {
+	current, err := LoadKeypair(stateDir)
+	if err != nil {
+		return Keyring{}, err
+	}
+	retired, err := loadRetiredKeypairs(stateDir)
+	if err != nil {
+		return Keyring{}, err
+	}
+	return NewKeyring(current, retired...), nil
+}
+
+// RotateKeypair retires the current keypair and replaces it with a new one.
+// The retired keypair is stored before the current one is replaced, so it's never lost
+func RotateKeypair(suite crypto.SigningScheme, stateDir string) (crypto.Keypair, error) {
+	current, err := LoadKeypair(stateDir)
+	if err != nil {
+		return crypto.Keypair{}, err
+	}
+	retired, err := loadRetiredKeypairs(stateDir)
+	if err != nil {
+		return crypto.Keypair{}, err
+	}
+
+	next, err := suite.CreateKeypair()
+	if err != nil {
+		return crypto.Keypair{}, fmt.Errorf("failed to create keypair: %w", err)
+	}
+
+	bytes, err := json.Marshal(append(retired, current))
+	if err != nil {
+		return crypto.Keypair{}, fmt.Errorf("failed to marshal the retired keypairs as JSON: %w", err)
+	}
+	if err := os.WriteFile(path.Join(stateDir, RetiredKeysSuffix), bytes, 0o600); err != nil {
+		return crypto.Keypair{}, fmt.Errorf("failed to store the retired keypairs: %w", err)
+	}
+
+	if err := StoreKeypair(next, path.Join(stateDir, KeySuffix)); err != nil {
+		return crypto.Keypair{}, err
+	}
+	return next, nil
+}
+
+func loadRetiredKeypairs(stateDir string) ([]crypto.Keypair, error) {
+	file, err := os.ReadFile(path.Join(stateDir, RetiredKeysSuffix))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read retired keypairs at %s: %w", stateDir, err)
+	}
+
+	var retired []crypto.Keypair
+	if err = json.Unmarshal(file, &retired); err != nil {
+		return nil, fmt.Errorf("could not unmarshal retired keypairs: %w", err)
+	}
+	return retired, nil
+}
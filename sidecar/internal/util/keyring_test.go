@@ -0,0 +1,49 @@
+package util
+
+import (
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/randa-mu/ssv-dkg/shared/crypto"
+)
+
+func TestRotatingKeypairKeepsRetiredKeys(t *testing.T) {
+	stateDir := t.TempDir()
+	suite := crypto.NewBLSSuite()
+	first, err := suite.CreateKeypair()
+	require.NoError(t, err)
+	require.NoError(t, StoreKeypair(first, path.Join(stateDir, KeySuffix)))
+
+	second, err := RotateKeypair(suite, stateDir)
+	require.NoError(t, err)
+	third, err := RotateKeypair(suite, stateDir)
+	require.NoError(t, err)
+
+	keyring, err := LoadKeyring(stateDir)
+	require.NoError(t, err)
+	require.Equal(t, third, keyring.Current)
+
+	for _, kp := range []crypto.Keypair{first, second, third} {
+		found, ok := keyring.Find(kp.Public)
+		require.True(t, ok)
+		require.Equal(t, kp, found)
+	}
+
+	other, err := suite.CreateKeypair()
+	require.NoError(t, err)
+	_, ok := keyring.Find(other.Public)
+	require.False(t, ok)
+}
+
+func TestKeyringWithoutRetiredKeys(t *testing.T) {
+	stateDir := t.TempDir()
+	kp, err := crypto.NewBLSSuite().CreateKeypair()
+	require.NoError(t, err)
+	require.NoError(t, StoreKeypair(kp, path.Join(stateDir, KeySuffix)))
+
+	keyring, err := LoadKeyring(stateDir)
+	require.NoError(t, err)
+	require.Equal(t, kp, keyring.Current)
+}
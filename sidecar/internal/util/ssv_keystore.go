@@ -0,0 +1,141 @@
+package util
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+
+	"github.com/randa-mu/ssv-dkg/shared/crypto"
+)
+
+// ssvKeystore is the format of the `encrypted_private_key.json` an SSV node creates for its operator key
+type ssvKeystore struct {
+	Checksum struct {
+		Function string `json:"function"`
+		Message  string `json:"message"`
+	} `json:"checksum"`
+	Cipher struct {
+		Function string `json:"function"`
+		Message  string `json:"message"`
+		Params   struct {
+			IV string `json:"iv"`
+		} `json:"params"`
+	} `json:"cipher"`
+	Kdf struct {
+		Function string `json:"function"`
+		Params   struct {
+			C     int    `json:"c"`
+			N     int    `json:"n"`
+			R     int    `json:"r"`
+			P     int    `json:"p"`
+			Dklen int    `json:"dklen"`
+			Prf   string `json:"prf"`
+			Salt  string `json:"salt"`
+		} `json:"params"`
+	} `json:"kdf"`
+}
+
+// LoadSsvPrivateKey loads the SSV operator's RSA private key. If a password is passed, the file is decrypted
+// as the SSV node's encrypted keystore; otherwise it must contain the key in PEM format
+func LoadSsvPrivateKey(filepath string, password string) ([]byte, error) {
+	file, err := os.ReadFile(filepath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rsa private key from %s: %w", filepath, err)
+	}
+
+	if password != "" {
+		file, err = decryptSsvKeystore(file, password)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt rsa private key in %s: %w", filepath, err)
+		}
+	}
+
+	return normaliseSsvPrivateKey(file)
+}
+
+// normaliseSsvPrivateKey accepts the key as PEM, or as base64-encoded PEM as the SSV node stores it
+func normaliseSsvPrivateKey(key []byte) ([]byte, error) {
+	normalised, err := crypto.NormalisePrivateKeyBytes(bytes.TrimSpace(key))
+	if err == nil {
+		return normalised, nil
+	}
+	decoded, decodeErr := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(key)))
+	if decodeErr != nil {
+		return nil, err
+	}
+	return crypto.NormalisePrivateKeyBytes(decoded)
+}
+
+func decryptSsvKeystore(file []byte, password string) ([]byte, error) {
+	var keystore ssvKeystore
+	if err := json.Unmarshal(file, &keystore); err != nil {
+		return nil, fmt.Errorf("could not unmarshal keystore: %w", err)
+	}
+
+	salt, err := hex.DecodeString(keystore.Kdf.Params.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid kdf salt: %w", err)
+	}
+	var decryptionKey []byte
+	switch keystore.Kdf.Function {
+	case "pbkdf2":
+		if keystore.Kdf.Params.Prf != "hmac-sha256" {
+			return nil, fmt.Errorf("unsupported pbkdf2 prf %s", keystore.Kdf.Params.Prf)
+		}
+		decryptionKey = pbkdf2.Key([]byte(password), salt, keystore.Kdf.Params.C, keystore.Kdf.Params.Dklen, sha256.New)
+	case "scrypt":
+		decryptionKey, err = scrypt.Key([]byte(password), salt, keystore.Kdf.Params.N, keystore.Kdf.Params.R, keystore.Kdf.Params.P, keystore.Kdf.Params.Dklen)
+		if err != nil {
+			return nil, fmt.Errorf("invalid scrypt params: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported kdf %s", keystore.Kdf.Function)
+	}
+	if len(decryptionKey) < 32 {
+		return nil, errors.New("the kdf must derive at least 32 bytes")
+	}
+
+	ciphertext, err := hex.DecodeString(keystore.Cipher.Message)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cipher message: %w", err)
+	}
+
+	// the checksum tells us whether the password was right before we try to use the decrypted key
+	if keystore.Checksum.Function != "sha256" {
+		return nil, fmt.Errorf("unsupported checksum %s", keystore.Checksum.Function)
+	}
+	checksumInput := make([]byte, 0, 16+len(ciphertext))
+	checksumInput = append(checksumInput, decryptionKey[16:32]...)
+	checksum := sha256.Sum256(append(checksumInput, ciphertext...))
+	if hex.EncodeToString(checksum[:]) != keystore.Checksum.Message {
+		return nil, errors.New("invalid password")
+	}
+
+	if keystore.Cipher.Function != "aes-128-ctr" {
+		return nil, fmt.Errorf("unsupported cipher %s", keystore.Cipher.Function)
+	}
+	iv, err := hex.DecodeString(keystore.Cipher.Params.IV)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cipher iv: %w", err)
+	}
+	block, err := aes.NewCipher(decryptionKey[:16])
+	if err != nil {
+		return nil, err
+	}
+	if len(iv) != block.BlockSize() {
+		return nil, errors.New("invalid cipher iv length")
+	}
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCTR(block, iv).XORKeyStream(plaintext, ciphertext)
+	return plaintext, nil
+}
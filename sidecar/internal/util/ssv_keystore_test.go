@@ -0,0 +1,87 @@
+package util
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/pbkdf2"
+
+	"github.com/randa-mu/ssv-dkg/shared/crypto"
+)
+
+func TestLoadingPemSsvPrivateKey(t *testing.T) {
+	kp, err := crypto.NewRSASuite().CreateKeypair()
+	require.NoError(t, err)
+	pemKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: kp.Private})
+
+	keyPath := path.Join(t.TempDir(), "key.pem")
+	require.NoError(t, os.WriteFile(keyPath, pemKey, 0o600))
+	key, err := LoadSsvPrivateKey(keyPath, "")
+	require.NoError(t, err)
+	require.Equal(t, kp.Private, key)
+
+	// the SSV node stores its key as base64-encoded PEM
+	require.NoError(t, os.WriteFile(keyPath, []byte(base64.StdEncoding.EncodeToString(pemKey)), 0o600))
+	key, err = LoadSsvPrivateKey(keyPath, "")
+	require.NoError(t, err)
+	require.Equal(t, kp.Private, key)
+}
+
+func TestDecryptingSsvKeystore(t *testing.T) {
+	kp, err := crypto.NewRSASuite().CreateKeypair()
+	require.NoError(t, err)
+	pemKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: kp.Private})
+
+	keyPath := path.Join(t.TempDir(), "encrypted_private_key.json")
+	require.NoError(t, os.WriteFile(keyPath, encryptKeystore(t, []byte(base64.StdEncoding.EncodeToString(pemKey)), "hunter2"), 0o600))
+
+	key, err := LoadSsvPrivateKey(keyPath, "hunter2")
+	require.NoError(t, err)
+	require.Equal(t, kp.Private, key)
+
+	_, err = LoadSsvPrivateKey(keyPath, "wrong")
+	require.ErrorContains(t, err, "invalid password")
+}
+
+// encryptKeystore creates a keystore in the same format as the SSV node, with a cheap kdf for testing
+func encryptKeystore(t *testing.T, secret []byte, password string) []byte {
+	salt := make([]byte, 32)
+	iv := make([]byte, 16)
+	_, err := rand.Read(salt)
+	require.NoError(t, err)
+	_, err = rand.Read(iv)
+	require.NoError(t, err)
+
+	decryptionKey := pbkdf2.Key([]byte(password), salt, 1024, 32, sha256.New)
+	block, err := aes.NewCipher(decryptionKey[:16])
+	require.NoError(t, err)
+	ciphertext := make([]byte, len(secret))
+	cipher.NewCTR(block, iv).XORKeyStream(ciphertext, secret)
+	checksum := sha256.Sum256(append(decryptionKey[16:32:32], ciphertext...))
+
+	var keystore ssvKeystore
+	keystore.Checksum.Function = "sha256"
+	keystore.Checksum.Message = hex.EncodeToString(checksum[:])
+	keystore.Cipher.Function = "aes-128-ctr"
+	keystore.Cipher.Message = hex.EncodeToString(ciphertext)
+	keystore.Cipher.Params.IV = hex.EncodeToString(iv)
+	keystore.Kdf.Function = "pbkdf2"
+	keystore.Kdf.Params.C = 1024
+	keystore.Kdf.Params.Dklen = 32
+	keystore.Kdf.Params.Prf = "hmac-sha256"
+	keystore.Kdf.Params.Salt = hex.EncodeToString(salt)
+
+	bytes, err := json.Marshal(keystore)
+	require.NoError(t, err)
+	return bytes
+}
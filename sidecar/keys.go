@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"path"
+	"time"
 
 	"github.com/randa-mu/ssv-dkg/shared/crypto"
 	"github.com/randa-mu/ssv-dkg/sidecar/internal/util"
@@ -25,13 +26,19 @@ func GenerateKey(stateDir string) error {
 	return nil
 }
 
-func SignKey(url string, stateDir string, operatorID uint32) ([]byte, error) {
+// SignKey signs the node's public key, URL and operator ID, to be added to the operators registry.
+// The signed identity expires after the given validity, or never if it's 0.
+// If the SSV operator's RSA private key is passed, the identity is also signed with it to bind it to the operator ID
+func SignKey(url string, stateDir string, operatorID uint32, validity time.Duration, ssvPrivateKey []byte) ([]byte, error) {
 	if url == "" {
 		return nil, errors.New("you must pass a URL to associate the keypair with")
 	}
 	if operatorID == 0 {
 		return nil, errors.New("operatorID must be greater than 0")
 	}
+	if validity < 0 {
+		return nil, errors.New("validity must not be negative")
+	}
 
 	keypair, err := util.LoadKeypair(stateDir)
 	if err != nil {
@@ -40,10 +47,16 @@ func SignKey(url string, stateDir string, operatorID uint32) ([]byte, error) {
 	}
 
 	suite := crypto.NewBLSSuite()
-	identity, err := keypair.SelfSign(suite, url, operatorID)
+	identity, err := keypair.SelfSign(suite, url, operatorID, validity)
 	if err != nil {
 		return nil, fmt.Errorf("failed to sign address: %w", err)
 	}
+	if ssvPrivateKey != nil {
+		identity, err = identity.SignWithSsvKey(ssvPrivateKey)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	bytes, err := json.Marshal(identity)
 	if err != nil {
@@ -51,3 +64,22 @@ func SignKey(url string, stateDir string, operatorID uint32) ([]byte, error) {
 	}
 	return bytes, nil
 }
+
+// RotateKey replaces the node's keypair with a new one, keeping the old one so the node can still reshare
+// validators created with it, and returns the new key signed for the operators registry
+func RotateKey(url string, stateDir string, operatorID uint32, validity time.Duration, ssvPrivateKey []byte) ([]byte, error) {
+	if url == "" {
+		return nil, errors.New("you must pass a URL to associate the keypair with")
+	}
+	if operatorID == 0 {
+		return nil, errors.New("operatorID must be greater than 0")
+	}
+	if validity < 0 {
+		return nil, errors.New("validity must not be negative")
+	}
+
+	if _, err := util.RotateKeypair(crypto.NewBLSSuite(), stateDir); err != nil {
+		return nil, fmt.Errorf("failed to rotate keypair: %w", err)
+	}
+	return SignKey(url, stateDir, operatorID, validity, ssvPrivateKey)
+}
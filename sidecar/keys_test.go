@@ -1,9 +1,14 @@
 package sidecar
 
 import (
+	"encoding/json"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
+
+	"github.com/randa-mu/ssv-dkg/shared/crypto"
+	"github.com/randa-mu/ssv-dkg/sidecar/internal/util"
 )
 
 func TestSignKey(t *testing.T) {
@@ -14,6 +19,7 @@ func TestSignKey(t *testing.T) {
 		url        string
 		stateDir   string
 		operatorID uint32
+		validity   time.Duration
 	}
 	tests := []struct {
 		name    string
@@ -47,6 +53,26 @@ func TestSignKey(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "expiring identity succeeds",
+			args: args{
+				url:        "https://example.com",
+				stateDir:   stateDir,
+				operatorID: 1,
+				validity:   time.Hour,
+			},
+			wantErr: false,
+		},
+		{
+			name: "negative validity fails",
+			args: args{
+				url:        "https://example.com",
+				stateDir:   stateDir,
+				operatorID: 1,
+				validity:   -time.Hour,
+			},
+			wantErr: true,
+		},
 		{
 			name: "missing operatorID fails",
 			args: args{
@@ -58,7 +84,7 @@ func TestSignKey(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			signedKey, err := SignKey(tt.args.url, tt.args.stateDir, tt.args.operatorID)
+			signedKey, err := SignKey(tt.args.url, tt.args.stateDir, tt.args.operatorID, tt.args.validity, nil)
 			if tt.wantErr {
 				require.Error(t, err)
 			} else {
@@ -69,3 +95,53 @@ func TestSignKey(t *testing.T) {
 		})
 	}
 }
+
+func TestRotateKey(t *testing.T) {
+	stateDir := t.TempDir()
+	require.NoError(t, GenerateKey(stateDir))
+	before, err := util.LoadKeypair(stateDir)
+	require.NoError(t, err)
+
+	// bad arguments don't rotate the key
+	_, err = RotateKey("", stateDir, 1, time.Hour, nil)
+	require.Error(t, err)
+	unchanged, err := util.LoadKeypair(stateDir)
+	require.NoError(t, err)
+	require.Equal(t, before, unchanged)
+
+	signedKey, err := RotateKey("https://example.com", stateDir, 1, time.Hour, nil)
+	require.NoError(t, err)
+
+	var identity crypto.Identity
+	require.NoError(t, json.Unmarshal(signedKey, &identity))
+	require.NoError(t, identity.Verify(crypto.NewBLSSuite()))
+	require.NotEqual(t, []byte(before.Public), []byte(identity.Public))
+
+	keyring, err := util.LoadKeyring(stateDir)
+	require.NoError(t, err)
+	_, found := keyring.Find(before.Public)
+	require.True(t, found)
+}
+
+func TestSignKeyWithSsvKey(t *testing.T) {
+	stateDir := t.TempDir()
+	require.NoError(t, GenerateKey(stateDir))
+	ssvKey, err := crypto.NewRSASuite().CreateKeypair()
+	require.NoError(t, err)
+
+	signedKey, err := SignKey("https://example.com", stateDir, 1, time.Hour, ssvKey.Private)
+	require.NoError(t, err)
+
+	var identity crypto.Identity
+	require.NoError(t, json.Unmarshal(signedKey, &identity))
+	require.NoError(t, identity.Verify(crypto.NewBLSSuite()))
+	require.NoError(t, identity.VerifySsvSignature(ssvKey.Public))
+
+	otherKey, err := crypto.NewRSASuite().CreateKeypair()
+	require.NoError(t, err)
+	require.Error(t, identity.VerifySsvSignature(otherKey.Public))
+
+	// the SSV signature covers the identity, so it can't be moved to another one
+	identity.Address = "https://evil.example.com"
+	require.Error(t, identity.VerifySsvSignature(ssvKey.Public))
+}
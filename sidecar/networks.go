@@ -0,0 +1,36 @@
+package sidecar
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/randa-mu/ssv-dkg/shared/api"
+	"github.com/randa-mu/ssv-dkg/shared/network"
+)
+
+// matchNetwork finds the network the sidecar is configured for that the deposit data is destined for.
+// Signing a deposit for a fork version we weren't configured with could create a validator on a chain
+// the operator never agreed to run it on, so we refuse rather than trusting the client
+func matchNetwork(networks []network.Network, depositData api.UnsignedDepositData) (network.Network, error) {
+	for _, n := range networks {
+		if !bytes.Equal(n.GenesisForkVersion, depositData.ForkVersion) {
+			continue
+		}
+		// the network name is optional, but if it's present it must agree with the fork version
+		if depositData.NetworkName != "" && depositData.NetworkName != n.Name {
+			return network.Network{}, fmt.Errorf("network name %s does not match fork version %x, which is %s", depositData.NetworkName, []byte(depositData.ForkVersion), n.Name)
+		}
+		return n, nil
+	}
+
+	return network.Network{}, fmt.Errorf("fork version %x is not supported by this sidecar; it supports %s", []byte(depositData.ForkVersion), networkNames(networks))
+}
+
+func networkNames(networks []network.Network) string {
+	names := make([]string, len(networks))
+	for i, n := range networks {
+		names[i] = n.Name
+	}
+	return strings.Join(names, ", ")
+}
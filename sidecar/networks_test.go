@@ -0,0 +1,36 @@
+package sidecar
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/randa-mu/ssv-dkg/shared/api"
+	"github.com/randa-mu/ssv-dkg/shared/network"
+)
+
+func TestMatchNetwork(t *testing.T) {
+	networks := []network.Network{network.Mainnet, network.Hoodi}
+
+	n, err := matchNetwork(networks, api.UnsignedDepositData{ForkVersion: network.Hoodi.GenesisForkVersion, NetworkName: "hoodi"})
+	require.NoError(t, err)
+	require.Equal(t, network.Hoodi, n)
+
+	n, err = matchNetwork(networks, api.UnsignedDepositData{ForkVersion: network.Mainnet.GenesisForkVersion})
+	require.NoError(t, err)
+	require.Equal(t, network.Mainnet, n)
+}
+
+func TestMatchNetworkRejectsUnconfiguredForkVersion(t *testing.T) {
+	networks := []network.Network{network.Mainnet}
+
+	_, err := matchNetwork(networks, api.UnsignedDepositData{ForkVersion: network.Holesky.GenesisForkVersion, NetworkName: "holesky"})
+	require.Error(t, err)
+}
+
+func TestMatchNetworkRejectsMismatchedName(t *testing.T) {
+	networks := []network.Network{network.Mainnet, network.Holesky}
+
+	_, err := matchNetwork(networks, api.UnsignedDepositData{ForkVersion: network.Mainnet.GenesisForkVersion, NetworkName: "holesky"})
+	require.Error(t, err)
+}
@@ -0,0 +1,32 @@
+package sidecar
+
+import "sync"
+
+// inFlightSessions tracks the sessionIDs of the DKGs we're running, as a DKG only stores its state once it completes,
+// so two concurrent requests with the same sessionID would otherwise both get past the check for a used one
+type inFlightSessions struct {
+	lock sync.Mutex
+	ids  map[string]bool
+}
+
+func newInFlightSessions() *inFlightSessions {
+	return &inFlightSessions{ids: make(map[string]bool)}
+}
+
+// start marks the sessionID as in use, returning false if it already is
+func (s *inFlightSessions) start(sessionID string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.ids[sessionID] {
+		return false
+	}
+	s.ids[sessionID] = true
+	return true
+}
+
+// finish frees the sessionID once its DKG has stored its state or failed
+func (s *inFlightSessions) finish(sessionID string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.ids, sessionID)
+}
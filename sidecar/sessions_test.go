@@ -0,0 +1,37 @@
+package sidecar
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInFlightSessionsRejectsConcurrentDuplicates(t *testing.T) {
+	sessions := newInFlightSessions()
+
+	started := make(chan bool, 10)
+	wg := sync.WaitGroup{}
+	for i := 0; i < cap(started); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			started <- sessions.start("cafebabe")
+		}()
+	}
+	wg.Wait()
+	close(started)
+
+	count := 0
+	for ok := range started {
+		if ok {
+			count++
+		}
+	}
+	require.Equal(t, 1, count)
+
+	// other sessions aren't affected, and the sessionID can be checked against the stored state again once finished
+	require.True(t, sessions.start("deadbeef"))
+	sessions.finish("cafebabe")
+	require.True(t, sessions.start("cafebabe"))
+}
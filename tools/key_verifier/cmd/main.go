@@ -4,16 +4,27 @@ import (
 	"log"
 	"os"
 
+	"github.com/randa-mu/ssv-dkg/shared/network"
 	"github.com/randa-mu/ssv-dkg/tools/key_verifier"
 )
 
 func main() {
-	if len(os.Args) != 2 {
-		log.Fatal("you must provide a filepath of keys to verify")
+	if len(os.Args) != 2 && len(os.Args) != 3 {
+		log.Fatal("you must provide a filepath of keys to verify, and optionally the network whose registry maintainers must have signed it")
 	}
 
-	err := key_verifier.VerifyKeys(os.Args[1])
+	if len(os.Args) == 2 {
+		if err := key_verifier.VerifyKeys(os.Args[1]); err != nil {
+			log.Fatalf("key validation error: %v", err)
+		}
+		return
+	}
+
+	n, err := network.Resolve(os.Args[2])
 	if err != nil {
+		log.Fatalf("error loading network: %v", err)
+	}
+	if err := key_verifier.VerifyRegistry(os.Args[1], n); err != nil {
 		log.Fatalf("key validation error: %v", err)
 	}
 }
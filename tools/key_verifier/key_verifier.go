@@ -1,35 +1,77 @@
 package key_verifier
 
 import (
-	"encoding/json"
 	"fmt"
-	"os"
+	"time"
 
+	"github.com/randa-mu/ssv-dkg/shared/api"
 	"github.com/randa-mu/ssv-dkg/shared/crypto"
+	"github.com/randa-mu/ssv-dkg/shared/network"
+	"github.com/randa-mu/ssv-dkg/shared/registry"
 )
 
-type operatorsFile struct {
-	Operators []crypto.Identity `json:"operators"`
-}
-
+// VerifyKeys checks every operator's identity in the registry is validly signed, and hasn't expired or been revoked
 func VerifyKeys(filepath string) error {
 	suite := crypto.NewBLSSuite()
-	file, err := os.ReadFile(filepath)
+	r, err := registry.Load(filepath)
 	if err != nil {
-		return fmt.Errorf("error opening file for verification: %w", err)
+		return fmt.Errorf("error loading registry for verification: %w", err)
+	}
+	now := time.Now()
+	for _, operator := range r.Operators {
+		if err := operator.Verify(suite); err != nil {
+			return fmt.Errorf("❌ key verification failed for %s", operator.Address)
+		}
+		if err := operator.CheckExpiry(now); err != nil {
+			return fmt.Errorf("❌ key verification failed for %s: %w", operator.Address, err)
+		}
+		if err := r.CheckRevoked(operator.Identity); err != nil {
+			return fmt.Errorf("❌ key verification failed for %s: %w", operator.Address, err)
+		}
 	}
 
-	var f operatorsFile
-	err = json.Unmarshal(file, &f)
+	fmt.Println("✅ All keys verified successfully!")
+	return nil
+}
+
+// VerifyRegistry checks the registry is signed by a threshold of the network's registry maintainers
+// and hasn't expired, if the network has any, before verifying the keys in it
+func VerifyRegistry(filepath string, n network.Network) error {
+	if n.RegistryMaintainers.Configured() {
+		if _, err := registry.Resolve(filepath, n.RegistryMaintainers); err != nil {
+			return fmt.Errorf("❌ registry signature verification failed: %w", err)
+		}
+		fmt.Println("✅ Registry signatures verified successfully!")
+	}
+	if err := VerifyKeys(filepath); err != nil {
+		return err
+	}
+	if n.SsvApiUrl == "" {
+		return nil
+	}
+	return VerifySsvBindings(filepath, api.NewSsvClient(n.SsvApiUrl))
+}
+
+// VerifySsvBindings checks every operator's identity is signed by the key registered on SSV for its operator ID,
+// so no entry can claim an operator ID it doesn't own
+func VerifySsvBindings(filepath string, client api.SsvClient) error {
+	r, err := registry.Load(filepath)
 	if err != nil {
-		return fmt.Errorf("error unmarshalling JSON in file: %w", err)
+		return fmt.Errorf("error loading registry for verification: %w", err)
 	}
-	for _, identity := range f.Operators {
-		if err := identity.Verify(suite); err != nil {
-			return fmt.Errorf("❌ key verification failed for %s", identity.Address)
+	for _, operator := range r.Operators {
+		if len(operator.SsvSignature) == 0 {
+			return fmt.Errorf("❌ operator %d's identity is not signed by its SSV operator key; it must sign it with `ssv-sidecar key sign --ssv-private-key`", operator.OperatorID)
+		}
+		ssvOperator, err := client.FetchPublicKeyFromSsv(operator.OperatorID)
+		if err != nil {
+			return fmt.Errorf("❌ error fetching SSV key for operator %d: %w", operator.OperatorID, err)
+		}
+		if err := operator.VerifySsvSignature(ssvOperator.PublicKey); err != nil {
+			return fmt.Errorf("❌ SSV key verification failed for %s: %w", operator.Address, err)
 		}
 	}
 
-	fmt.Println("✅ All keys verified successfully!")
+	fmt.Println("✅ All SSV keys verified successfully!")
 	return nil
 }
@@ -1,11 +1,21 @@
 package key_verifier
 
 import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
+
+	"github.com/randa-mu/ssv-dkg/shared/api"
+	"github.com/randa-mu/ssv-dkg/shared/crypto"
+	"github.com/randa-mu/ssv-dkg/shared/network"
+	"github.com/randa-mu/ssv-dkg/shared/registry"
 )
 
 func TestValidKeyValidates(t *testing.T) {
@@ -162,3 +172,116 @@ func TestNonExistentFileReturnsError(t *testing.T) {
 
 	require.Error(t, VerifyKeys("somenonsensepath"))
 }
+
+func TestRegistryMustBeSignedByMaintainers(t *testing.T) {
+	t.Parallel()
+
+	valid := fmt.Sprintf(`
+{
+  "serial": 1,
+  "expiry": "%s",
+  "operators": [
+{
+  "operator_id": 3,
+  "address": "http://127.0.0.1:8083",
+  "public": "qa49KGl9moW+GEHol+9lVZmniwKoeSgVTh6FAXQsENlIS8WTRWgO6mSfRXGUwo7D",
+  "signature": "gx7XWG4cc7gzPj/qB0rqNzzqChaOgrByxQ+bNJeHMRkBm1jyvcvmrWQGbOE4fzZID85edxt+4B1V1zctG75OL+7MOA2RKK8EAm44i7RCWsBwvXYOwDOc39yCtx9cdgwz"
+}
+  ]
+}`, time.Now().Add(time.Hour).Format(time.RFC3339))
+
+	p := path.Join(t.TempDir(), "keys.json")
+	require.NoError(t, os.WriteFile(p, []byte(valid), 0o644))
+
+	suite := crypto.NewBLSSuite()
+	maintainer, err := suite.CreateKeypair()
+	require.NoError(t, err)
+	n := network.Network{RegistryMaintainers: registry.Maintainers{Keys: [][]byte{maintainer.Public}, Threshold: 1}}
+
+	// the keys are valid, but the registry isn't signed
+	require.NoError(t, VerifyKeys(p))
+	require.Error(t, VerifyRegistry(p, n))
+
+	signature, err := registry.Sign(suite, maintainer, []byte(valid))
+	require.NoError(t, err)
+	signatures, err := json.Marshal(registry.SignatureFile{Signatures: []registry.MaintainerSignature{signature}})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(p+registry.SignatureFileSuffix, signatures, 0o644))
+
+	require.NoError(t, VerifyRegistry(p, n))
+}
+
+func TestRevokedKeyReturnsError(t *testing.T) {
+	t.Parallel()
+
+	revoked := `
+{
+  "operators": [
+{
+  "operator_id": 3,
+  "address": "http://127.0.0.1:8083",
+  "public": "qa49KGl9moW+GEHol+9lVZmniwKoeSgVTh6FAXQsENlIS8WTRWgO6mSfRXGUwo7D",
+  "signature": "gx7XWG4cc7gzPj/qB0rqNzzqChaOgrByxQ+bNJeHMRkBm1jyvcvmrWQGbOE4fzZID85edxt+4B1V1zctG75OL+7MOA2RKK8EAm44i7RCWsBwvXYOwDOc39yCtx9cdgwz"
+}
+  ],
+  "revocations": [
+{
+  "public": "qa49KGl9moW+GEHol+9lVZmniwKoeSgVTh6FAXQsENlIS8WTRWgO6mSfRXGUwo7D",
+  "reason": "key leaked"
+}
+  ]
+}`
+
+	p := path.Join(t.TempDir(), "keys.json")
+	require.NoError(t, os.WriteFile(p, []byte(revoked), 0o644))
+
+	require.ErrorContains(t, VerifyKeys(p), "key leaked")
+}
+
+func TestSsvBindingsVerifyAgainstSsvApi(t *testing.T) {
+	t.Parallel()
+
+	ssvKey, err := crypto.NewRSASuite().CreateKeypair()
+	require.NoError(t, err)
+	otherSsvKey, err := crypto.NewRSASuite().CreateKeypair()
+	require.NoError(t, err)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := ssvKey.Public
+		if r.URL.Path == "/operators/2" {
+			key = otherSsvKey.Public
+		}
+		_ = json.NewEncoder(w).Encode(api.SsvApiResponse{PublicKey: key})
+	}))
+	t.Cleanup(server.Close)
+
+	suite := crypto.NewBLSSuite()
+	kp, err := suite.CreateKeypair()
+	require.NoError(t, err)
+	identity, err := kp.SelfSign(suite, "https://example.org", 1, time.Hour)
+	require.NoError(t, err)
+	identity, err = identity.SignWithSsvKey(ssvKey.Private)
+	require.NoError(t, err)
+
+	p := path.Join(t.TempDir(), "keys.json")
+	writeRegistry := func(identity crypto.Identity) {
+		contents, err := json.Marshal(registry.Registry{Operators: []registry.Operator{{Identity: identity}}})
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(p, contents, 0o644))
+	}
+
+	writeRegistry(identity)
+	require.NoError(t, VerifySsvBindings(p, api.NewSsvClient(server.URL)))
+
+	// signed by a different SSV operator than the one it claims to be
+	identity, err = kp.SelfSign(suite, "https://example.org", 2, time.Hour)
+	require.NoError(t, err)
+	identity, err = identity.SignWithSsvKey(ssvKey.Private)
+	require.NoError(t, err)
+	writeRegistry(identity)
+	require.Error(t, VerifySsvBindings(p, api.NewSsvClient(server.URL)))
+
+	// or not signed by an SSV operator at all
+	identity.SsvSignature = nil
+	writeRegistry(identity)
+	require.ErrorContains(t, VerifySsvBindings(p, api.NewSsvClient(server.URL)), "not signed by its SSV operator key")
+}
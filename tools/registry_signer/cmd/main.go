@@ -0,0 +1,31 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/randa-mu/ssv-dkg/tools/registry_signer"
+)
+
+func main() {
+	if len(os.Args) == 3 && os.Args[1] == "keygen" {
+		keypair, err := registry_signer.CreateMaintainerKey(os.Args[2])
+		if err != nil {
+			log.Fatalf("key generation error: %v", err)
+		}
+		fmt.Printf("✅ maintainer key written to %s; its public key is %s\n", os.Args[2], hex.EncodeToString(keypair.Public))
+		return
+	}
+
+	if len(os.Args) == 4 && os.Args[1] == "sign" {
+		if err := registry_signer.SignRegistry(os.Args[2], os.Args[3]); err != nil {
+			log.Fatalf("signing error: %v", err)
+		}
+		fmt.Printf("✅ signature added to %s.sig\n", os.Args[2])
+		return
+	}
+
+	log.Fatal("usage: registry-signer keygen <keypair file> | registry-signer sign <registry file> <keypair file>")
+}
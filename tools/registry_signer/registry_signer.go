@@ -0,0 +1,81 @@
+package registry_signer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/exp/slices"
+
+	"github.com/randa-mu/ssv-dkg/shared/crypto"
+	"github.com/randa-mu/ssv-dkg/shared/registry"
+)
+
+// CreateMaintainerKey creates a new maintainer keypair and writes it to the given path.
+// The public key is what goes in the `registry_maintainers` of a network file
+func CreateMaintainerKey(filepath string) (crypto.Keypair, error) {
+	keypair, err := crypto.NewBLSSuite().CreateKeypair()
+	if err != nil {
+		return crypto.Keypair{}, fmt.Errorf("error creating keypair: %w", err)
+	}
+	bytes, err := json.Marshal(keypair)
+	if err != nil {
+		return crypto.Keypair{}, fmt.Errorf("error marshalling keypair: %w", err)
+	}
+	if err := os.WriteFile(filepath, bytes, 0o600); err != nil {
+		return crypto.Keypair{}, fmt.Errorf("error writing keypair: %w", err)
+	}
+	return keypair, nil
+}
+
+// SignRegistry adds the maintainer's signature over the registry file to its detached signature file,
+// replacing any signature the maintainer made before
+func SignRegistry(registryPath string, keypairPath string) error {
+	keypairBytes, err := os.ReadFile(keypairPath)
+	if err != nil {
+		return fmt.Errorf("error reading keypair: %w", err)
+	}
+	var keypair crypto.Keypair
+	if err := json.Unmarshal(keypairBytes, &keypair); err != nil {
+		return fmt.Errorf("error unmarshalling keypair: %w", err)
+	}
+
+	contents, err := os.ReadFile(registryPath)
+	if err != nil {
+		return fmt.Errorf("error reading registry: %w", err)
+	}
+	r := registry.Registry{}
+	if err := json.Unmarshal(contents, &r); err != nil {
+		return fmt.Errorf("error unmarshalling registry: %w", err)
+	}
+	if r.Serial == 0 || r.Expiry.IsZero() {
+		return errors.New("a registry must have a serial and an expiry before it can be signed")
+	}
+
+	signatures := registry.SignatureFile{}
+	signaturePath := registryPath + registry.SignatureFileSuffix
+	existing, err := os.ReadFile(signaturePath)
+	if err == nil {
+		if err := json.Unmarshal(existing, &signatures); err != nil {
+			return fmt.Errorf("error unmarshalling existing signatures: %w", err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("error reading existing signatures: %w", err)
+	}
+
+	signature, err := registry.Sign(crypto.NewBLSSuite(), keypair, contents)
+	if err != nil {
+		return fmt.Errorf("error signing registry: %w", err)
+	}
+	signatures.Signatures = slices.DeleteFunc(signatures.Signatures, func(s registry.MaintainerSignature) bool {
+		return slices.Equal(s.PublicKey, signature.PublicKey)
+	})
+	signatures.Signatures = append(signatures.Signatures, signature)
+
+	bytes, err := json.MarshalIndent(signatures, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshalling signatures: %w", err)
+	}
+	return os.WriteFile(signaturePath, bytes, 0o644)
+}
@@ -0,0 +1,50 @@
+package registry_signer
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/randa-mu/ssv-dkg/shared/registry"
+)
+
+func TestSigningRegistryByThresholdOfMaintainers(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	registryPath := path.Join(dir, "operators.json")
+	contents := fmt.Sprintf(`{"serial": 1, "expiry": "%s", "operators": []}`, time.Now().Add(time.Hour).Format(time.RFC3339))
+	require.NoError(t, os.WriteFile(registryPath, []byte(contents), 0o644))
+
+	first, err := CreateMaintainerKey(path.Join(dir, "first.json"))
+	require.NoError(t, err)
+	second, err := CreateMaintainerKey(path.Join(dir, "second.json"))
+	require.NoError(t, err)
+	maintainers := registry.Maintainers{Keys: [][]byte{first.Public, second.Public}, Threshold: 2}
+
+	require.NoError(t, SignRegistry(registryPath, path.Join(dir, "first.json")))
+	// signing twice with the same key replaces the signature rather than counting twice
+	require.NoError(t, SignRegistry(registryPath, path.Join(dir, "first.json")))
+	_, err = registry.Resolve(registryPath, maintainers)
+	require.Error(t, err)
+
+	require.NoError(t, SignRegistry(registryPath, path.Join(dir, "second.json")))
+	_, err = registry.Resolve(registryPath, maintainers)
+	require.NoError(t, err)
+}
+
+func TestSigningRegistryWithoutExpiryFails(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	registryPath := path.Join(dir, "operators.json")
+	require.NoError(t, os.WriteFile(registryPath, []byte(`{"operators": []}`), 0o644))
+	_, err := CreateMaintainerKey(path.Join(dir, "key.json"))
+	require.NoError(t, err)
+
+	require.Error(t, SignRegistry(registryPath, path.Join(dir, "key.json")))
+}